@@ -9,7 +9,11 @@ package core
 import (
 	"context"
 	"fmt"
+	"os"
+	"path/filepath"
 	"reflect"
+	"strings"
+	"sync"
 
 	"github.com/DoNewsCode/core/codec/yaml"
 	"github.com/DoNewsCode/core/config"
@@ -37,6 +41,14 @@ type C struct {
 	container  *container.Container
 	di         *dig.Container
 	baseLogger log.Logger
+	closeOnce  sync.Once
+	// constructors are recorded for the di graph command.
+	constructors []*diConstructor
+	// args is the configuration layer of the --config and --set flags.
+	args *argsProvider
+	// origins maps the index of a module to the ID of the constructor that
+	// created it, if any. It is used to close unrelated modules in parallel.
+	origins map[int]int
 }
 
 // ConfParser models a parser for configuration. For example, yaml.Parser.
@@ -73,6 +85,10 @@ type coreValues struct {
 	// Base Values
 	configStack   []config.ProviderSet
 	configWatcher contract.ConfigWatcher
+	envPrefix     string
+	envSeparator  string
+	resolvers     map[string]config.SecretResolver
+	args          []string
 	// ConfProvider functions
 	configProvider  ConfigProvider
 	diProvider      DiProvider
@@ -87,10 +103,37 @@ type CoreOption func(*coreValues)
 // WithYamlFile is a two-in-one coreOption. It uses the configuration file as the
 // source of configuration, and watches the change of that file for hot reloading.
 func WithYamlFile(path string) (CoreOption, CoreOption) {
-	return WithConfigStack(file.Provider(path), config.CodecParser{Codec: yaml.Codec{}}),
+	return WithNamedConfigStack("file "+path, file.Provider(path), config.CodecParser{Codec: yaml.Codec{}}),
 		WithConfigWatcher(watcher.File{Path: path})
 }
 
+// WithYamlDir is a two-in-one coreOption. It uses the *.yaml files in the
+// directory as the source of configuration, and watches the directory for hot
+// reloading. The files are loaded in lexical order as separate layers, so that
+// the later files take precedence. Bursts of changes, such as the symlink swap
+// of a Kubernetes ConfigMap, are debounced into a single reload. See
+// config.DirProvider and watcher.Dir.
+func WithYamlDir(dir string) (CoreOption, CoreOption) {
+	return WithConfigStack(config.DirProvider{Path: dir}, nil),
+		WithConfigWatcher(watcher.Dir{Path: dir})
+}
+
+// WithYamlOverlays is like WithYamlFile, but also loads the overlays of the
+// configuration file when they exist. For config.yaml, they are
+// config.<env>.yaml and config.local.yaml, in the ascending order of precedence.
+// The environment is resolved by config.NewEnv from the env entry, which can be
+// overridden by the flags or the environment variables. The configuration file
+// and the overlays are watched together. See config.OverlayProvider.
+func WithYamlOverlays(path string) (CoreOption, CoreOption) {
+	ext := filepath.Ext(path)
+	pattern := strings.TrimSuffix(filepath.Base(path), ext) + "*" + ext
+	stack := func(values *coreValues) {
+		WithConfigStack(config.OverlayProvider{Path: path}, nil)(values)
+		WithNamedConfigStack("file "+path, file.Provider(path), config.CodecParser{Codec: yaml.Codec{}})(values)
+	}
+	return stack, WithConfigWatcher(watcher.Dir{Path: filepath.Dir(path), Pattern: pattern})
+}
+
 // WithInline is a CoreOption that creates a inline config in the configuration stack.
 func WithInline(key string, entry any) CoreOption {
 	return WithConfigStack(confmap.Provider(map[string]any{
@@ -98,6 +141,51 @@ func WithInline(key string, entry any) CoreOption {
 	}, "."), nil)
 }
 
+// WithEnvPrefix is a CoreOption that adds the environment variables with the
+// given prefix to the configuration stack. The layer ranks below every inline
+// value regardless of the order of the options, and above the configuration
+// files added after the last inline value. For example, APP_HTTP_ADDR overrides http.addr, and
+// APP_GORM__DEFAULT__DSN overrides gorm.default.dsn. See config.EnvProvider for
+// how the variables are mapped.
+func WithEnvPrefix(prefix string) CoreOption {
+	return func(values *coreValues) {
+		values.envPrefix = prefix
+	}
+}
+
+// WithEnvSeparator is a CoreOption that changes the separator of the key path in
+// the environment variables added by WithEnvPrefix. The default separator is "_".
+func WithEnvSeparator(separator string) CoreOption {
+	return func(values *coreValues) {
+		values.envSeparator = separator
+	}
+}
+
+// WithSecretResolver is a CoreOption that resolves the secret references of the
+// scheme in the config values, such as ${vault:kv/data/app#password}, with the
+// resolver. The references ${env:NAME} and ${file:path} are always resolved.
+// The default ConfigProvider is reloaded once with the resolvers. See
+// config.WithSecretResolver.
+func WithSecretResolver(scheme string, resolver config.SecretResolver) CoreOption {
+	return func(values *coreValues) {
+		if values.resolvers == nil {
+			values.resolvers = make(map[string]config.SecretResolver)
+		}
+		values.resolvers[scheme] = resolver
+	}
+}
+
+// WithConfigFlags is a CoreOption that replaces the command-line arguments in
+// which the --config and --set flags are looked for when the core is created.
+// The default arguments are os.Args[1:]. Pass nil to ignore the command line,
+// for example in tests and libraries, or if the root command defines its own
+// --config or --set flag.
+func WithConfigFlags(args []string) CoreOption {
+	return func(values *coreValues) {
+		values.args = args
+	}
+}
+
 // WithConfigStack is a CoreOption that defines a configuration layer. See package config for details.
 func WithConfigStack(provider ConfProvider, parser ConfParser) CoreOption {
 	return func(values *coreValues) {
@@ -105,6 +193,15 @@ func WithConfigStack(provider ConfProvider, parser ConfParser) CoreOption {
 	}
 }
 
+// WithNamedConfigStack is like WithConfigStack, but the layer is given a name,
+// such as "file config.yaml", to be reported as the source of its values by
+// config dump --explain.
+func WithNamedConfigStack(name string, provider ConfProvider, parser ConfParser) CoreOption {
+	return func(values *coreValues) {
+		values.configStack = append(values.configStack, config.ProviderSet{Parser: parser, Provider: provider, Name: name})
+	}
+}
+
 // WithConfigWatcher is a CoreOption that adds a config watcher to the core (for hot reloading configs).
 func WithConfigWatcher(watcher contract.ConfigWatcher) CoreOption {
 	return func(values *coreValues) {
@@ -134,6 +231,8 @@ func SetEnvProvider(provider EnvProvider) CoreOption {
 }
 
 // SetLoggerProvider is a CoreOption to replaces the default LoggerProvider.
+// Unlike ProvideLogger, a custom provider has to wrap its logger with
+// logging.WithRedaction itself to keep the config secrets out of logs.
 func SetLoggerProvider(provider LoggerProvider) CoreOption {
 	return func(values *coreValues) {
 		values.loggerProvider = provider
@@ -157,11 +256,29 @@ func New(opts ...CoreOption) *C {
 		envProvider:     ProvideEnv,
 		loggerProvider:  ProvideLogger,
 		diProvider:      ProvideDi,
+		args:            os.Args[1:],
 	}
 	for _, f := range opts {
 		f(&values)
 	}
+	if values.envPrefix != "" {
+		values.configStack = withEnvLayer(values.configStack, config.EnvProvider{
+			Prefix:    values.envPrefix,
+			Separator: values.envSeparator,
+		})
+	}
+	args := &argsProvider{}
+	args.files, args.sets = scanArgs(values.args)
+	values.configStack = append([]config.ProviderSet{{Provider: args, Name: "flags"}}, values.configStack...)
 	conf := values.configProvider(values.configStack, values.configWatcher)
+	if adapter, ok := conf.(*config.KoanfAdapter); ok && len(values.resolvers) > 0 {
+		for scheme, resolver := range values.resolvers {
+			adapter.AddSecretResolver(scheme, resolver)
+		}
+		if err := adapter.Reload(); err != nil {
+			panic(fmt.Errorf("failed to load config: %w", err))
+		}
+	}
 	env := values.envProvider(conf)
 	appName := values.appNameProvider(conf)
 	logger := values.loggerProvider(conf, appName, env)
@@ -175,10 +292,25 @@ func New(opts ...CoreOption) *C {
 		container:  &container.Container{},
 		di:         diContainer,
 		baseLogger: logger,
+		args:       args,
 	}
 	return &c
 }
 
+// withEnvLayer inserts the env layer right below the lowest inline layer, so
+// that every inline value overrides the environment variables.
+func withEnvLayer(stack []config.ProviderSet, env config.EnvProvider) []config.ProviderSet {
+	i := 0
+	for j := range stack {
+		if _, ok := stack[j].Provider.(*confmap.Confmap); ok {
+			i = j + 1
+		}
+	}
+	out := append([]config.ProviderSet{}, stack[:i]...)
+	out = append(out, config.ProviderSet{Provider: env})
+	return append(out, stack[i:]...)
+}
+
 // Default creates a core.C under its default state. Core dependencies are
 // already provided, and the config module and serve module are bundled.
 func Default(opts ...CoreOption) *C {
@@ -197,14 +329,18 @@ func Default(opts ...CoreOption) *C {
 // Note that the module added in this way will not retain any original field
 // values, i.e. the module will only contain fields populated by DI container.
 func (c *C) AddModule(module any) {
+	c.addModule(module, -1)
+}
+
+// addModule adds the module created by the constructor of the given ID. The ID
+// is negative if the module is not created by a constructor.
+func (c *C) addModule(module any, origin int) {
 	t := reflect.TypeOf(module)
 	if t.Kind() == reflect.Ptr && dig.IsIn(t.Elem()) {
 		err := di.IntoPopulator(c.di).Populate(module)
 		if err != nil {
 			panic(err)
 		}
-		c.container.AddModule(module)
-		return
 	}
 	if dig.IsIn(t) {
 		copy := reflect.New(t)
@@ -212,8 +348,14 @@ func (c *C) AddModule(module any) {
 		if err != nil {
 			panic(err)
 		}
-		c.container.AddModule(copy.Elem().Interface())
-		return
+		module = copy.Elem().Interface()
+	}
+	// Populate may add other modules. Record the origin at the final index.
+	if origin >= 0 {
+		if c.origins == nil {
+			c.origins = make(map[int]int)
+		}
+		c.origins[len(c.container.Modules())] = origin
 	}
 	c.container.AddModule(module)
 }
@@ -221,7 +363,7 @@ func (c *C) AddModule(module any) {
 // Provide adds dependencies provider to the core. Note the dependency provider
 // must be a function in the form of:
 //
-//  func(foo Foo) Bar
+//	func(foo Foo) Bar
 //
 // where foo is the upstream dependency and Bar is the provided type. The order
 // for providers doesn't matter. They are only executed lazily when the Invoke is
@@ -234,7 +376,9 @@ func (c *C) AddModule(module any) {
 // from google/wire (https://github.com/google/wire). All "func()" returned by
 // constructor are treated as clean up functions. It also examines if the
 // dependency implements the modular interface. If so, this dependency will be
-// added the module collection.
+// added the module collection. A di.ConfigDeps is called with the configuration,
+// and the returned dependencies are provided in its place. Provide panics if the
+// di.ConfigDeps fails.
 func (c *C) Provide(deps di.Deps) {
 	for _, dep := range deps {
 		c.provide(dep)
@@ -244,12 +388,23 @@ func (c *C) Provide(deps di.Deps) {
 func (c *C) provide(constructor any) {
 	var (
 		options        []dig.ProvideOption
+		info           di.ProvideInfo
 		shouldMakeFunc bool
 	)
 
+	if configDeps, ok := constructor.(di.ConfigDeps); ok {
+		deps, err := configDeps(c.conf)
+		if err != nil {
+			panic(err)
+		}
+		c.Provide(deps)
+		return
+	}
+
 	if op, ok := constructor.(di.OptionalProvider); ok {
 		constructor = op.Constructor
 		options = op.Options
+		info = op.Info()
 	}
 
 	ftype := reflect.TypeOf(constructor)
@@ -288,10 +443,12 @@ func (c *C) provide(constructor any) {
 		if err != nil {
 			panic(err)
 		}
+		c.recordConstructor(constructor, info)
 		return
 	}
 
 	// has cleanup or module, use reflect.MakeFunc as interceptor.
+	id := len(c.constructors)
 	fnType := reflect.FuncOf(inTypes, outTypes, ftype.IsVariadic() /* variadic */)
 	fn := reflect.MakeFunc(fnType, func(args []reflect.Value) []reflect.Value {
 		filteredOuts := make([]reflect.Value, 0)
@@ -299,11 +456,11 @@ func (c *C) provide(constructor any) {
 		for _, v := range outVs {
 			vType := v.Type()
 			if isCleanup(vType) {
-				c.AddModule(cleanup(v.Interface().(func())))
+				c.addModule(cleanup(v.Interface().(func())), id)
 				continue
 			}
 			if isModule(vType) {
-				c.AddModule(v.Interface().(di.Modular).Module())
+				c.addModule(v.Interface().(di.Modular).Module(), id)
 			}
 			filteredOuts = append(filteredOuts, v)
 		}
@@ -314,6 +471,7 @@ func (c *C) provide(constructor any) {
 	if err != nil {
 		panic(err)
 	}
+	c.recordConstructor(constructor, info)
 }
 
 // ProvideEssentials adds the default core dependencies to the core.
@@ -333,6 +491,7 @@ func (c *C) ProvideEssentials() {
 		LevelLogger       logging.LevelLogger
 		Lifecycles        lifecycleOut
 		DefaultConfigs    []config.ExportedConfig `group:"config,flatten"`
+		Closer            closeFunc
 	}
 
 	c.provide(func() coreDependencies {
@@ -347,6 +506,7 @@ func (c *C) ProvideEssentials() {
 			DIPopulator:       di.IntoPopulator(c.di),
 			Lifecycles:        provideLifecycle(),
 			DefaultConfigs:    provideDefaultConfig(),
+			Closer:            c.close,
 		}
 		if cc, ok := c.conf.(contract.ConfigRouter); ok {
 			coreDependencies.ConfigRouter = cc
@@ -376,19 +536,23 @@ func (c *C) Serve(ctx context.Context) error {
 }
 
 // Shutdown iterates through every CloserProvider registered in the container,
-// and calls them in the reversed order of registration.
+// and calls them in the reversed order of registration. The serve command
+// already does this as the last step of its graceful shutdown. Calling Shutdown
+// more than once is a no-op.
 func (c *C) Shutdown() {
-	modules := c.Modules()
-	for i := range modules {
-		if closer, ok := modules[len(modules)-i-1].(CloserProvider); ok {
-			closer.ProvideCloser()
-		}
-	}
+	_ = c.close(context.Background())
+}
+
+// ShutdownContext is like Shutdown, but it returns once the context expires,
+// and reports the closers that failed. See ContextCloserProvider.
+func (c *C) ShutdownContext(ctx context.Context) error {
+	return c.close(ctx)
 }
 
 // AddModuleFunc add the module after Invoking its constructor. Clean up
 // functions and errors are handled automatically.
 func (c *C) AddModuleFunc(constructor any) {
+	id := len(c.constructors)
 	c.provide(constructor)
 	ftype := reflect.TypeOf(constructor)
 	targetTypes := make([]reflect.Type, 0)
@@ -406,7 +570,7 @@ func (c *C) AddModuleFunc(constructor any) {
 	fnType := reflect.FuncOf(targetTypes, nil, false /* variadic */)
 	fn := reflect.MakeFunc(fnType, func(args []reflect.Value) []reflect.Value {
 		for _, arg := range args {
-			c.AddModule(arg.Interface())
+			c.addModule(arg.Interface(), id)
 		}
 		return nil
 	})
@@ -418,7 +582,16 @@ func (c *C) AddModuleFunc(constructor any) {
 }
 
 // ApplyRootCommand iterates through every CommandProvider registered in the container,
-// and introduce the root *cobra.Command to everyone.
+// and introduce the root *cobra.Command to everyone. The di command, which renders
+// the dependency graph of the container, is added as well, and so are the
+// persistent --config and --set flags, which override the configuration of every
+// command:
+//
+//	app serve --config ./config/local.yaml --set log.level=warn --set http.disable=true
+//
+// The flags in os.Args are applied when the core is created, so they configure
+// the logger and every module as well. See WithConfigFlags. Subcommands with
+// their own persistent pre-run hook should be added before ApplyRootCommand.
 func (c *C) ApplyRootCommand(command *cobra.Command) {
 	modules := c.Modules()
 	for i := range modules {
@@ -426,6 +599,8 @@ func (c *C) ApplyRootCommand(command *cobra.Command) {
 			p.ProvideCommand(command)
 		}
 	}
+	command.AddCommand(newDICmd(c))
+	c.applyConfigFlags(command)
 }
 
 // Invoke runs the given function after instantiating its dependencies. Any
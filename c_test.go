@@ -2,13 +2,16 @@ package core
 
 import (
 	"context"
+	"errors"
 	"io/ioutil"
 	"os"
+	"path/filepath"
 	"strings"
 	"sync/atomic"
 	"testing"
 	"time"
 
+	"github.com/DoNewsCode/core/codec/yaml"
 	"github.com/DoNewsCode/core/config"
 	"github.com/DoNewsCode/core/contract/lifecycle"
 	"github.com/DoNewsCode/core/di"
@@ -16,6 +19,7 @@ import (
 	"github.com/DoNewsCode/core/srvgrpc"
 	"github.com/DoNewsCode/core/srvhttp"
 
+	"github.com/knadh/koanf/providers/file"
 	"github.com/spf13/cobra"
 	"github.com/stretchr/testify/assert"
 )
@@ -272,3 +276,102 @@ func TestC_AddModule(t *testing.T) {
 		})
 	}
 }
+
+func TestC_WithEnvPrefix(t *testing.T) {
+	t.Setenv("APP_HTTP_ADDR", ":8081")
+	t.Setenv("APP_GRPC_ADDR", ":9091")
+	t.Setenv("APP_LOG_LEVEL", "warn")
+
+	f, _ := ioutil.TempFile(t.TempDir(), "*.yaml")
+	f.WriteString("http:\n  addr: :8080\nlog:\n  level: info\n")
+	f.Close()
+
+	c := New(
+		WithEnvPrefix("APP"),
+		WithInline("grpc.addr", ":9090"),
+		WithConfigStack(file.Provider(f.Name()), config.CodecParser{Codec: yaml.Codec{}}),
+	)
+	assert.Equal(t, ":8081", c.conf.String("http.addr"))
+	assert.Equal(t, ":9090", c.conf.String("grpc.addr"))
+	assert.Equal(t, "warn", c.conf.String("log.level"))
+
+	t.Run("inline after file", func(t *testing.T) {
+		f, _ := ioutil.TempFile(t.TempDir(), "*.yaml")
+		f.WriteString("log:\n  level: info\n")
+		f.Close()
+
+		stack, watcher := WithYamlFile(f.Name())
+		c := New(
+			WithEnvPrefix("APP"),
+			stack,
+			watcher,
+			WithInline("http", map[string]any{"addr": ":inline"}),
+		)
+		assert.Equal(t, ":inline", c.conf.String("http.addr"))
+		assert.Equal(t, ":9091", c.conf.String("grpc.addr"))
+	})
+}
+
+func TestC_WithSecretResolver(t *testing.T) {
+	resolver := config.SecretResolverFunc(func(ctx context.Context, ref string) (string, error) {
+		if ref == "broken" {
+			return "", errors.New("vault is sealed")
+		}
+		return "hunter2", nil
+	})
+
+	c := New(WithInline("redis.default.password", "${vault:app}"), WithSecretResolver("vault", resolver))
+	assert.Equal(t, "hunter2", c.conf.String("redis.default.password"))
+
+	assert.PanicsWithError(t, "failed to load config: unable to resolve secrets: failed to resolve ${vault:broken} at redis.default.password: vault is sealed", func() {
+		New(WithInline("redis.default.password", "${vault:broken}"), WithSecretResolver("vault", resolver))
+	})
+}
+
+func TestC_configDump(t *testing.T) {
+	t.Setenv("APP_LOG_FORMAT", "json")
+	path := filepath.Join(t.TempDir(), "config.yaml")
+	os.WriteFile(path, []byte("cron:\n  disable: true\n"), os.ModePerm)
+	stack, watcher := WithYamlFile(path)
+	c := Default(WithEnvPrefix("APP"), WithInline("http.addr", ":8081"), stack, watcher)
+	c.AddModuleFunc(config.New)
+
+	var buf strings.Builder
+	rootCommand := &cobra.Command{}
+	rootCommand.SetOut(&buf)
+	c.ApplyRootCommand(rootCommand)
+	rootCommand.SetArgs([]string{"config", "dump", "--explain", "--set", "grpc.addr=:9091"})
+	assert.NoError(t, rootCommand.Execute())
+
+	assert.Contains(t, buf.String(), "addr: :8081 # inline")
+	assert.Contains(t, buf.String(), "addr: :9091 # flags")
+	assert.Contains(t, buf.String(), "format: json # env APP")
+	assert.Contains(t, buf.String(), "level: debug # default")
+	assert.Contains(t, buf.String(), "disable: true # file "+path)
+}
+
+func TestC_WithYamlDir(t *testing.T) {
+	dir := t.TempDir()
+	os.WriteFile(dir+"/10-base.yaml", []byte("http:\n  addr: :8080\nlog:\n  level: info\n"), os.ModePerm)
+	os.WriteFile(dir+"/20-override.yaml", []byte("http:\n  addr: :8081\n"), os.ModePerm)
+	os.WriteFile(dir+"/notes.txt", []byte("log: [\n"), os.ModePerm)
+
+	c := New(WithYamlDir(dir))
+	assert.Equal(t, ":8081", c.conf.String("http.addr"))
+	assert.Equal(t, "info", c.conf.String("log.level"))
+}
+
+func TestC_WithYamlOverlays(t *testing.T) {
+	t.Setenv("APP_ENV", "staging")
+	dir := t.TempDir()
+	os.WriteFile(dir+"/config.yaml", []byte("env: production\nhttp:\n  addr: :8080\nlog:\n  level: info\n"), os.ModePerm)
+	os.WriteFile(dir+"/config.production.yaml", []byte("http:\n  addr: :80\n"), os.ModePerm)
+	os.WriteFile(dir+"/config.staging.yaml", []byte("http:\n  addr: :81\n"), os.ModePerm)
+	os.WriteFile(dir+"/config.local.yaml", []byte("log:\n  level: debug\n"), os.ModePerm)
+
+	stack, watcher := WithYamlOverlays(dir + "/config.yaml")
+	c := New(WithEnvPrefix("APP"), stack, watcher)
+	assert.Equal(t, config.EnvStaging, c.env)
+	assert.Equal(t, ":81", c.conf.String("http.addr"))
+	assert.Equal(t, "debug", c.conf.String("log.level"))
+}
@@ -0,0 +1,230 @@
+package core
+
+import (
+	"context"
+	"fmt"
+	"path"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/DoNewsCode/core/config"
+)
+
+const defaultCloserTimeout = 10 * time.Second
+
+// closeFunc calls every CloserProvider registered in the core. It returns once
+// all closers are done, or the context expires.
+type closeFunc func(ctx context.Context) error
+
+// closerTask is a closer along with the closers that must finish before it
+// starts.
+type closerTask struct {
+	name    string
+	origin  int
+	closer  any
+	after   []*closerTask
+	done    chan struct{}
+	err     error
+	elapsed time.Duration
+}
+
+// close calls the closers in the reversed order of registration. Closers of
+// modules that do not depend on each other are called in parallel. Two modules
+// are unrelated if they were created by constructors that do not depend on
+// each other, directly or through other constructors. Modules added with
+// AddModule are always closed in order.
+//
+// Each closer is given serve.closerTimeout to return. A closer that is late is
+// abandoned, and the closers waiting on it proceed.
+func (c *C) close(ctx context.Context) (err error) {
+	c.closeOnce.Do(func() {
+		timeout := defaultCloserTimeout
+		var d config.Duration
+		if e := c.conf.Unmarshal("serve.closerTimeout", &d); e == nil && d.Duration > 0 {
+			timeout = d.Duration
+		}
+
+		tasks := c.closerTasks()
+		if len(tasks) == 0 {
+			return
+		}
+		start := time.Now()
+		var (
+			wg sync.WaitGroup
+			// mu guards the results of the tasks, which are not reported
+			// anymore once the summary is being written.
+			mu       sync.Mutex
+			reported bool
+		)
+		finish := func(task *closerTask, err error, elapsed time.Duration) {
+			mu.Lock()
+			defer mu.Unlock()
+			if !reported {
+				task.err, task.elapsed = err, elapsed
+			}
+		}
+		for _, task := range tasks {
+			wg.Add(1)
+			go func(task *closerTask) {
+				defer wg.Done()
+				defer close(task.done)
+				for _, t := range task.after {
+					select {
+					case <-t.done:
+					case <-ctx.Done():
+						finish(task, fmt.Errorf("skipped: %w", ctx.Err()), 0)
+						return
+					}
+				}
+				begin := time.Now()
+				err := callCloser(ctx, timeout, task.closer)
+				finish(task, err, time.Since(begin))
+			}(task)
+		}
+		done := make(chan struct{})
+		go func() {
+			wg.Wait()
+			close(done)
+		}()
+		select {
+		case <-done:
+		case <-ctx.Done():
+		}
+
+		var (
+			summary []string
+			failed  []string
+		)
+		mu.Lock()
+		defer mu.Unlock()
+		reported = true
+		for _, task := range tasks {
+			select {
+			case <-task.done:
+			default:
+				task.err = fmt.Errorf("did not return before deadline: %w", ctx.Err())
+				task.elapsed = time.Since(start)
+			}
+			if task.err != nil {
+				summary = append(summary, fmt.Sprintf("%s %s (%s)", task.name, task.elapsed.Round(time.Millisecond), task.err))
+				failed = append(failed, fmt.Sprintf("%s: %s", task.name, task.err))
+				continue
+			}
+			summary = append(summary, fmt.Sprintf("%s %s", task.name, task.elapsed.Round(time.Millisecond)))
+		}
+		if len(failed) > 0 {
+			c.logger.Warnf("%d closers done in %s, %d failed: %s", len(tasks), time.Since(start).Round(time.Millisecond), len(failed), strings.Join(summary, ", "))
+			err = fmt.Errorf("failed to close: %s", strings.Join(failed, "; "))
+			return
+		}
+		c.logger.Infof("%d closers done in %s: %s", len(tasks), time.Since(start).Round(time.Millisecond), strings.Join(summary, ", "))
+	})
+	return err
+}
+
+// callCloser calls the closer with a deadline. It returns when the closer
+// returns or the deadline is exceeded, whichever comes first.
+func callCloser(ctx context.Context, timeout time.Duration, closer any) error {
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+
+	errs := make(chan error, 1)
+	go func() {
+		defer func() {
+			if r := recover(); r != nil {
+				errs <- fmt.Errorf("panic: %v", r)
+			}
+		}()
+		if p, ok := closer.(ContextCloserProvider); ok {
+			errs <- p.ProvideCloserContext(ctx)
+			return
+		}
+		closer.(CloserProvider).ProvideCloser()
+		errs <- nil
+	}()
+	select {
+	case err := <-errs:
+		return err
+	case <-ctx.Done():
+		return fmt.Errorf("did not return before deadline: %w", ctx.Err())
+	}
+}
+
+// closerTasks collects the closers in the reversed order of registration, and
+// lets each of them wait for the related closers registered after it.
+func (c *C) closerTasks() []*closerTask {
+	var tasks []*closerTask
+	modules := c.Modules()
+	for i := len(modules) - 1; i >= 0; i-- {
+		_, ok1 := modules[i].(ContextCloserProvider)
+		_, ok2 := modules[i].(CloserProvider)
+		if !ok1 && !ok2 {
+			continue
+		}
+		origin, ok := c.origins[i]
+		if !ok {
+			origin = -1
+		}
+		task := &closerTask{
+			name:   fmt.Sprintf("%T", modules[i]),
+			origin: origin,
+			closer: modules[i],
+			done:   make(chan struct{}),
+		}
+		if origin >= 0 {
+			ctor := c.constructors[origin]
+			task.name = path.Base(ctor.Package) + "." + ctor.Function
+		}
+		tasks = append(tasks, task)
+	}
+
+	dependsOn := c.constructorDependencies()
+	related := func(a, b int) bool {
+		return a < 0 || b < 0 || a == b || dependsOn[a][b] || dependsOn[b][a]
+	}
+	for i, task := range tasks {
+		for _, previous := range tasks[:i] {
+			if related(task.origin, previous.origin) {
+				task.after = append(task.after, previous)
+			}
+		}
+	}
+	return tasks
+}
+
+// constructorDependencies returns the transitive dependencies of every
+// constructor, by ID.
+func (c *C) constructorDependencies() map[int]map[int]bool {
+	providers := make(map[string][]int)
+	for _, ctor := range c.constructors {
+		for _, result := range ctor.Results {
+			providers[result.String()] = append(providers[result.String()], ctor.ID)
+		}
+	}
+	deps := make(map[int]map[int]bool)
+	var visit func(id int) map[int]bool
+	visit = func(id int) map[int]bool {
+		if d, ok := deps[id]; ok {
+			return d
+		}
+		d := make(map[int]bool)
+		deps[id] = d
+		for _, param := range c.constructors[id].Params {
+			for _, p := range providers[param.String()] {
+				if d[p] {
+					continue
+				}
+				d[p] = true
+				for q := range visit(p) {
+					d[q] = true
+				}
+			}
+		}
+		return d
+	}
+	for _, ctor := range c.constructors {
+		visit(ctor.ID)
+	}
+	return deps
+}
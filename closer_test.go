@@ -0,0 +1,98 @@
+package core
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/DoNewsCode/core/di"
+
+	"github.com/stretchr/testify/assert"
+)
+
+type contextCloser func(ctx context.Context) error
+
+func (f contextCloser) ProvideCloserContext(ctx context.Context) error {
+	return f(ctx)
+}
+
+type closerA struct{}
+
+type closerB struct{}
+
+type closerC struct{}
+
+func TestC_ShutdownContext(t *testing.T) {
+	t.Run("unrelated closers run in parallel", func(t *testing.T) {
+		var wg sync.WaitGroup
+		wg.Add(2)
+		barrier := func() {
+			wg.Done()
+			wg.Wait()
+		}
+		c := New()
+		c.Provide(di.Deps{
+			func() (closerA, func()) { return closerA{}, barrier },
+			func() (closerB, func()) { return closerB{}, barrier },
+		})
+		c.Invoke(func(closerA, closerB) {})
+
+		ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+		defer cancel()
+		assert.NoError(t, c.ShutdownContext(ctx))
+	})
+
+	t.Run("dependents are closed first", func(t *testing.T) {
+		var seq []string
+		c := New()
+		c.Provide(di.Deps{
+			func() (closerA, func()) {
+				return closerA{}, func() {
+					time.Sleep(10 * time.Millisecond)
+					seq = append(seq, "a")
+				}
+			},
+			func(closerA) (closerB, func()) { return closerB{}, func() { seq = append(seq, "b") } },
+			func(closerB) (closerC, func()) { return closerC{}, func() { seq = append(seq, "c") } },
+		})
+		c.Invoke(func(closerC) {})
+
+		assert.NoError(t, c.ShutdownContext(context.Background()))
+		assert.Equal(t, []string{"c", "b", "a"}, seq)
+	})
+
+	t.Run("errors and deadlines are reported", func(t *testing.T) {
+		c := New(WithInline("serve.closerTimeout", "10ms"))
+		c.AddModule(contextCloser(func(ctx context.Context) error {
+			return errors.New("foo")
+		}))
+		c.AddModule(contextCloser(func(ctx context.Context) error {
+			<-ctx.Done()
+			return ctx.Err()
+		}))
+		c.AddModule(closer(func() { select {} }))
+		var called bool
+		c.AddModule(closer(func() { called = true }))
+
+		err := c.ShutdownContext(context.Background())
+		assert.True(t, called)
+		assert.ErrorContains(t, err, "core.closer: did not return before deadline")
+		assert.Equal(t, 2, strings.Count(err.Error(), "context deadline exceeded"))
+		assert.ErrorContains(t, err, "core.contextCloser: foo")
+		assert.NoError(t, c.ShutdownContext(context.Background()))
+	})
+
+	t.Run("the context bounds all closers", func(t *testing.T) {
+		c := New()
+		c.AddModule(closer(func() { select {} }))
+		ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+		defer cancel()
+
+		start := time.Now()
+		assert.Error(t, c.ShutdownContext(ctx))
+		assert.Less(t, time.Since(start), time.Second)
+	})
+}
@@ -0,0 +1,204 @@
+package config
+
+import (
+	"fmt"
+	"reflect"
+	"sync"
+	"sync/atomic"
+
+	"github.com/DoNewsCode/core/contract"
+
+	"github.com/knadh/koanf"
+)
+
+// binder is a binding registered to a KoanfAdapter.
+type binder interface {
+	// prepare unmarshals and validates the value from the new config map.
+	prepare(tree *koanf.Koanf) (any, error)
+	// commit publishes the value prepared from the config map of the generation.
+	commit(generation uint64, value any)
+}
+
+// Binding holds the config at a key path, unmarshalled into a T. See Bind.
+type Binding[T any] struct {
+	conf  contract.ConfigUnmarshaler
+	root  *KoanfAdapter
+	path  string
+	value atomic.Value // *T
+
+	mu         sync.Mutex
+	generation uint64
+	callbacks  map[uint64]func(old, new T)
+	nextID     uint64
+	unbind     func()
+}
+
+// Bind unmarshals the config at the key path into a T, and validates it against
+// the validate tags, see ValidateStruct, and by the Validate() error method if
+// T implements it. The returned Binding always holds the latest value:
+//
+//	type PoolConf struct {
+//		MaxOpenConns int `json:"maxOpenConns"`
+//	}
+//
+//	pool, err := config.Bind[PoolConf](conf, "gorm.default.pool")
+//	pool.Load().MaxOpenConns
+//
+// If conf is a *KoanfAdapter, or an instance routed from it, the value is
+// unmarshalled and validated again on each reload, before the reloaded
+// configuration takes effect. An invalid value fails the reload as a whole, so
+// the binding never holds a value that is out of sync with the configuration.
+// For other implementations of contract.ConfigUnmarshaler, call Binding.Reload
+// in a listener of lifecycle.ConfigReload instead.
+func Bind[T any](conf contract.ConfigUnmarshaler, path string) (*Binding[T], error) {
+	b := &Binding[T]{
+		conf:      conf,
+		path:      path,
+		callbacks: make(map[uint64]func(old, new T)),
+		unbind:    func() {},
+	}
+	k, ok := conf.(*KoanfAdapter)
+	if !ok {
+		if err := b.Reload(); err != nil {
+			return nil, err
+		}
+		return b, nil
+	}
+
+	root := k
+	if k.root != nil {
+		root, b.path = k.root, joinPath(k.prefix, path)
+	}
+	b.root = root
+	root.rwlock.Lock()
+	defer root.rwlock.Unlock()
+
+	value, err := b.prepare(root.K)
+	if err != nil {
+		return nil, err
+	}
+	b.commit(root.generation, value)
+	if root.binders == nil {
+		root.binders = make(map[uint64]binder)
+	}
+	id := root.nextBinder
+	root.nextBinder++
+	root.binders[id] = b
+	b.unbind = func() {
+		root.rwlock.Lock()
+		defer root.rwlock.Unlock()
+
+		delete(root.binders, id)
+	}
+	return b, nil
+}
+
+// Load returns the current value.
+func (b *Binding[T]) Load() T {
+	return *b.value.Load().(*T)
+}
+
+// OnChange registers a callback that is called with the old and the new value
+// whenever a reload changes the value. The callbacks are called in no
+// particular order. It returns a function to unregister the callback.
+func (b *Binding[T]) OnChange(callback func(old, new T)) (unsubscribe func()) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	id := b.nextID
+	b.nextID++
+	b.callbacks[id] = callback
+	return func() {
+		b.mu.Lock()
+		defer b.mu.Unlock()
+
+		delete(b.callbacks, id)
+	}
+}
+
+// Reload unmarshals and validates the value again. If it fails, the current
+// value is kept.
+func (b *Binding[T]) Reload() error {
+	if b.root != nil {
+		b.root.rwlock.RLock()
+		tree, generation := b.root.K, b.root.generation
+		b.root.rwlock.RUnlock()
+
+		value, err := b.prepare(tree)
+		if err != nil {
+			return err
+		}
+		b.commit(generation, value)
+		return nil
+	}
+
+	var value T
+	if err := b.conf.Unmarshal(b.path, &value); err != nil {
+		return fmt.Errorf("failed to bind %s: %w", b.path, err)
+	}
+	if err := validateBound(b.path, &value); err != nil {
+		return err
+	}
+	b.mu.Lock()
+	generation := b.generation + 1
+	b.mu.Unlock()
+	b.commit(generation, &value)
+	return nil
+}
+
+// Close stops updating the value on reloads.
+func (b *Binding[T]) Close() {
+	b.unbind()
+}
+
+func (b *Binding[T]) prepare(tree *koanf.Koanf) (any, error) {
+	var value T
+	if err := unmarshal(tree, b.path, &value); err != nil {
+		return nil, fmt.Errorf("failed to bind %s: %w", b.path, err)
+	}
+	if err := validateBound(b.path, &value); err != nil {
+		return nil, err
+	}
+	return &value, nil
+}
+
+func (b *Binding[T]) commit(generation uint64, value any) {
+	b.mu.Lock()
+	// Reloads may finish out of order.
+	if generation < b.generation {
+		b.mu.Unlock()
+		return
+	}
+	b.generation = generation
+	old, _ := b.value.Load().(*T)
+	b.value.Store(value)
+	callbacks := make([]func(old, new T), 0, len(b.callbacks))
+	for _, callback := range b.callbacks {
+		callbacks = append(callbacks, callback)
+	}
+	b.mu.Unlock()
+
+	if old == nil || reflect.DeepEqual(*old, *value.(*T)) {
+		return
+	}
+	for _, callback := range callbacks {
+		callback(*old, *value.(*T))
+	}
+}
+
+// validateBound validates the pointer to the bound value against the validate
+// tags, and then by the Validate method, if any. The method set of the pointer
+// includes the methods of the value.
+func validateBound(path string, value any) error {
+	if err := ValidateStruct(path, value); err != nil {
+		return err
+	}
+	v, ok := value.(interface{ Validate() error })
+	if !ok {
+		return nil
+	}
+	if err := v.Validate(); err != nil {
+		return fmt.Errorf("invalid config at %s: %w", path, err)
+	}
+	return nil
+}
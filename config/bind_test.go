@@ -0,0 +1,118 @@
+package config
+
+import (
+	"errors"
+	"sync"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+type boundPool struct {
+	MaxOpenConns int      `json:"maxOpenConns"`
+	Idle         Duration `json:"idle"`
+}
+
+func (p boundPool) Validate() error {
+	if p.MaxOpenConns < 0 {
+		return errors.New("maxOpenConns must not be negative")
+	}
+	return nil
+}
+
+func TestBind(t *testing.T) {
+	conns := 10
+	provider := readerFunc(func() (map[string]any, error) {
+		return map[string]any{
+			"db": map[string]any{"pool": map[string]any{"maxOpenConns": conns, "idle": "1s"}},
+		}, nil
+	})
+	conf, err := NewConfig(WithProviderLayer(provider, nil))
+	assert.NoError(t, err)
+
+	pool, err := Bind[boundPool](conf, "db.pool")
+	assert.NoError(t, err)
+	routed, err := Bind[int](conf.Route("db"), "pool.maxOpenConns")
+	assert.NoError(t, err)
+	assert.Equal(t, 10, pool.Load().MaxOpenConns)
+	assert.Equal(t, 10, routed.Load())
+
+	var changes [][2]int
+	unsubscribe := pool.OnChange(func(old, new boundPool) {
+		changes = append(changes, [2]int{old.MaxOpenConns, new.MaxOpenConns})
+	})
+
+	conns = 20
+	assert.NoError(t, conf.Reload())
+	assert.Equal(t, 20, pool.Load().MaxOpenConns)
+	assert.Equal(t, 20, routed.Load())
+
+	// Unchanged values are not reported.
+	assert.NoError(t, conf.Reload())
+
+	conns = -1
+	assert.ErrorContains(t, conf.Reload(), "invalid config at db.pool: maxOpenConns must not be negative")
+	assert.Equal(t, 20, pool.Load().MaxOpenConns)
+	assert.Equal(t, 20, conf.Int("db.pool.maxOpenConns"))
+
+	unsubscribe()
+	routed.Close()
+	conns = 30
+	assert.NoError(t, conf.Reload())
+	assert.Equal(t, 30, pool.Load().MaxOpenConns)
+	assert.Equal(t, 20, routed.Load())
+	assert.Equal(t, [][2]int{{10, 20}}, changes)
+
+	_, err = Bind[boundPool](conf, "db")
+	assert.ErrorContains(t, err, "failed to bind db")
+}
+
+func TestBind_unmarshaler(t *testing.T) {
+	conf := MapAdapter{"pool": map[string]any{"maxOpenConns": 5}}
+	pool, err := Bind[boundPool](conf, "pool")
+	assert.NoError(t, err)
+	assert.Equal(t, 5, pool.Load().MaxOpenConns)
+
+	conf["pool"] = map[string]any{"maxOpenConns": -1}
+	assert.Error(t, pool.Reload())
+	assert.Equal(t, 5, pool.Load().MaxOpenConns)
+
+	_, err = Bind[validatePool](conf, "pool")
+	assert.EqualError(t, err, "pool.maxOpenConns does not satisfy min=0")
+}
+
+func TestBind_race(t *testing.T) {
+	conf, err := NewConfig(WithProviderLayer(readerFunc(func() (map[string]any, error) {
+		return map[string]any{"foo": "bar"}, nil
+	}), nil))
+	assert.NoError(t, err)
+	foo, err := Bind[string](conf, "foo")
+	assert.NoError(t, err)
+
+	var wg sync.WaitGroup
+	for i := 0; i < 10; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			assert.NoError(t, conf.Reload())
+			assert.Equal(t, "bar", foo.Load())
+		}()
+	}
+	wg.Wait()
+}
+
+func TestKoanfAdapter_Route_reload(t *testing.T) {
+	value := "bar"
+	conf, err := NewConfig(WithProviderLayer(readerFunc(func() (map[string]any, error) {
+		return map[string]any{"foo": map[string]any{"bar": map[string]any{"baz": value}}}, nil
+	}), nil))
+	assert.NoError(t, err)
+
+	routed := conf.Route("foo")
+	nested := routed.(*KoanfAdapter).Route("bar")
+	assert.Equal(t, "bar", routed.String("bar.baz"))
+	value = "qux"
+	assert.NoError(t, conf.Reload())
+	assert.Equal(t, "qux", routed.String("bar.baz"))
+	assert.Equal(t, "qux", nested.String("baz"))
+}
@@ -9,7 +9,9 @@ import (
 
 	"github.com/DoNewsCode/core/contract"
 	"github.com/DoNewsCode/core/contract/lifecycle"
+	"github.com/DoNewsCode/core/logging"
 
+	"github.com/go-kit/log"
 	"github.com/knadh/koanf"
 	"github.com/knadh/koanf/providers/confmap"
 	"github.com/mitchellh/mapstructure"
@@ -17,19 +19,33 @@ import (
 
 // KoanfAdapter is an implementation of contract.ConfigUnmarshaler based on Koanf (https://github.com/knadh/koanf).
 type KoanfAdapter struct {
-	layers     []ProviderSet
-	validators []Validator
-	watcher    contract.ConfigWatcher
-	dispatcher lifecycle.ConfigReload
-	delimiter  string
-	rwlock     sync.RWMutex
-	K          *koanf.Koanf
+	layers       []ProviderSet
+	validators   []Validator
+	watcher      contract.ConfigWatcher
+	dispatcher   lifecycle.ConfigReload
+	delimiter    string
+	resolvers    map[string]SecretResolver
+	secrets      *secrets
+	tolerant     bool
+	logger       log.Logger
+	reloadFailed lifecycle.ConfigReloadFailed
+	metrics      *ReloadMetrics
+	binders      map[uint64]binder
+	nextBinder   uint64
+	generation   uint64
+	root         *KoanfAdapter
+	prefix       string
+	rwlock       sync.RWMutex
+	reloadMu     sync.Mutex
+	K            *koanf.Koanf
 }
 
 // ProviderSet is a configuration layer formed by a parser and a provider.
 type ProviderSet struct {
 	Parser   koanf.Parser
 	Provider koanf.Provider
+	// Name describes the layer in the output of config dump --explain. Optional.
+	Name string
 }
 
 // Option is the functional option type for KoanfAdapter
@@ -43,6 +59,14 @@ func WithProviderLayer(provider koanf.Provider, parser koanf.Parser) Option {
 	}
 }
 
+// WithNamedProviderLayer is like WithProviderLayer, but the layer is given a
+// name to be reported by Explain.
+func WithNamedProviderLayer(name string, provider koanf.Provider, parser koanf.Parser) Option {
+	return func(option *KoanfAdapter) {
+		option.layers = append(option.layers, ProviderSet{Provider: provider, Parser: parser, Name: name})
+	}
+}
+
 // WithWatcher is an option for *KoanfAdapter that adds a config watcher. The watcher should notify the configurations
 // whenever a reload event is triggered.
 func WithWatcher(watcher contract.ConfigWatcher) Option {
@@ -65,6 +89,41 @@ func WithDispatcher(dispatcher lifecycle.ConfigReload) Option {
 	}
 }
 
+// WithTolerantReload is an option for *KoanfAdapter that keeps the last good
+// configuration when a reload triggered by the watcher fails. The failure is
+// logged, counted and dispatched as a lifecycle.ConfigReloadFailed event, and
+// the watcher keeps watching, unless a listener of the event returns an error.
+// Without this option, the failure is returned by Watch.
+func WithTolerantReload() Option {
+	return func(option *KoanfAdapter) {
+		option.tolerant = true
+	}
+}
+
+// WithLogger is an option for *KoanfAdapter that logs the failed reloads in the
+// tolerant reload mode.
+func WithLogger(logger log.Logger) Option {
+	return func(option *KoanfAdapter) {
+		option.logger = logger
+	}
+}
+
+// WithReloadFailedDispatcher is an option for *KoanfAdapter that dispatches the
+// failed reloads in the tolerant reload mode.
+func WithReloadFailedDispatcher(dispatcher lifecycle.ConfigReloadFailed) Option {
+	return func(option *KoanfAdapter) {
+		option.reloadFailed = dispatcher
+	}
+}
+
+// WithReloadMetrics is an option for *KoanfAdapter that counts the failed
+// reloads in the tolerant reload mode.
+func WithReloadMetrics(metrics *ReloadMetrics) Option {
+	return func(option *KoanfAdapter) {
+		option.metrics = metrics
+	}
+}
+
 // WithValidators changes the validators of Koanf.
 func WithValidators(validators ...Validator) Option {
 	return func(option *KoanfAdapter) {
@@ -72,9 +131,25 @@ func WithValidators(validators ...Validator) Option {
 	}
 }
 
+// WithSecretResolver is an option for *KoanfAdapter that resolves the secret
+// references of the scheme, such as ${vault:kv/data/app#password}, with the
+// resolver. The schemes env and file are resolved by EnvResolver and
+// FileResolver by default.
+func WithSecretResolver(scheme string, resolver SecretResolver) Option {
+	return func(option *KoanfAdapter) {
+		option.resolvers[scheme] = resolver
+	}
+}
+
 // NewConfig creates a new *KoanfAdapter.
 func NewConfig(options ...Option) (*KoanfAdapter, error) {
-	adapter := KoanfAdapter{delimiter: "."}
+	adapter := KoanfAdapter{
+		delimiter: ".",
+		resolvers: map[string]SecretResolver{
+			"env":  EnvResolver,
+			"file": FileResolver,
+		},
+	}
 
 	for _, f := range options {
 		f(&adapter)
@@ -91,13 +166,44 @@ func NewConfig(options ...Option) (*KoanfAdapter, error) {
 
 // Reload reloads the whole configuration stack. It reloads layer by layer, so if
 // an error occurred, Reload will return early and abort the rest of the
-// reloading.
+// reloading. The values of the bindings are validated along with the validators,
+// and updated once the new configuration is in effect. A routed instance
+// reloads its root.
+//
+// Reloads are serialized, so that the changes dispatched by each reload are
+// relative to the configuration of the previous one. The ConfigReload listeners
+// are called within the reload, and must not call Reload themselves.
 func (k *KoanfAdapter) Reload() error {
-	tmp := koanf.New(".")
+	if k.root != nil {
+		return k.root.Reload()
+	}
 
-	for i := len(k.layers) - 1; i >= 0; i-- {
-		err := tmp.Load(k.layers[i].Provider, k.layers[i].Parser)
-		if err != nil {
+	k.reloadMu.Lock()
+	defer k.reloadMu.Unlock()
+
+	layers, err := expandLayers(k.layers)
+	if err != nil {
+		return err
+	}
+	tmp, err := loadLayers(layers)
+	if err != nil {
+		return err
+	}
+
+	k.rwlock.RLock()
+	resolvers := make(map[string]SecretResolver, len(k.resolvers))
+	for scheme, resolver := range k.resolvers {
+		resolvers[scheme] = resolver
+	}
+	k.rwlock.RUnlock()
+	tree := tmp.Raw()
+	secrets, err := resolveSecrets(context.Background(), resolvers, tree)
+	if err != nil {
+		return fmt.Errorf("unable to resolve secrets: %w", err)
+	}
+	if len(secrets.paths) > 0 {
+		tmp = koanf.New(".")
+		if err := tmp.Load(confmap.Provider(tree, ""), nil); err != nil {
 			return fmt.Errorf("unable to load config %w", err)
 		}
 	}
@@ -108,35 +214,240 @@ func (k *KoanfAdapter) Reload() error {
 		}
 	}
 
+	k.rwlock.RLock()
+	binders := make(map[uint64]binder, len(k.binders))
+	for id, b := range k.binders {
+		binders[id] = b
+	}
+	k.rwlock.RUnlock()
+	values := make(map[uint64]any, len(binders))
+	for id, b := range binders {
+		value, err := b.prepare(tmp)
+		if err != nil {
+			return fmt.Errorf("validation failed: %w", err)
+		}
+		values[id] = value
+	}
+
 	k.rwlock.Lock()
+	old, oldSecrets := k.K, k.secrets
 	k.K = tmp
+	k.secrets = secrets
+	k.generation++
+	generation := k.generation
 	k.rwlock.Unlock()
 
+	for id, b := range binders {
+		b.commit(generation, values[id])
+	}
+
 	if k.dispatcher != nil {
-		k.dispatcher.Fire(context.Background(), k)
+		k.dispatcher.Fire(context.Background(), lifecycle.ConfigReloadPayload{
+			Config:  k,
+			Changes: diff(old, tmp, oldSecrets, secrets),
+		})
 	}
 
 	return nil
 }
 
+// loadLayers loads the layers from the bottom up.
+func loadLayers(layers []ProviderSet) (*koanf.Koanf, error) {
+	tree := koanf.New(".")
+	for i := len(layers) - 1; i >= 0; i-- {
+		provider := layers[i].Provider
+		if m, ok := provider.(keyMatcher); ok {
+			provider = m.withKeys(tree.KeyMap())
+		}
+		if err := tree.Load(provider, layers[i].Parser); err != nil {
+			return nil, fmt.Errorf("unable to load config %w", err)
+		}
+	}
+	return tree, nil
+}
+
+// layerExpander is a provider that forms several layers, which are determined
+// on every reload. The layers may depend on base, which loads the rest of the
+// stack.
+type layerExpander interface {
+	layers(base func() (*koanf.Koanf, error)) ([]ProviderSet, error)
+}
+
+// expandLayers returns the layers of the stack, with the providers that form
+// several layers expanded in place.
+func expandLayers(layers []ProviderSet) ([]ProviderSet, error) {
+	var (
+		base     *koanf.Koanf
+		baseErr  error
+		baseOnce sync.Once
+	)
+	loadBase := func() (*koanf.Koanf, error) {
+		baseOnce.Do(func() {
+			var rest []ProviderSet
+			for _, layer := range layers {
+				if _, ok := layer.Provider.(layerExpander); !ok {
+					rest = append(rest, layer)
+				}
+			}
+			base, baseErr = loadLayers(rest)
+		})
+		return base, baseErr
+	}
+
+	expanded := make([]ProviderSet, 0, len(layers))
+	for _, layer := range layers {
+		e, ok := layer.Provider.(layerExpander)
+		if !ok {
+			expanded = append(expanded, layer)
+			continue
+		}
+		sublayers, err := e.layers(loadBase)
+		if err != nil {
+			return nil, fmt.Errorf("unable to load config %w", err)
+		}
+		expanded = append(expanded, sublayers...)
+	}
+	return expanded, nil
+}
+
+// diff returns the changes of the leaf key paths from the old tree to the new
+// one. The secret values are masked, so that listeners don't see them.
+func diff(old, new *koanf.Koanf, oldSecrets, newSecrets *secrets) lifecycle.ConfigChanges {
+	changes := make(lifecycle.ConfigChanges)
+	oldValues, newValues := old.All(), new.All()
+	for key, value := range oldValues {
+		if newValue, ok := newValues[key]; !ok || !reflect.DeepEqual(value, newValue) {
+			changes[key] = lifecycle.ConfigChange{Old: value, New: newValue}
+		}
+	}
+	for key, value := range newValues {
+		if _, ok := oldValues[key]; !ok {
+			changes[key] = lifecycle.ConfigChange{New: value}
+		}
+	}
+	for key, change := range changes {
+		if change.Old != nil && oldSecrets.isSecret(key) {
+			change.Old = SecretMask
+		}
+		if change.New != nil && newSecrets.isSecret(key) {
+			change.New = SecretMask
+		}
+		changes[key] = change
+	}
+	return changes
+}
+
+// AddSecretResolver resolves the secret references of the scheme with the
+// resolver from the next Reload on. See WithSecretResolver.
+func (k *KoanfAdapter) AddSecretResolver(scheme string, resolver SecretResolver) {
+	k.rwlock.Lock()
+	defer k.rwlock.Unlock()
+
+	if k.resolvers == nil {
+		k.resolvers = make(map[string]SecretResolver)
+	}
+	k.resolvers[scheme] = resolver
+}
+
+// IsSecret reports whether the value at the key path is resolved from a secret
+// reference.
+func (k *KoanfAdapter) IsSecret(path string) bool {
+	if k.root != nil {
+		return k.root.IsSecret(joinPath(k.prefix, path))
+	}
+
+	k.rwlock.RLock()
+	defer k.rwlock.RUnlock()
+
+	return k.secrets.isSecret(path)
+}
+
+// Redact replaces the resolved secrets in the text with SecretMask. Use it
+// before logging anything that may contain a secret, such as a DSN. Secrets
+// shorter than six bytes are only replaced if they make up the whole text.
+func (k *KoanfAdapter) Redact(text string) string {
+	if k.root != nil {
+		return k.root.Redact(text)
+	}
+
+	k.rwlock.RLock()
+	defer k.rwlock.RUnlock()
+
+	return k.secrets.redact(text)
+}
+
+// HasSecrets reports whether any value is resolved from a secret reference.
+func (k *KoanfAdapter) HasSecrets() bool {
+	if k.root != nil {
+		return k.root.HasSecrets()
+	}
+
+	k.rwlock.RLock()
+	defer k.rwlock.RUnlock()
+
+	return k.secrets != nil && len(k.secrets.values) > 0
+}
+
+// RedactedRaw returns the whole config map, with the values resolved from
+// secret references replaced by SecretMask.
+func (k *KoanfAdapter) RedactedRaw() map[string]any {
+	if k.root != nil {
+		tree := k.tree()
+		k.root.rwlock.RLock()
+		defer k.root.rwlock.RUnlock()
+
+		return k.root.secrets.redactTree(k.prefix, tree.Raw()).(map[string]any)
+	}
+
+	k.rwlock.RLock()
+	defer k.rwlock.RUnlock()
+
+	return k.secrets.redactTree("", k.K.Raw()).(map[string]any)
+}
+
 // Watch uses the internal watcher to watch the configuration reload signals.
 // This function should be registered in the run group. If the watcher is nil,
-// this call will block until context expired.
+// this call will block until context expired. In the tolerant reload mode,
+// failed reloads don't stop the watcher, unless a listener of
+// lifecycle.ConfigReloadFailed returns an error. See WithTolerantReload.
 func (k *KoanfAdapter) Watch(ctx context.Context) error {
 	if k.watcher == nil {
 		<-ctx.Done()
 		return ctx.Err()
 	}
-	return k.watcher.Watch(ctx, k.Reload)
+	return k.watcher.Watch(ctx, func() error {
+		err := k.Reload()
+		if err == nil || !k.tolerant {
+			return err
+		}
+		return k.reloadFailure(ctx, err)
+	})
+}
+
+// reloadFailure reports a failed reload. The last good configuration is kept,
+// as Reload only replaces it on success. The error of the ConfigReloadFailed
+// listeners is returned, so that they can stop the watcher.
+func (k *KoanfAdapter) reloadFailure(ctx context.Context, err error) error {
+	if k.logger != nil {
+		logging.WithLevel(k.logger).Errf("failed to reload config, keeping the last good config: %s", k.Redact(err.Error()))
+	}
+	if k.metrics != nil {
+		k.metrics.Fail()
+	}
+	if k.reloadFailed != nil {
+		return k.reloadFailed.Fire(ctx, lifecycle.ConfigReloadFailedPayload{Err: err, Config: k})
+	}
+	return nil
 }
 
 // Unmarshal unmarshals a given key path into the given struct using the mapstructure lib.
 // If no path is specified, the whole map is unmarshalled. `koanf` is the struct field tag used to match field names.
 func (k *KoanfAdapter) Unmarshal(path string, o any) error {
-	k.rwlock.RLock()
-	defer k.rwlock.RUnlock()
+	return unmarshal(k.tree(), path, o)
+}
 
-	return k.K.UnmarshalWithConf(path, o, koanf.UnmarshalConf{
+func unmarshal(tree *koanf.Koanf, path string, o any) error {
+	return tree.UnmarshalWithConf(path, o, koanf.UnmarshalConf{
 		Tag: "json",
 		DecoderConfig: &mapstructure.DecoderConfig{
 			Result:           o,
@@ -153,72 +464,77 @@ func (k *KoanfAdapter) Unmarshal(path string, o any) error {
 // Route cuts the config map at a given key path into a sub map and returns a new contract.ConfigAccessor instance
 // with the cut config map loaded. For instance, if the loaded config has a path that looks like parent.child.sub.a.b,
 // `Route("parent.child")` returns a new contract.ConfigAccessor instance with the config map `sub.a.b` where
-// everything above `parent.child` are cut out.
+// everything above `parent.child` are cut out. The returned instance follows the reloads of k. Its K field
+// holds the cut config map as of the last access, and is cut again on the next access after a reload.
 func (k *KoanfAdapter) Route(s string) contract.ConfigAccessor {
-	k.rwlock.RLock()
-	defer k.rwlock.RUnlock()
+	root, prefix := k, s
+	if k.root != nil {
+		root, prefix = k.root, joinPath(k.prefix, s)
+	}
+	routed := &KoanfAdapter{root: root, prefix: prefix, delimiter: root.delimiter}
+	routed.tree()
+	return routed
+}
+
+// tree returns the current config map. A routed instance cuts the config map of
+// its root again after each reload of the root.
+func (k *KoanfAdapter) tree() *koanf.Koanf {
+	if k.root == nil {
+		k.rwlock.RLock()
+		defer k.rwlock.RUnlock()
 
-	return &KoanfAdapter{
-		K: k.K.Cut(s),
+		return k.K
 	}
+
+	k.root.rwlock.RLock()
+	tree, generation := k.root.K, k.root.generation
+	k.root.rwlock.RUnlock()
+
+	k.rwlock.Lock()
+	defer k.rwlock.Unlock()
+
+	if k.K == nil || k.generation != generation {
+		k.K = tree.Cut(k.prefix)
+		k.generation = generation
+	}
+	return k.K
 }
 
 // String returns the string value of a given key path or "" if the path does not exist or if the value is not a valid string
 func (k *KoanfAdapter) String(s string) string {
-	k.rwlock.RLock()
-	defer k.rwlock.RUnlock()
-
-	return k.K.String(s)
+	return k.tree().String(s)
 }
 
 // Int returns the int value of a given key path or 0 if the path does not exist or if the value is not a valid int.
 func (k *KoanfAdapter) Int(s string) int {
-	k.rwlock.RLock()
-	defer k.rwlock.RUnlock()
-
-	return k.K.Int(s)
+	return k.tree().Int(s)
 }
 
 // Strings returns the []string slice value of a given key path or an empty []string slice if the path does not exist
 // or if the value is not a valid string slice.
 func (k *KoanfAdapter) Strings(s string) []string {
-	k.rwlock.RLock()
-	defer k.rwlock.RUnlock()
-
-	return k.K.Strings(s)
+	return k.tree().Strings(s)
 }
 
 // Bool returns the bool value of a given key path or false if the path does not exist or if the value is not a valid bool representation.
 // Accepted string representations of bool are the ones supported by strconv.ParseBool.
 func (k *KoanfAdapter) Bool(s string) bool {
-	k.rwlock.RLock()
-	defer k.rwlock.RUnlock()
-
-	return k.K.Bool(s)
+	return k.tree().Bool(s)
 }
 
 // Get returns the raw, uncast any value of a given key path in the config map. If the key path does not exist, nil is returned.
 func (k *KoanfAdapter) Get(s string) any {
-	k.rwlock.RLock()
-	defer k.rwlock.RUnlock()
-
-	return k.K.Get(s)
+	return k.tree().Get(s)
 }
 
 // Float64 returns the float64 value of a given key path or 0 if the path does not exist or if the value is not a valid float64.
 func (k *KoanfAdapter) Float64(s string) float64 {
-	k.rwlock.RLock()
-	defer k.rwlock.RUnlock()
-
-	return k.K.Float64(s)
+	return k.tree().Float64(s)
 }
 
 // Duration returns the time.Duration value of a given key path or its zero value if the path does not exist or if the value is not a valid float64.
 func (k *KoanfAdapter) Duration(s string) time.Duration {
-	k.rwlock.RLock()
-	defer k.rwlock.RUnlock()
-
-	return k.K.Duration(s)
+	return k.tree().Duration(s)
 }
 
 // MapAdapter implements ConfigUnmarshaler and ConfigRouter.
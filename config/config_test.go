@@ -1,16 +1,22 @@
 package config
 
 import (
+	"bytes"
 	"context"
 	"errors"
 	"fmt"
 	"io/ioutil"
 	"os"
+	"sync"
 	"testing"
 	"time"
 
 	"github.com/DoNewsCode/core/config/watcher"
+	"github.com/DoNewsCode/core/contract/lifecycle"
+	"github.com/DoNewsCode/core/events"
 
+	"github.com/go-kit/kit/metrics/generic"
+	"github.com/go-kit/log"
 	"github.com/knadh/koanf"
 	"github.com/knadh/koanf/parsers/json"
 	"github.com/knadh/koanf/parsers/yaml"
@@ -23,6 +29,7 @@ func TestKoanfAdapter_Route(t *testing.T) {
 	ka := prepareJSONTestSubject(t)
 	assert.Implements(t, MapAdapter{}, ka.Route("foo"))
 	assert.Implements(t, MapAdapter{}, ka.Route("foo"))
+	assert.Equal(t, "baz", ka.Route("foo").(*KoanfAdapter).K.String("bar"))
 }
 
 func TestKoanfAdapter_race(t *testing.T) {
@@ -80,6 +87,107 @@ func TestKoanfAdapter_Watch(t *testing.T) {
 		) */
 }
 
+type stepWatcher struct {
+	steps []string
+	value *string
+}
+
+func (s stepWatcher) Watch(ctx context.Context, reload func() error) error {
+	for _, step := range s.steps {
+		*s.value = step
+		if err := reload(); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func TestKoanfAdapter_Watch_tolerant(t *testing.T) {
+	value := "good"
+	provider := readerFunc(func() (map[string]any, error) {
+		if value == "broken" {
+			return nil, errors.New("broken")
+		}
+		return map[string]any{"foo": value}, nil
+	})
+	validator := func(data map[string]any) error {
+		if data["foo"] == "bad" {
+			return errors.New("foo must not be bad")
+		}
+		return nil
+	}
+
+	t.Run("strict", func(t *testing.T) {
+		ka, err := NewConfig(
+			WithProviderLayer(provider, nil),
+			WithValidators(validator),
+			WithWatcher(stepWatcher{steps: []string{"bad", "better"}, value: &value}),
+		)
+		assert.NoError(t, err)
+		assert.ErrorContains(t, ka.Watch(context.Background()), "foo must not be bad")
+		assert.Equal(t, "good", ka.String("foo"))
+	})
+
+	t.Run("tolerant", func(t *testing.T) {
+		var (
+			buf      bytes.Buffer
+			failures []error
+		)
+		dispatcher := &events.Event[lifecycle.ConfigReloadFailedPayload]{}
+		dispatcher.On(func(ctx context.Context, payload lifecycle.ConfigReloadFailedPayload) error {
+			assert.Equal(t, "good", payload.Config.(*KoanfAdapter).String("foo"))
+			failures = append(failures, payload.Err)
+			return nil
+		})
+		counter := generic.NewCounter("failures")
+		value = "good"
+		ka, err := NewConfig(
+			WithProviderLayer(provider, nil),
+			WithValidators(validator),
+			WithWatcher(stepWatcher{steps: []string{"bad", "broken", "better"}, value: &value}),
+			WithTolerantReload(),
+			WithLogger(log.NewLogfmtLogger(&buf)),
+			WithReloadFailedDispatcher(dispatcher),
+			WithReloadMetrics(NewReloadMetrics(counter)),
+		)
+		assert.NoError(t, err)
+		assert.NoError(t, ka.Watch(context.Background()))
+		assert.Equal(t, "better", ka.String("foo"))
+		assert.Len(t, failures, 2)
+		assert.Equal(t, 2.0, counter.Value())
+		assert.Contains(t, buf.String(), "failed to reload config, keeping the last good config: validation failed: foo must not be bad")
+		assert.Contains(t, buf.String(), "broken")
+	})
+
+	t.Run("fail fast", func(t *testing.T) {
+		dispatcher := &events.Event[lifecycle.ConfigReloadFailedPayload]{}
+		dispatcher.On(func(ctx context.Context, payload lifecycle.ConfigReloadFailedPayload) error {
+			return fmt.Errorf("fatal: %w", payload.Err)
+		})
+		value = "good"
+		ka, err := NewConfig(
+			WithProviderLayer(provider, nil),
+			WithValidators(validator),
+			WithWatcher(stepWatcher{steps: []string{"bad", "better"}, value: &value}),
+			WithTolerantReload(),
+			WithReloadFailedDispatcher(dispatcher),
+		)
+		assert.NoError(t, err)
+		assert.ErrorContains(t, ka.Watch(context.Background()), "fatal: validation failed: foo must not be bad")
+		assert.Equal(t, "good", ka.String("foo"))
+	})
+}
+
+type readerFunc func() (map[string]any, error)
+
+func (r readerFunc) ReadBytes() ([]byte, error) {
+	return nil, errors.New("not supported")
+}
+
+func (r readerFunc) Read() (map[string]any, error) {
+	return r()
+}
+
 func TestKoanfAdapter_Bool(t *testing.T) {
 	t.Parallel()
 	k := prepareJSONTestSubject(t)
@@ -197,6 +305,91 @@ func TestKoanfAdapter_Reload(t *testing.T) {
 	assert.Nil(t, conf)
 }
 
+func TestKoanfAdapter_Reload_changes(t *testing.T) {
+	t.Parallel()
+	data := map[string]any{
+		"log":   map[string]any{"level": "info"},
+		"redis": map[string]any{"default": map[string]any{"addrs": []any{"a"}}, "cache": map[string]any{"db": 1}},
+	}
+	var payloads []lifecycle.ConfigReloadPayload
+	dispatcher := &events.Event[lifecycle.ConfigReloadPayload]{}
+	dispatcher.On(func(ctx context.Context, payload lifecycle.ConfigReloadPayload) error {
+		payloads = append(payloads, payload)
+		return nil
+	})
+	conf, err := NewConfig(WithDispatcher(dispatcher), WithProviderLayer(readerFunc(func() (map[string]any, error) {
+		return data, nil
+	}), nil))
+	assert.NoError(t, err)
+
+	data = map[string]any{
+		"log":   map[string]any{"level": "debug", "format": "json"},
+		"redis": map[string]any{"default": map[string]any{"addrs": []any{"a"}}},
+	}
+	assert.NoError(t, conf.Reload())
+	assert.NoError(t, conf.Reload())
+
+	assert.Len(t, payloads, 3)
+	assert.Same(t, conf, payloads[1].Config)
+	assert.Equal(t, lifecycle.ConfigChanges{
+		"log.level":      {Old: "info", New: "debug"},
+		"log.format":     {New: "json"},
+		"redis.cache.db": {Old: 1},
+	}, payloads[1].Changes)
+	assert.True(t, payloads[1].Changes.Changed("Redis.Cache"))
+	assert.True(t, payloads[1].Changes.Changed("log"))
+	assert.False(t, payloads[1].Changes.Changed("redis.default"))
+	assert.False(t, payloads[1].Changes.Changed("redis.cach"))
+	assert.Empty(t, payloads[2].Changes)
+	assert.False(t, payloads[2].Changes.Changed("log"))
+	assert.True(t, lifecycle.ConfigChanges(nil).Changed("log"))
+}
+
+func TestKoanfAdapter_Reload_concurrent(t *testing.T) {
+	t.Parallel()
+	var (
+		mu       sync.Mutex
+		reads    int
+		payloads []lifecycle.ConfigReloadPayload
+	)
+	dispatcher := &events.Event[lifecycle.ConfigReloadPayload]{}
+	dispatcher.On(func(ctx context.Context, payload lifecycle.ConfigReloadPayload) error {
+		mu.Lock()
+		defer mu.Unlock()
+		payloads = append(payloads, payload)
+		return nil
+	})
+	conf, err := NewConfig(WithDispatcher(dispatcher), WithProviderLayer(readerFunc(func() (map[string]any, error) {
+		mu.Lock()
+		reads++
+		n := reads
+		mu.Unlock()
+		// Let the even reads finish after the next ones.
+		if n%2 == 0 {
+			time.Sleep(5 * time.Millisecond)
+		}
+		return map[string]any{"n": n}, nil
+	}), nil))
+	assert.NoError(t, err)
+
+	var wg sync.WaitGroup
+	for i := 0; i < 20; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			assert.NoError(t, conf.Reload())
+		}()
+	}
+	wg.Wait()
+
+	// Each reload is relative to the previous one.
+	assert.Len(t, payloads, 21)
+	for i := 2; i < len(payloads); i++ {
+		assert.Equal(t, payloads[i-1].Changes["n"].New, payloads[i].Changes["n"].Old)
+	}
+	assert.Equal(t, 21, conf.Int("n"))
+}
+
 func TestUpgrade(t *testing.T) {
 	var m MapAdapter = map[string]any{"foo": "bar"}
 	upgraded := WithAccessor(m)
@@ -0,0 +1,90 @@
+package config
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/DoNewsCode/core/codec/yaml"
+
+	"github.com/knadh/koanf"
+	"github.com/knadh/koanf/providers/file"
+)
+
+// DirProvider is a koanf.Provider that reads the files matching a pattern in a
+// directory, such as a mounted Kubernetes ConfigMap or a conf.d directory. The
+// files are read in lexical order, and the later files take precedence, so
+// 99-override.yaml overrides 10-base.yaml. Hidden files, including the ..data
+// entries of Kubernetes, are skipped.
+//
+// When used as a layer of KoanfAdapter, each file forms a separate layer, named
+// after its path in the output of config dump --explain. The directory is listed
+// again on every reload, so added and removed files are picked up.
+type DirProvider struct {
+	// Path is the directory.
+	Path string
+	// Pattern is the glob pattern of the file names. Defaults to "*.yaml".
+	Pattern string
+	// Parser parses the files. Defaults to the YAML parser.
+	Parser koanf.Parser
+}
+
+// ReadBytes is not supported by DirProvider.
+func (d DirProvider) ReadBytes() ([]byte, error) {
+	return nil, errors.New("dir provider does not support this method")
+}
+
+// Read returns the files merged in lexical order.
+func (d DirProvider) Read() (map[string]any, error) {
+	layers, err := d.layers(nil)
+	if err != nil {
+		return nil, err
+	}
+	merged, err := loadLayers(layers)
+	if err != nil {
+		return nil, err
+	}
+	return merged.Raw(), nil
+}
+
+// layers returns a layer for each file, the last file on top.
+func (d DirProvider) layers(func() (*koanf.Koanf, error)) ([]ProviderSet, error) {
+	pattern, parser := d.Pattern, d.Parser
+	if pattern == "" {
+		pattern = "*.yaml"
+	}
+	if parser == nil {
+		parser = CodecParser{Codec: yaml.Codec{}}
+	}
+	entries, err := os.ReadDir(d.Path)
+	if err != nil {
+		return nil, err
+	}
+	var names []string
+	for _, entry := range entries {
+		name := entry.Name()
+		if strings.HasPrefix(name, ".") {
+			continue
+		}
+		if ok, err := filepath.Match(pattern, name); err != nil {
+			return nil, err
+		} else if !ok {
+			continue
+		}
+		// Follow symlinks, such as the ones in a Kubernetes ConfigMap.
+		if info, err := os.Stat(filepath.Join(d.Path, name)); err != nil || info.IsDir() {
+			continue
+		}
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	layers := make([]ProviderSet, 0, len(names))
+	for i := len(names) - 1; i >= 0; i-- {
+		path := filepath.Join(d.Path, names[i])
+		layers = append(layers, ProviderSet{Provider: file.Provider(path), Parser: parser, Name: "file " + path})
+	}
+	return layers, nil
+}
@@ -0,0 +1,44 @@
+package config
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestDirProvider(t *testing.T) {
+	dir := t.TempDir()
+	write := func(name, content string) {
+		assert.NoError(t, os.WriteFile(filepath.Join(dir, name), []byte(content), os.ModePerm))
+	}
+	write("10-base.yaml", "http:\n  addr: :8080\nlog:\n  level: info\n")
+	write("20-override.yaml", "http:\n  addr: :8081\n")
+	write(".hidden.yaml", "http:\n  addr: :8082\n")
+	write("notes.txt", "log: [\n")
+
+	conf, err := NewConfig(WithProviderLayer(DirProvider{Path: dir}, nil))
+	assert.NoError(t, err)
+	assert.Equal(t, ":8081", conf.String("http.addr"))
+	assert.Equal(t, "info", conf.String("log.level"))
+
+	sources, err := conf.Explain()
+	assert.NoError(t, err)
+	assert.Equal(t, "file "+filepath.Join(dir, "20-override.yaml"), sources["http.addr"])
+	assert.Equal(t, "file "+filepath.Join(dir, "10-base.yaml"), sources["log.level"])
+
+	// Added and removed files are picked up on reload.
+	write("30-local.yaml", "log:\n  level: debug\n")
+	assert.NoError(t, os.Remove(filepath.Join(dir, "20-override.yaml")))
+	assert.NoError(t, conf.Reload())
+	assert.Equal(t, ":8080", conf.String("http.addr"))
+	assert.Equal(t, "debug", conf.String("log.level"))
+
+	merged, err := DirProvider{Path: dir}.Read()
+	assert.NoError(t, err)
+	assert.Equal(t, map[string]any{"http": map[string]any{"addr": ":8080"}, "log": map[string]any{"level": "debug"}}, merged)
+
+	_, err = NewConfig(WithProviderLayer(DirProvider{Path: filepath.Join(dir, "missing")}, nil))
+	assert.Error(t, err)
+}
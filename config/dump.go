@@ -0,0 +1,172 @@
+package config
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"regexp"
+	"sort"
+
+	"github.com/knadh/koanf"
+	"github.com/knadh/koanf/providers/confmap"
+	"gopkg.in/yaml.v3"
+)
+
+// secretKey matches the keys whose values are masked in config dump, even if
+// they are not resolved from secret references.
+var secretKey = regexp.MustCompile(`(?i)(password|passwd|secret|token|credential|privatekey|private_key|accesskey|access_key|apikey|api_key|dsn)`)
+
+// Explain returns the name of the layer each leaf key path comes from. Every
+// layer is read again, so that the result reflects the current state of the
+// providers. Layers without a name are described by their provider.
+func (k *KoanfAdapter) Explain() (map[string]string, error) {
+	sources := make(map[string]string)
+	merged := koanf.New(".")
+	layers, err := expandLayers(k.layers)
+	if err != nil {
+		return nil, err
+	}
+	for i := len(layers) - 1; i >= 0; i-- {
+		provider := layers[i].Provider
+		if m, ok := provider.(keyMatcher); ok {
+			provider = m.withKeys(merged.KeyMap())
+		}
+		layer := koanf.New(".")
+		if err := layer.Load(provider, layers[i].Parser); err != nil {
+			return nil, fmt.Errorf("unable to load config %w", err)
+		}
+		name := describeLayer(layers[i])
+		for _, key := range layer.Keys() {
+			sources[key] = name
+		}
+		if err := merged.Merge(layer); err != nil {
+			return nil, err
+		}
+	}
+	return sources, nil
+}
+
+// describeLayer returns the name of the layer. An unnamed layer is described by
+// the type of its provider. See WithNamedProviderLayer.
+func describeLayer(layer ProviderSet) string {
+	if layer.Name != "" {
+		return layer.Name
+	}
+	switch p := layer.Provider.(type) {
+	case *confmap.Confmap:
+		return "inline"
+	case EnvProvider:
+		return "env " + p.Prefix
+	}
+	return fmt.Sprintf("%T", layer.Provider)
+}
+
+// dumpTree returns the merged config tree, with the secrets and the values of
+// secret-looking keys masked.
+func (k *KoanfAdapter) dumpTree() map[string]any {
+	return maskSecretKeys(k.RedactedRaw())
+}
+
+func maskSecretKeys(tree map[string]any) map[string]any {
+	for key, value := range tree {
+		switch v := value.(type) {
+		case map[string]any:
+			maskSecretKeys(v)
+		case nil:
+		default:
+			if secretKey.MatchString(key) && v != "" {
+				tree[key] = SecretMask
+			}
+		}
+	}
+	return tree
+}
+
+// dumpYAML writes the tree as YAML. If sources is not nil, each leaf is
+// annotated with its source in a line comment.
+func dumpYAML(w io.Writer, tree map[string]any, sources map[string]string) error {
+	encoder := yaml.NewEncoder(w)
+	encoder.SetIndent(2)
+	if sources == nil {
+		if err := encoder.Encode(tree); err != nil {
+			return err
+		}
+		return encoder.Close()
+	}
+	node, err := yamlNode("", tree, sources)
+	if err != nil {
+		return err
+	}
+	if err := encoder.Encode(node); err != nil {
+		return err
+	}
+	return encoder.Close()
+}
+
+func yamlNode(path string, value any, sources map[string]string) (*yaml.Node, error) {
+	m, ok := value.(map[string]any)
+	if !ok {
+		var node yaml.Node
+		if err := node.Encode(value); err != nil {
+			return nil, err
+		}
+		if source, ok := sources[path]; ok {
+			node.LineComment = source
+		}
+		return &node, nil
+	}
+	node := &yaml.Node{Kind: yaml.MappingNode}
+	for _, key := range sortedKeys(m) {
+		child, err := yamlNode(joinPath(path, key), m[key], sources)
+		if err != nil {
+			return nil, err
+		}
+		keyNode := &yaml.Node{Kind: yaml.ScalarNode, Value: key}
+		// Comments on sequences are not rendered. Annotate the key instead.
+		if child.Kind != yaml.ScalarNode {
+			keyNode.LineComment, child.LineComment = child.LineComment, ""
+		}
+		node.Content = append(node.Content, keyNode, child)
+	}
+	return node, nil
+}
+
+// dumpJSON writes the tree as indented JSON. If sources is not nil, each leaf
+// is replaced by an object with the value and its source.
+func dumpJSON(w io.Writer, tree map[string]any, sources map[string]string) error {
+	var out any = tree
+	if sources != nil {
+		out = explainTree("", tree, sources)
+	}
+	encoder := json.NewEncoder(w)
+	encoder.SetIndent("", "  ")
+	return encoder.Encode(out)
+}
+
+func explainTree(path string, value any, sources map[string]string) any {
+	m, ok := value.(map[string]any)
+	if !ok {
+		return map[string]any{"value": value, "source": sources[path]}
+	}
+	out := make(map[string]any, len(m))
+	for key, v := range m {
+		out[key] = explainTree(joinPath(path, key), v, sources)
+	}
+	return out
+}
+
+func sortedKeys(m map[string]any) []string {
+	keys := make([]string, 0, len(m))
+	for key := range m {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+func joinPath(path, key string) string {
+	if path == "" {
+		return key
+	}
+	return path + "." + key
+}
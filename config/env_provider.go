@@ -0,0 +1,112 @@
+package config
+
+import (
+	"errors"
+	"os"
+	"strings"
+
+	"github.com/knadh/koanf"
+	"github.com/knadh/koanf/maps"
+	"gopkg.in/yaml.v3"
+)
+
+// EnvProvider is a koanf.Provider that reads the environment variables with a
+// prefix. The variable name without the prefix is split into a key path by the
+// separator. With the prefix "APP" and the default separator "_", APP_HTTP_ADDR
+// is mapped to http.addr. Empty segments are dropped, so APP_GORM__DEFAULT__DSN
+// is mapped to gorm.default.dsn. Choose "__" as the separator to keep single
+// underscores in the key names.
+//
+// Environment variables are upper case by convention, while the keys are often
+// camel case. When used as a layer of KoanfAdapter, the key path is matched
+// against the keys of the lower layers case-insensitively, so that
+// APP_GORM__DEFAULT__MAXOPENCONNS overrides gorm.default.maxOpenConns.
+//
+// The values are parsed as YAML scalars or flow sequences, so that "8080" is an
+// int, "true" is a bool and "[a, b]" is a list. Other values are strings.
+type EnvProvider struct {
+	// Prefix is the prefix of the environment variables, without the trailing
+	// separator.
+	Prefix string
+	// Separator separates the segments of the key path. Defaults to "_".
+	Separator string
+
+	keys koanf.KeyMap
+}
+
+// ReadBytes is not supported by EnvProvider.
+func (e EnvProvider) ReadBytes() ([]byte, error) {
+	return nil, errors.New("env provider does not support this method")
+}
+
+// Read returns the environment variables with the prefix as a nested map.
+func (e EnvProvider) Read() (map[string]any, error) {
+	separator := e.Separator
+	if separator == "" {
+		separator = "_"
+	}
+	prefix := strings.ToUpper(e.Prefix) + "_"
+
+	folded := make(map[string]string, len(e.keys))
+	for key := range e.keys {
+		folded[strings.ToLower(key)] = key
+	}
+
+	out := make(map[string]any)
+	for _, kv := range os.Environ() {
+		name, value, _ := strings.Cut(kv, "=")
+		if !strings.HasPrefix(strings.ToUpper(name), prefix) {
+			continue
+		}
+		var segments []string
+		for _, segment := range strings.Split(name[len(prefix):], separator) {
+			if segment != "" {
+				segments = append(segments, strings.ToLower(segment))
+			}
+		}
+		if len(segments) == 0 {
+			continue
+		}
+		out[foldKey(segments, folded)] = parseEnvValue(value)
+	}
+	return maps.Unflatten(out, "."), nil
+}
+
+// withKeys returns a copy of the provider that matches the keys in the key map.
+func (e EnvProvider) withKeys(keys koanf.KeyMap) koanf.Provider {
+	e.keys = keys
+	return e
+}
+
+// foldKey joins the segments into a key path, replacing the longest leading
+// part that matches a known key with the spelling of that key.
+func foldKey(segments []string, folded map[string]string) string {
+	for i := len(segments); i > 0; i-- {
+		if key, ok := folded[strings.Join(segments[:i], ".")]; ok {
+			return strings.Join(append([]string{key}, segments[i:]...), ".")
+		}
+	}
+	return strings.Join(segments, ".")
+}
+
+func parseEnvValue(value string) any {
+	var v any
+	if err := yaml.Unmarshal([]byte(value), &v); err != nil {
+		return value
+	}
+	switch v.(type) {
+	case bool, int, float64:
+		return v
+	case []any:
+		if strings.HasPrefix(strings.TrimSpace(value), "[") {
+			return v
+		}
+	}
+	return value
+}
+
+// keyMatcher is a provider that depends on the keys loaded by the layers below
+// it.
+type keyMatcher interface {
+	withKeys(keys koanf.KeyMap) koanf.Provider
+}
@@ -0,0 +1,44 @@
+package config
+
+import (
+	"testing"
+
+	"github.com/knadh/koanf/providers/confmap"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestEnvProvider(t *testing.T) {
+	t.Setenv("APP_HTTP_ADDR", ":8081")
+	t.Setenv("APP_GORM__DEFAULT__DSN", "root@tcp(127.0.0.1:3306)/app")
+	t.Setenv("APP_GORM__DEFAULT__MAXOPENCONNS", "10")
+	t.Setenv("APP_CRON_DISABLE", "true")
+	t.Setenv("APP_KAFKA_BROKERS", "[a, b]")
+	t.Setenv("APP_LOG_FORMAT", "key: value")
+	t.Setenv("OTHER_HTTP_ADDR", ":8082")
+
+	ka, err := NewConfig(
+		WithProviderLayer(EnvProvider{Prefix: "APP"}, nil),
+		WithProviderLayer(confmap.Provider(map[string]any{
+			"http.addr":                 ":8080",
+			"gorm.default.maxOpenConns": 5,
+			"grpc.addr":                 ":9090",
+		}, "."), nil),
+	)
+	assert.NoError(t, err)
+	assert.Equal(t, ":8081", ka.String("http.addr"))
+	assert.Equal(t, ":9090", ka.String("grpc.addr"))
+	assert.Equal(t, "root@tcp(127.0.0.1:3306)/app", ka.String("gorm.default.dsn"))
+	assert.Equal(t, 10, ka.Get("gorm.default.maxOpenConns"))
+	assert.Nil(t, ka.Get("gorm.default.maxopenconns"))
+	assert.Equal(t, true, ka.Get("cron.disable"))
+	assert.Equal(t, []string{"a", "b"}, ka.Strings("kafka.brokers"))
+	assert.Equal(t, "key: value", ka.Get("log.format"))
+}
+
+func TestEnvProvider_separator(t *testing.T) {
+	t.Setenv("APP_KAFKA__READER__MY_TOPIC__BROKERS", "[a]")
+
+	ka, err := NewConfig(WithProviderLayer(EnvProvider{Prefix: "APP", Separator: "__"}, nil))
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"a"}, ka.Strings("kafka.reader.my_topic.brokers"))
+}
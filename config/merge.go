@@ -0,0 +1,174 @@
+package config
+
+import (
+	"bytes"
+	"fmt"
+	"strings"
+
+	"github.com/pmezard/go-difflib/difflib"
+	"gopkg.in/yaml.v3"
+)
+
+// mergeYAML deep merges the exported configs into the YAML document. Only the
+// missing keys are added, at any depth. Existing values, comments and the order
+// of keys are kept. The new top-level keys are appended in the order of the
+// configs. A config new as a whole is commented with the comment of the config.
+func mergeYAML(document []byte, configs []ExportedConfig) ([]byte, error) {
+	var root yaml.Node
+	if err := yaml.Unmarshal(document, &root); err != nil {
+		return nil, err
+	}
+	if root.Kind == 0 {
+		root = yaml.Node{Kind: yaml.DocumentNode, Content: []*yaml.Node{{Kind: yaml.MappingNode}}}
+	}
+	if root.Kind != yaml.DocumentNode || root.Content[0].Kind != yaml.MappingNode {
+		return nil, fmt.Errorf("the config file must be a mapping")
+	}
+	var (
+		target  = root.Content[0]
+		added   = make(map[string]bool)
+		changes int
+	)
+	for _, config := range configs {
+		var source yaml.Node
+		if err := source.Encode(config.Data); err != nil {
+			return nil, err
+		}
+		keys, n := mergeNode(target, &source)
+		// Comment the config only if it is new as a whole.
+		if len(keys) > 0 && len(keys) == len(source.Content)/2 && config.Comment != "" {
+			keys[0].HeadComment = config.Comment
+		}
+		for _, key := range keys {
+			added[key.Value] = true
+		}
+		changes += n
+	}
+	if changes == 0 {
+		return document, nil
+	}
+
+	var buf bytes.Buffer
+	encoder := yaml.NewEncoder(&buf)
+	encoder.SetIndent(detectIndent(document))
+	if err := encoder.Encode(&root); err != nil {
+		return nil, err
+	}
+	if err := encoder.Close(); err != nil {
+		return nil, err
+	}
+
+	// Blank lines are not kept by yaml.v3. Restore them between the top-level
+	// sections, and separate the new sections.
+	separated := make(map[string]bool)
+	lines := strings.Split(string(document), "\n")
+	for _, block := range topLevelBlocks(lines) {
+		if block.start > 0 && strings.TrimSpace(lines[block.start-1]) == "" {
+			separated[block.key] = true
+		}
+	}
+	lines = strings.Split(buf.String(), "\n")
+	var out []string
+	start := 0
+	for _, block := range topLevelBlocks(lines) {
+		out = append(out, lines[start:block.start]...)
+		if block.start > 0 && (separated[block.key] || added[block.key]) {
+			out = append(out, "")
+		}
+		start = block.start
+	}
+	out = append(out, lines[start:]...)
+	return []byte(strings.Join(out, "\n")), nil
+}
+
+// mergeNode adds the keys missing in the target mapping from the source mapping,
+// and merges the mappings under the same keys. It returns the key nodes added to
+// the target, and the number of keys added at all depths.
+func mergeNode(target, source *yaml.Node) (added []*yaml.Node, n int) {
+	for i := 0; i+1 < len(source.Content); i += 2 {
+		key, value := source.Content[i], source.Content[i+1]
+		existing := lookupNode(target, key.Value)
+		if existing == nil {
+			target.Content = append(target.Content, key, value)
+			added = append(added, key)
+			n++
+			continue
+		}
+		if existing.Kind == yaml.MappingNode && value.Kind == yaml.MappingNode {
+			_, m := mergeNode(existing, value)
+			n += m
+		}
+	}
+	return added, n
+}
+
+func lookupNode(mapping *yaml.Node, key string) *yaml.Node {
+	for i := 0; i+1 < len(mapping.Content); i += 2 {
+		if mapping.Content[i].Value == key {
+			return mapping.Content[i+1]
+		}
+	}
+	return nil
+}
+
+type yamlBlock struct {
+	key   string
+	start int
+}
+
+// topLevelBlocks finds the top-level keys, along with the comments above them.
+func topLevelBlocks(lines []string) []yamlBlock {
+	var (
+		blocks []yamlBlock
+		start  = -1
+	)
+	for i, line := range lines {
+		if line == "" || line[0] == ' ' || line[0] == '\t' || line[0] == '-' {
+			start = -1
+			continue
+		}
+		if line[0] == '#' {
+			if start < 0 {
+				start = i
+			}
+			continue
+		}
+		if start < 0 {
+			start = i
+		}
+		key, _, _ := strings.Cut(line, ":")
+		blocks = append(blocks, yamlBlock{key: strings.Trim(key, `"'`), start: start})
+		start = -1
+	}
+	return blocks
+}
+
+// detectIndent returns the smallest indentation of the document, or 4, which is
+// the indentation of config init.
+func detectIndent(document []byte) int {
+	indent := 0
+	for _, line := range strings.Split(string(document), "\n") {
+		trimmed := strings.TrimLeft(line, " ")
+		if trimmed == "" || trimmed[0] == '#' || trimmed[0] == '-' || len(trimmed) == len(line) {
+			continue
+		}
+		if n := len(line) - len(trimmed); indent == 0 || n < indent {
+			indent = n
+		}
+	}
+	if indent < 2 {
+		return 4
+	}
+	return indent
+}
+
+// unifiedDiff returns the changes from a to b in the unified format.
+func unifiedDiff(path string, a, b []byte) (string, error) {
+	return difflib.GetUnifiedDiffString(difflib.UnifiedDiff{
+		A:        difflib.SplitLines(string(a)),
+		B:        difflib.SplitLines(string(b)),
+		FromFile: path,
+		ToFile:   path + " (merged)",
+		Context:  3,
+	})
+}
@@ -0,0 +1,108 @@
+package config
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/spf13/cobra"
+	"github.com/stretchr/testify/assert"
+)
+
+type mergeRedis struct {
+	Addrs []string `yaml:"addrs"`
+	DB    int      `yaml:"db"`
+}
+
+var mergeConfigs = []ExportedConfig{
+	{
+		Owner: "otredis",
+		Data: map[string]any{
+			"redis":        map[string]mergeRedis{"default": {Addrs: []string{"127.0.0.1:6379"}}},
+			"redisMetrics": map[string]any{"interval": "15s"},
+		},
+		Comment: "The redis configuration",
+	},
+	{
+		Owner:   "core",
+		Data:    map[string]any{"name": "app"},
+		Comment: "The name of the application",
+	},
+}
+
+const mergeDocument = `# The redis configuration
+redis:
+  # The cache
+  cache:
+    addrs: [cache.local] # primary
+  default:
+    db: 1
+
+# The name of the application
+name: foo
+`
+
+func TestMergeYAML(t *testing.T) {
+	merged, err := mergeYAML([]byte(mergeDocument), mergeConfigs)
+	assert.NoError(t, err)
+	assert.Equal(t, `# The redis configuration
+redis:
+  # The cache
+  cache:
+    addrs: [cache.local] # primary
+  default:
+    db: 1
+    addrs:
+      - 127.0.0.1:6379
+
+# The name of the application
+name: foo
+
+redisMetrics:
+  interval: 15s
+`, string(merged))
+
+	again, err := mergeYAML(merged, mergeConfigs)
+	assert.NoError(t, err)
+	assert.Equal(t, string(merged), string(again))
+
+	merged, err = mergeYAML(nil, mergeConfigs[1:])
+	assert.NoError(t, err)
+	assert.Equal(t, "# The name of the application\nname: app\n", string(merged))
+
+	_, err = mergeYAML([]byte("- foo\n"), mergeConfigs)
+	assert.Error(t, err)
+}
+
+func TestModule_ProvideCommand_initCmd_merge(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "config.yaml")
+	assert.NoError(t, os.WriteFile(path, []byte(mergeDocument), os.ModePerm))
+	run := func(args ...string) (string, error) {
+		var buf strings.Builder
+		rootCmd := &cobra.Command{Use: "root"}
+		Module{exportedConfigs: mergeConfigs}.ProvideCommand(rootCmd)
+		rootCmd.SetOut(&buf)
+		rootCmd.SetArgs(append([]string{"config", "init", "--outputFile", path}, args...))
+		err := rootCmd.Execute()
+		return buf.String(), err
+	}
+
+	out, err := run("otredis", "--merge", "--dry-run")
+	assert.NoError(t, err)
+	assert.Contains(t, out, "+    addrs:\n")
+	assert.Contains(t, out, "+redisMetrics:\n")
+	unchanged, _ := os.ReadFile(path)
+	assert.Equal(t, mergeDocument, string(unchanged))
+
+	out, err = run("otredis", "--merge")
+	assert.NoError(t, err)
+	assert.Empty(t, out)
+	merged, _ := os.ReadFile(path)
+	assert.Contains(t, string(merged), "redisMetrics:\n  interval: 15s\n")
+
+	_, err = run("--dry-run")
+	assert.ErrorContains(t, err, "--dry-run requires --merge")
+	_, err = run("--merge", "--style", "json")
+	assert.ErrorContains(t, err, "--merge only supports the yaml style")
+}
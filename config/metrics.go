@@ -0,0 +1,20 @@
+package config
+
+import (
+	"github.com/go-kit/kit/metrics"
+)
+
+// ReloadMetrics counts the failed reloads of the configuration.
+type ReloadMetrics struct {
+	failures metrics.Counter
+}
+
+// NewReloadMetrics constructs a new *ReloadMetrics.
+func NewReloadMetrics(counter metrics.Counter) *ReloadMetrics {
+	return &ReloadMetrics{failures: counter}
+}
+
+// Fail marks a reload as failed.
+func (r *ReloadMetrics) Fail() {
+	r.failures.Add(1)
+}
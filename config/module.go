@@ -2,7 +2,9 @@ package config
 
 import (
 	"context"
+	stdjson "encoding/json"
 	"fmt"
+	"io"
 	"io/ioutil"
 	"os"
 	"path/filepath"
@@ -13,13 +15,18 @@ import (
 	"github.com/DoNewsCode/core/contract/lifecycle"
 	"github.com/DoNewsCode/core/di"
 
+	"github.com/go-kit/log"
 	"github.com/oklog/run"
 	"github.com/pkg/errors"
 	"github.com/spf13/cobra"
 )
 
 // Module is the configuration module that bundles the reload watcher and exportConfig commands.
-// This module triggers ReloadedEvent on configuration change.
+// This module triggers ReloadedEvent on configuration change. By default, a
+// failed reload stops the watcher along with the run group. If
+// config.tolerantReload is true, the watcher runs in the tolerant reload mode
+// instead: the last good configuration is kept, and ConfigReloadFailed is
+// triggered. See WithTolerantReload.
 type Module struct {
 	conf            *KoanfAdapter
 	exportedConfigs []ExportedConfig
@@ -30,8 +37,11 @@ type ConfigIn struct {
 	di.In
 
 	Conf            contract.ConfigAccessor
-	Dispatcher      lifecycle.ConfigReload `optional:"true"`
-	ExportedConfigs []ExportedConfig       `group:"config"`
+	Dispatcher      lifecycle.ConfigReload       `optional:"true"`
+	ReloadFailed    lifecycle.ConfigReloadFailed `optional:"true"`
+	Logger          log.Logger                   `optional:"true"`
+	ReloadMetrics   *ReloadMetrics               `optional:"true"`
+	ExportedConfigs []ExportedConfig             `group:"config"`
 }
 
 // New creates a new config module. It contains the init command.
@@ -51,6 +61,18 @@ func New(p ConfigIn) (Module, error) {
 	if p.Dispatcher != nil {
 		adapter.dispatcher = p.Dispatcher
 	}
+	if adapter.Bool("config.tolerantReload") {
+		adapter.tolerant = true
+	}
+	if p.ReloadFailed != nil {
+		adapter.reloadFailed = p.ReloadFailed
+	}
+	if p.Logger != nil {
+		adapter.logger = p.Logger
+	}
+	if p.ReloadMetrics != nil {
+		adapter.metrics = p.ReloadMetrics
+	}
 
 	return Module{
 		conf:            adapter,
@@ -73,11 +95,17 @@ func (m Module) ProvideCommand(command *cobra.Command) {
 	var (
 		targetFilePath string
 		style          string
+		merge          bool
+		dryRun         bool
+		strict         bool
 	)
 	initCmd := &cobra.Command{
 		Use:   "init [module]",
 		Short: "export a copy of default config.",
-		Long:  "export a default config for currently installed modules.",
+		Long: `export a default config for currently installed modules. By default, a config is
+skipped if any of its top-level keys exists in the file. With --merge, the
+missing keys are added at any depth, keeping the comments and the order of the
+existing keys.`,
 		RunE: func(cmd *cobra.Command, args []string) error {
 			var (
 				handler         handler
@@ -90,20 +118,15 @@ func (m Module) ProvideCommand(command *cobra.Command) {
 			if err != nil {
 				return err
 			}
-			if len(args) == 0 {
-				exportedConfigs = m.exportedConfigs
-			}
-			if len(args) >= 1 {
-				copy := make([]ExportedConfig, 0)
-				for i := range m.exportedConfigs {
-					for j := 0; j < len(args); j++ {
-						if args[j] == m.exportedConfigs[i].Owner {
-							copy = append(copy, m.exportedConfigs[i])
-							break
-						}
-					}
+			exportedConfigs = m.selectConfigs(args)
+			if merge {
+				if style != "yaml" {
+					return fmt.Errorf("--merge only supports the yaml style, got %s", style)
 				}
-				exportedConfigs = copy
+				return mergeFile(cmd.OutOrStdout(), targetFilePath, exportedConfigs, dryRun)
+			}
+			if dryRun {
+				return errors.New("--dry-run requires --merge")
 			}
 			os.MkdirAll(filepath.Dir(targetFilePath), os.ModePerm)
 			targetFile, err = os.OpenFile(targetFilePath,
@@ -128,10 +151,13 @@ func (m Module) ProvideCommand(command *cobra.Command) {
 		},
 	}
 
+	initCmd.Flags().BoolVar(&merge, "merge", false, "deep merge the missing keys into the existing YAML file")
+	initCmd.Flags().BoolVar(&dryRun, "dry-run", false, "print the diff of --merge instead of writing the file")
+
 	verifyCmd := &cobra.Command{
 		Use:   "verify [module]",
 		Short: "verify the config file is correct.",
-		Long:  "verify the config file is correct based on the methods exported by modules.",
+		Long:  "verify the config file is correct based on the schema derived from the exported configs, the validate tags of their types, and the methods exported by modules.",
 		RunE: func(cmd *cobra.Command, args []string) error {
 			var (
 				handler         handler
@@ -144,21 +170,7 @@ func (m Module) ProvideCommand(command *cobra.Command) {
 			if err != nil {
 				return err
 			}
-			if len(args) == 0 {
-				exportedConfigs = m.exportedConfigs
-			}
-			if len(args) >= 1 {
-				copy := make([]ExportedConfig, 0)
-				for i := range m.exportedConfigs {
-					for j := 0; j < len(args); j++ {
-						if args[j] == m.exportedConfigs[i].Owner {
-							copy = append(copy, m.exportedConfigs[i])
-							break
-						}
-					}
-				}
-				exportedConfigs = copy
-			}
+			exportedConfigs = m.selectConfigs(args)
 			os.MkdirAll(filepath.Dir(targetFilePath), os.ModePerm)
 			targetFile, err = os.OpenFile(targetFilePath,
 				handler.flags(), os.ModePerm)
@@ -174,18 +186,77 @@ func (m Module) ProvideCommand(command *cobra.Command) {
 			if err != nil {
 				return errors.Wrap(err, "failed to unmarshal config file")
 			}
+			schema := Schema
+			if strict {
+				schema = StrictSchema
+			}
+			if err := validateSchema(schema(exportedConfigs), confMap); err != nil {
+				return errors.Wrap(err, "invalid config")
+			}
 			for _, config := range exportedConfigs {
-				if config.Validate == nil {
-					continue
-				}
-				if err := config.Validate(confMap); err != nil {
-					return errors.Wrap(err, "invalid config")
+				for _, validate := range validators(config) {
+					if err := validate(confMap); err != nil {
+						return errors.Wrap(err, "invalid config")
+					}
 				}
 			}
 			return nil
 		},
 	}
 
+	schemaCmd := &cobra.Command{
+		Use:   "schema [module]",
+		Short: "export the JSON Schema of config.",
+		Long: `export the JSON Schema of the config for currently installed modules. Editors
+can validate and complete the config file with the schema. For example, save it
+with "config schema > config/schema.json", and then add the following line to
+the top of the YAML config file for yaml-language-server:
+
+  # yaml-language-server: $schema=./schema.json
+
+With --strict, the keys not modeled by the types of the exported configs are
+not allowed.`,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			encoder := stdjson.NewEncoder(cmd.OutOrStdout())
+			encoder.SetIndent("", "  ")
+			if strict {
+				return encoder.Encode(StrictSchema(m.selectConfigs(args)))
+			}
+			return encoder.Encode(Schema(m.selectConfigs(args)))
+		},
+	}
+
+	verifyCmd.Flags().BoolVar(&strict, "strict", false, "reject the keys not modeled by the types of the exported configs")
+	schemaCmd.Flags().BoolVar(&strict, "strict", false, "disallow the keys not modeled by the types of the exported configs")
+
+	var explain bool
+	dumpCmd := &cobra.Command{
+		Use:   "dump",
+		Short: "dump the effective config.",
+		Long:  "dump the config merged from all layers. Secrets and the values of secret-looking keys are masked.",
+		RunE: func(cmd *cobra.Command, args []string) error {
+			var (
+				sources map[string]string
+				err     error
+			)
+			if explain {
+				sources, err = m.conf.Explain()
+				if err != nil {
+					return errors.Wrap(err, "failed to explain config")
+				}
+			}
+			switch style {
+			case "json":
+				return dumpJSON(cmd.OutOrStdout(), m.conf.dumpTree(), sources)
+			case "yaml":
+				return dumpYAML(cmd.OutOrStdout(), m.conf.dumpTree(), sources)
+			default:
+				return fmt.Errorf("unsupported config style %s", style)
+			}
+		},
+	}
+	dumpCmd.Flags().BoolVar(&explain, "explain", false, "annotate each value with the layer it came from")
+
 	configCmd := &cobra.Command{
 		Use:   "config",
 		Short: "manage configuration",
@@ -214,15 +285,58 @@ func (m Module) ProvideCommand(command *cobra.Command) {
 	)
 	configCmd.AddCommand(initCmd)
 	configCmd.AddCommand(verifyCmd)
+	configCmd.AddCommand(dumpCmd)
+	configCmd.AddCommand(schemaCmd)
 	command.AddCommand(configCmd)
 }
 
+// mergeFile deep merges the configs into the YAML file, or prints the diff in
+// the dry run.
+func mergeFile(out io.Writer, path string, configs []ExportedConfig, dryRun bool) error {
+	document, err := os.ReadFile(path)
+	if err != nil && !os.IsNotExist(err) {
+		return errors.Wrap(err, "failed to read config file")
+	}
+	merged, err := mergeYAML(document, configs)
+	if err != nil {
+		return errors.Wrap(err, "failed to merge config file")
+	}
+	if dryRun {
+		diff, err := unifiedDiff(path, document, merged)
+		if err != nil {
+			return err
+		}
+		_, err = io.WriteString(out, diff)
+		return err
+	}
+	os.MkdirAll(filepath.Dir(path), os.ModePerm)
+	if err := os.WriteFile(path, merged, os.ModePerm); err != nil {
+		return errors.Wrap(err, "failed to write config file")
+	}
+	return nil
+}
+
+// selectConfigs returns the configs exported by the owners, or all configs if
+// no owner is given.
+func (m Module) selectConfigs(owners []string) []ExportedConfig {
+	if len(owners) == 0 {
+		return m.exportedConfigs
+	}
+	selected := make([]ExportedConfig, 0)
+	for i := range m.exportedConfigs {
+		for j := 0; j < len(owners); j++ {
+			if owners[j] == m.exportedConfigs[i].Owner {
+				selected = append(selected, m.exportedConfigs[i])
+				break
+			}
+		}
+	}
+	return selected
+}
+
 func loadValidators(k *KoanfAdapter, exportedConfigs []ExportedConfig) error {
 	for _, config := range exportedConfigs {
-		if config.Validate == nil {
-			continue
-		}
-		k.validators = append(k.validators, config.Validate)
+		k.validators = append(k.validators, validators(config)...)
 	}
 	for _, f := range k.validators {
 		if err := f(k.K.Raw()); err != nil {
@@ -232,6 +346,19 @@ func loadValidators(k *KoanfAdapter, exportedConfigs []ExportedConfig) error {
 	return nil
 }
 
+// validators returns the Validate method of the config, along with the
+// validator of the validate tags in the types of its data.
+func validators(config ExportedConfig) []Validator {
+	var validators []Validator
+	if config.Validate != nil {
+		validators = append(validators, config.Validate)
+	}
+	if validate := tagValidator(config.Data); validate != nil {
+		validators = append(validators, validate)
+	}
+	return validators
+}
+
 func getHandler(style string) (handler, error) {
 	switch style {
 	case "json":
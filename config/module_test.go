@@ -4,11 +4,12 @@ import (
 	"context"
 	"io/ioutil"
 	"os"
+	"path/filepath"
 	"runtime"
 	"strings"
 	"testing"
 
-	"github.com/DoNewsCode/core/contract"
+	"github.com/DoNewsCode/core/contract/lifecycle"
 	"github.com/DoNewsCode/core/events"
 
 	"github.com/knadh/koanf/providers/confmap"
@@ -53,65 +54,61 @@ func setup() *cobra.Command {
 	return rootCmd
 }
 
-func tearDown() {
-	os.Remove("./testdata/module_test.yaml")
-	os.Remove("./testdata/module_test.json")
-	ioutil.WriteFile("./testdata/module_test_partial.json", []byte("{\n  \"foo\": \"bar\"\n}"), os.ModePerm)
-	ioutil.WriteFile("./testdata/module_test_partial.yaml", []byte("# A mock config\nfoo: bar\n"), os.ModePerm)
-}
-
 func TestModule_ProvideCommand_initCmd(t *testing.T) {
 	cases := []struct {
 		name     string
-		output   string
+		seed     string
 		args     []string
 		expected string
 	}{
 		{
 			"foo yaml",
-			"./testdata/module_test.yaml",
-			[]string{"config", "init", "foo", "--outputFile", "./testdata/module_test.yaml"},
+			"",
+			[]string{"config", "init", "foo"},
 			"./testdata/module_test_foo_expected.yaml",
 		},
 
 		{
 			"old yaml",
-			"./testdata/module_test.yaml",
-			[]string{"config", "init", "--outputFile", "./testdata/module_test.yaml"},
+			"",
+			[]string{"config", "init"},
 			"./testdata/module_test_expected.yaml",
 		},
 		{
 			"foo json",
-			"./testdata/module_test.json",
-			[]string{"config", "init", "foo", "--outputFile", "./testdata/module_test.json", "--style", "json"},
+			"",
+			[]string{"config", "init", "foo", "--style", "json"},
 			"./testdata/module_test_foo_expected.json",
 		},
 		{
 			"old json",
-			"./testdata/module_test.json",
-			[]string{"config", "init", "--outputFile", "./testdata/module_test.json", "--style", "json"},
+			"",
+			[]string{"config", "init", "--style", "json"},
 			"./testdata/module_test_expected.json",
 		},
 		{
 			"partial json",
-			"./testdata/module_test_partial.json",
-			[]string{"config", "init", "--outputFile", "./testdata/module_test_partial.json", "--style", "json"},
+			"{\n  \"foo\": \"bar\"\n}",
+			[]string{"config", "init", "--style", "json"},
 			"./testdata/module_test_partial_expected.json",
 		},
 		{
 			"partial yaml",
-			"./testdata/module_test_partial.yaml",
-			[]string{"config", "init", "baz", "--outputFile", "./testdata/module_test_partial.yaml"},
+			"# A mock config\nfoo: bar\n",
+			[]string{"config", "init", "baz"},
 			"./testdata/module_test_partial_expected.yaml",
 		},
 	}
 	for _, c := range cases {
 		t.Run(c.name, func(t *testing.T) {
+			output := filepath.Join(t.TempDir(), "module_test"+filepath.Ext(c.expected))
+			if c.seed != "" {
+				assert.NoError(t, ioutil.WriteFile(output, []byte(c.seed), os.ModePerm))
+			}
 			rootCmd := setup()
-			defer tearDown()
-			rootCmd.SetArgs(c.args)
+			rootCmd.SetArgs(append(c.args, "--outputFile", output))
 			rootCmd.Execute()
-			testTarget, _ := ioutil.ReadFile(c.output)
+			testTarget, _ := ioutil.ReadFile(output)
 			expected, _ := ioutil.ReadFile(c.expected)
 			expectedString := string(expected)
 			if runtime.GOOS == "windows" {
@@ -173,9 +170,9 @@ func TestModule_Watch(t *testing.T) {
 		ctx, cancel := context.WithCancel(context.Background())
 		defer cancel()
 
-		dispatcher := &events.Event[contract.ConfigUnmarshaler]{}
-		dispatcher.On(func(ctx context.Context, event contract.ConfigUnmarshaler) error {
-			data := event.(*KoanfAdapter)
+		dispatcher := &events.Event[lifecycle.ConfigReloadPayload]{}
+		dispatcher.On(func(ctx context.Context, payload lifecycle.ConfigReloadPayload) error {
+			data := payload.Config.(*KoanfAdapter)
 			assert.Equal(t, "bar", data.String("foo"))
 			cancel()
 			return nil
@@ -188,9 +185,9 @@ func TestModule_Watch(t *testing.T) {
 		ctx, cancel := context.WithCancel(context.Background())
 		defer cancel()
 
-		dispatcher := &events.Event[contract.ConfigUnmarshaler]{}
-		dispatcher.On(func(ctx context.Context, event contract.ConfigUnmarshaler) error {
-			data := event.(*KoanfAdapter)
+		dispatcher := &events.Event[lifecycle.ConfigReloadPayload]{}
+		dispatcher.On(func(ctx context.Context, payload lifecycle.ConfigReloadPayload) error {
+			data := payload.Config.(*KoanfAdapter)
 			assert.Equal(t, "bar", data.String("foo"))
 			cancel()
 			return nil
@@ -204,6 +201,7 @@ func TestModule_Watch(t *testing.T) {
 		module, _ := New(ConfigIn{
 			Conf: conf,
 		})
+		assert.False(t, conf.tolerant)
 		var g run.Group
 		g.Add(func() error {
 			<-ctx.Done()
@@ -214,8 +212,109 @@ func TestModule_Watch(t *testing.T) {
 	})
 }
 
+func TestModule_tolerantReload(t *testing.T) {
+	for _, c := range []struct {
+		name     string
+		tolerant bool
+	}{
+		{"strict", false},
+		{"tolerant", true},
+	} {
+		t.Run(c.name, func(t *testing.T) {
+			value := "good"
+			conf, err := NewConfig(
+				WithProviderLayer(readerFunc(func() (map[string]any, error) {
+					return map[string]any{"foo": value}, nil
+				}), nil),
+				WithProviderLayer(confmap.Provider(map[string]any{"config.tolerantReload": c.tolerant}, "."), nil),
+				WithValidators(func(data map[string]any) error {
+					if data["foo"] == "bad" {
+						return errors.New("foo must not be bad")
+					}
+					return nil
+				}),
+				WithWatcher(stepWatcher{steps: []string{"bad", "better"}, value: &value}),
+			)
+			assert.NoError(t, err)
+			_, err = New(ConfigIn{Conf: conf})
+			assert.NoError(t, err)
+			assert.Equal(t, c.tolerant, conf.tolerant)
+
+			err = conf.Watch(context.Background())
+			if c.tolerant {
+				assert.NoError(t, err)
+				assert.Equal(t, "better", conf.String("foo"))
+				return
+			}
+			assert.ErrorContains(t, err, "foo must not be bad")
+			assert.Equal(t, "good", conf.String("foo"))
+		})
+	}
+}
+
 type MockWatcher struct{}
 
 func (m *MockWatcher) Watch(ctx context.Context, reload func() error) error {
 	return reload()
 }
+
+func TestModule_ProvideCommand_dumpCmd(t *testing.T) {
+	t.Setenv("CORE_DUMP_TOKEN", "hunter2")
+	conf, err := NewConfig(
+		WithProviderLayer(confmap.Provider(map[string]any{
+			"log.level":  "info",
+			"app.secret": "${env:CORE_DUMP_TOKEN}",
+		}, "."), nil),
+		WithNamedProviderLayer("default", confmap.Provider(map[string]any{
+			"http.addr":              ":8080",
+			"log.level":              "debug",
+			"redis.default.password": "plain",
+			"redis.default.addrs":    []any{"127.0.0.1:6379"},
+		}, "."), nil),
+	)
+	assert.NoError(t, err)
+
+	run := func(args ...string) string {
+		var buf strings.Builder
+		rootCmd := &cobra.Command{Use: "root"}
+		Module{conf: conf}.ProvideCommand(rootCmd)
+		rootCmd.SetOut(&buf)
+		rootCmd.SetArgs(append([]string{"config", "dump"}, args...))
+		assert.NoError(t, rootCmd.Execute())
+		return buf.String()
+	}
+
+	assert.Equal(t, `app:
+  secret: '******'
+http:
+  addr: :8080
+log:
+  level: info
+redis:
+  default:
+    addrs:
+      - 127.0.0.1:6379
+    password: '******'
+`, run())
+
+	assert.Equal(t, `app:
+  secret: '******' # inline
+http:
+  addr: :8080 # default
+log:
+  level: info # inline
+redis:
+  default:
+    addrs: # default
+      - 127.0.0.1:6379
+    password: '******' # default
+`, run("--explain"))
+
+	out := run("--explain", "--style", "json")
+	assert.Contains(t, out, `"addr": {
+      "source": "default",
+      "value": ":8080"
+    }`)
+	assert.NotContains(t, out, "hunter2")
+	assert.NotContains(t, out, "plain")
+}
@@ -0,0 +1,92 @@
+package config
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/DoNewsCode/core/codec/yaml"
+
+	"github.com/knadh/koanf"
+	"github.com/knadh/koanf/providers/file"
+)
+
+// OverlayProvider is a koanf.Provider that reads the overlays of a config file,
+// which sit next to it. For config.yaml, the overlays are config.<env>.yaml,
+// such as config.production.yaml, and config.local.yaml on top. Missing overlays
+// are skipped.
+//
+// The environment is resolved by NewEnv from the env entry of the rest of the
+// configuration stack, so it can be set in config.yaml, and overridden by the
+// flags or the environment variables. The environment overlay is skipped if the
+// environment is unknown.
+//
+// When used as a layer of KoanfAdapter, each overlay forms a separate layer,
+// named after its path in the output of config dump --explain. Put this layer
+// right above the layer of the config file itself.
+type OverlayProvider struct {
+	// Path is the path of the config file.
+	Path string
+	// Parser parses the overlays. Defaults to the YAML parser.
+	Parser koanf.Parser
+}
+
+// ReadBytes is not supported by OverlayProvider.
+func (o OverlayProvider) ReadBytes() ([]byte, error) {
+	return nil, errors.New("overlay provider does not support this method")
+}
+
+// Read returns the overlays merged. The environment is resolved from the config
+// file.
+func (o OverlayProvider) Read() (map[string]any, error) {
+	layers, err := o.layers(func() (*koanf.Koanf, error) {
+		return loadLayers([]ProviderSet{{Provider: file.Provider(o.Path), Parser: o.parser()}})
+	})
+	if err != nil {
+		return nil, err
+	}
+	merged, err := loadLayers(layers)
+	if err != nil {
+		return nil, err
+	}
+	return merged.Raw(), nil
+}
+
+// Overlays returns the paths of the overlays of the config file in the
+// environment, whether they exist or not, the one on top first.
+func (o OverlayProvider) Overlays(env Env) []string {
+	ext := filepath.Ext(o.Path)
+	stem := strings.TrimSuffix(o.Path, ext)
+	paths := []string{stem + "." + EnvLocal.String() + ext}
+	if env != EnvUnknown && env != EnvLocal {
+		paths = append(paths, stem+"."+env.String()+ext)
+	}
+	return paths
+}
+
+// layers returns a layer for each existing overlay, the one on top first.
+func (o OverlayProvider) layers(base func() (*koanf.Koanf, error)) ([]ProviderSet, error) {
+	tree, err := base()
+	if err != nil {
+		return nil, err
+	}
+	var layers []ProviderSet
+	for _, path := range o.Overlays(NewEnv(tree.String("env"))) {
+		if _, err := os.Stat(path); err != nil {
+			if os.IsNotExist(err) {
+				continue
+			}
+			return nil, err
+		}
+		layers = append(layers, ProviderSet{Provider: file.Provider(path), Parser: o.parser(), Name: "file " + path})
+	}
+	return layers, nil
+}
+
+func (o OverlayProvider) parser() koanf.Parser {
+	if o.Parser != nil {
+		return o.Parser
+	}
+	return CodecParser{Codec: yaml.Codec{}}
+}
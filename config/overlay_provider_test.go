@@ -0,0 +1,59 @@
+package config
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/knadh/koanf/parsers/yaml"
+	"github.com/knadh/koanf/providers/confmap"
+	"github.com/knadh/koanf/providers/file"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestOverlayProvider(t *testing.T) {
+	dir := t.TempDir()
+	write := func(name, content string) {
+		assert.NoError(t, os.WriteFile(filepath.Join(dir, name), []byte(content), os.ModePerm))
+	}
+	path := filepath.Join(dir, "config.yaml")
+	write("config.yaml", "env: prod\nhttp:\n  addr: :8080\nlog:\n  level: info\nname: app\n")
+	write("config.production.yaml", "http:\n  addr: :80\nlog:\n  level: warn\n")
+	write("config.staging.yaml", "http:\n  addr: :81\n")
+	write("config.local.yaml", "log:\n  level: debug\n")
+
+	overlay := OverlayProvider{Path: path}
+	assert.Equal(t, []string{filepath.Join(dir, "config.local.yaml"), filepath.Join(dir, "config.staging.yaml")}, overlay.Overlays(EnvStaging))
+	assert.Equal(t, []string{filepath.Join(dir, "config.local.yaml")}, overlay.Overlays(EnvLocal))
+	assert.Equal(t, []string{filepath.Join(dir, "config.local.yaml")}, overlay.Overlays(EnvUnknown))
+
+	data, err := overlay.Read()
+	assert.NoError(t, err)
+	assert.Equal(t, map[string]any{"http": map[string]any{"addr": ":80"}, "log": map[string]any{"level": "debug"}}, data)
+
+	// The environment can be overridden by the layers above.
+	env := map[string]any{}
+	conf, err := NewConfig(
+		WithProviderLayer(readerFunc(func() (map[string]any, error) { return env, nil }), nil),
+		WithProviderLayer(overlay, nil),
+		WithProviderLayer(file.Provider(path), yaml.Parser()),
+	)
+	assert.NoError(t, err)
+	assert.Equal(t, ":80", conf.String("http.addr"))
+	assert.Equal(t, "debug", conf.String("log.level"))
+	assert.Equal(t, "app", conf.String("name"))
+
+	sources, err := conf.Explain()
+	assert.NoError(t, err)
+	assert.Equal(t, "file "+filepath.Join(dir, "config.production.yaml"), sources["http.addr"])
+	assert.Equal(t, "file "+filepath.Join(dir, "config.local.yaml"), sources["log.level"])
+
+	env["env"] = "staging"
+	assert.NoError(t, os.Remove(filepath.Join(dir, "config.local.yaml")))
+	assert.NoError(t, conf.Reload())
+	assert.Equal(t, ":81", conf.String("http.addr"))
+	assert.Equal(t, "info", conf.String("log.level"))
+
+	_, err = NewConfig(WithProviderLayer(OverlayProvider{Path: filepath.Join(dir, "missing.yaml")}, nil), WithProviderLayer(confmap.Provider(map[string]any{}, "."), nil))
+	assert.NoError(t, err)
+}
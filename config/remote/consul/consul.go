@@ -0,0 +1,207 @@
+// Package consul allows the core package to bootstrap its configuration from a
+// key in the Consul KV store.
+package consul
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/DoNewsCode/core"
+	"github.com/DoNewsCode/core/config"
+	"github.com/DoNewsCode/core/contract"
+)
+
+const (
+	defaultAddress  = "http://127.0.0.1:8500"
+	defaultWaitTime = 5 * time.Minute
+	minBackoff      = time.Second
+	maxBackoff      = time.Minute
+)
+
+// Config is the configuration of the Consul HTTP API.
+type Config struct {
+	// Address is the address of the Consul agent. Defaults to
+	// http://127.0.0.1:8500.
+	Address string
+	// Token is the ACL token. Optional.
+	Token string
+	// Datacenter is the datacenter to query. Defaults to the datacenter of the
+	// agent.
+	Datacenter string
+	// WaitTime is the maximum duration of a blocking query. Defaults to 5m.
+	WaitTime time.Duration
+	// HTTPClient sends the requests. Defaults to http.DefaultClient.
+	HTTPClient *http.Client
+}
+
+// Consul is a core.ConfProvider and contract.ConfigWatcher implementation to
+// read and watch a key in the Consul KV store. The changes are watched by
+// blocking queries.
+type Consul struct {
+	key    string
+	config Config
+
+	mu    sync.Mutex
+	index uint64
+	value []byte
+}
+
+// Provider creates a *Consul.
+func Provider(cfg Config, key string) *Consul {
+	return &Consul{
+		key:    strings.TrimPrefix(key, "/"),
+		config: cfg,
+	}
+}
+
+// WithKey is a two-in-one coreOption. It uses the key in the Consul KV store as
+// the source of configuration, and watches the change of that key for hot
+// reloading.
+func WithKey(cfg Config, key string, codec contract.Codec) (core.CoreOption, core.CoreOption) {
+	r := Provider(cfg, key)
+	return core.WithNamedConfigStack("consul "+key, r, config.CodecParser{Codec: codec}), core.WithConfigWatcher(r)
+}
+
+// ReadBytes reads the value of the key from Consul and returns the bytes.
+func (r *Consul) ReadBytes() ([]byte, error) {
+	value, index, err := r.get(context.Background(), 0)
+	if err != nil {
+		return nil, err
+	}
+	r.mu.Lock()
+	r.index, r.value = index, value
+	r.mu.Unlock()
+	return value, nil
+}
+
+// Read is not supported by the remote provider.
+func (r *Consul) Read() (map[string]any, error) {
+	return nil, errors.New("remote provider does not support this method")
+}
+
+// Watch watches the change to the key by blocking queries. If the value is
+// changed, the reload function will be called. Note the reload function should
+// not just load the changes made within this key, but rather it should reload
+// the whole config stack. Failed queries are retried with exponential backoff,
+// until the context is canceled.
+func (r *Consul) Watch(ctx context.Context, reload func() error) error {
+	backoff := minBackoff
+	for {
+		r.mu.Lock()
+		index, last := r.index, r.value
+		r.mu.Unlock()
+
+		value, next, err := r.get(ctx, index)
+		if ctx.Err() != nil {
+			return nil
+		}
+		if err != nil {
+			if !sleep(ctx, backoff) {
+				return nil
+			}
+			if backoff *= 2; backoff > maxBackoff {
+				backoff = maxBackoff
+			}
+			continue
+		}
+		backoff = minBackoff
+
+		// The index may go backwards, for example after a snapshot restore.
+		// Start over from 0 in that case.
+		if next < index {
+			next = 0
+		}
+		r.mu.Lock()
+		r.index = next
+		r.mu.Unlock()
+		if bytes.Equal(value, last) {
+			continue
+		}
+
+		// Trigger event.
+		if err := reload(); err != nil {
+			return err
+		}
+	}
+}
+
+// get reads the value of the key. If index is greater than 0, the query blocks
+// until the index of the key exceeds it, or the wait time elapses. It returns
+// the value along with the index of the key.
+func (r *Consul) get(ctx context.Context, index uint64) ([]byte, uint64, error) {
+	address := r.config.Address
+	if address == "" {
+		address = defaultAddress
+	}
+	if !strings.Contains(address, "://") {
+		address = "http://" + address
+	}
+	query := url.Values{"raw": {""}}
+	if r.config.Datacenter != "" {
+		query.Set("dc", r.config.Datacenter)
+	}
+	if index > 0 {
+		wait := r.config.WaitTime
+		if wait <= 0 {
+			wait = defaultWaitTime
+		}
+		query.Set("index", strconv.FormatUint(index, 10))
+		query.Set("wait", strconv.FormatInt(wait.Milliseconds(), 10)+"ms")
+	}
+	u := strings.TrimSuffix(address, "/") + "/v1/kv/" + r.key + "?" + query.Encode()
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, u, nil)
+	if err != nil {
+		return nil, 0, err
+	}
+	if r.config.Token != "" {
+		req.Header.Set("X-Consul-Token", r.config.Token)
+	}
+	client := r.config.HTTPClient
+	if client == nil {
+		client = http.DefaultClient
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		return nil, 0, err
+	}
+	defer resp.Body.Close()
+
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, 0, err
+	}
+	switch resp.StatusCode {
+	case http.StatusOK:
+	case http.StatusNotFound:
+		return nil, 0, fmt.Errorf("no such config key: %s", r.key)
+	default:
+		return nil, 0, fmt.Errorf("failed to read config key %s: %s: %s", r.key, resp.Status, bytes.TrimSpace(body))
+	}
+	next, err := strconv.ParseUint(resp.Header.Get("X-Consul-Index"), 10, 64)
+	if err != nil {
+		return nil, 0, fmt.Errorf("invalid X-Consul-Index of config key %s: %w", r.key, err)
+	}
+	return body, next, nil
+}
+
+func sleep(ctx context.Context, d time.Duration) bool {
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+
+	select {
+	case <-timer.C:
+		return true
+	case <-ctx.Done():
+		return false
+	}
+}
@@ -0,0 +1,127 @@
+package consul
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/DoNewsCode/core"
+	"github.com/DoNewsCode/core/codec/yaml"
+	"github.com/DoNewsCode/core/contract"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// kv is a stand-in for the KV store of Consul, serving a single key.
+type kv struct {
+	mu      sync.Mutex
+	cond    *sync.Cond
+	value   string
+	index   uint64
+	token   string
+	queries []string
+}
+
+func newKV(value string) *kv {
+	s := &kv{value: value, index: 10}
+	s.cond = sync.NewCond(&s.mu)
+	return s
+}
+
+func (s *kv) put(value string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.value = value
+	s.index++
+	s.cond.Broadcast()
+}
+
+func (s *kv) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.URL.Path != "/v1/kv/app.yaml" {
+		w.WriteHeader(http.StatusNotFound)
+		return
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.token = r.Header.Get("X-Consul-Token")
+	s.queries = append(s.queries, r.URL.RawQuery)
+	if index, _ := strconv.ParseUint(r.URL.Query().Get("index"), 10, 64); index > 0 {
+		wait, _ := time.ParseDuration(r.URL.Query().Get("wait"))
+		deadline := time.AfterFunc(wait, func() {
+			s.mu.Lock()
+			defer s.mu.Unlock()
+			s.cond.Broadcast()
+		})
+		defer deadline.Stop()
+		start := time.Now()
+		for s.index <= index && time.Since(start) < wait {
+			s.cond.Wait()
+		}
+	}
+	w.Header().Set("X-Consul-Index", strconv.FormatUint(s.index, 10))
+	w.Write([]byte(s.value))
+}
+
+func TestConsul(t *testing.T) {
+	store := newKV("name: app")
+	server := httptest.NewServer(store)
+	defer server.Close()
+
+	r := Provider(Config{Address: server.URL, Token: "secret", Datacenter: "dc1", WaitTime: 100 * time.Millisecond}, "/app.yaml")
+
+	_, err := r.Read()
+	assert.Error(t, err)
+
+	bytes, err := r.ReadBytes()
+	assert.NoError(t, err)
+	assert.Equal(t, "name: app", string(bytes))
+	assert.Equal(t, "secret", store.token)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	ch := make(chan string)
+	done := make(chan error)
+	go func() {
+		done <- r.Watch(ctx, func() error {
+			bytes, err := r.ReadBytes()
+			if err != nil {
+				return err
+			}
+			ch <- string(bytes)
+			return nil
+		})
+	}()
+
+	// Let a blocking query time out without changes.
+	time.Sleep(300 * time.Millisecond)
+	store.put("name: app2")
+	assert.Equal(t, "name: app2", <-ch)
+
+	cancel()
+	assert.NoError(t, <-done)
+
+	store.mu.Lock()
+	defer store.mu.Unlock()
+	assert.Equal(t, "dc=dc1&raw=", store.queries[0])
+	assert.Contains(t, store.queries, "dc=dc1&index=10&raw=&wait=100ms")
+
+	_, err = Provider(Config{Address: server.URL}, "missing").ReadBytes()
+	assert.EqualError(t, err, "no such config key: missing")
+}
+
+func TestWithKey(t *testing.T) {
+	server := httptest.NewServer(newKV("name: consul"))
+	defer server.Close()
+
+	c := core.New(WithKey(Config{Address: server.URL}, "app.yaml", yaml.Codec{}))
+	c.ProvideEssentials()
+	c.Invoke(func(accessor contract.ConfigAccessor) {
+		assert.Equal(t, "consul", accessor.String("name"))
+	})
+}
@@ -1,4 +1,5 @@
-// Package etcd allows the core package to bootstrap its configuration from an etcd server.
+// Package etcd allows the core package to bootstrap its configuration from an etcd server, either from a
+// single key, or from the keys under a prefix.
 package etcd
 
 import (
@@ -33,7 +34,7 @@ func Provider(clientConfig clientv3.Config, key string) *ETCD {
 // source of configuration, and watches the change of that key for hot reloading.
 func WithKey(cfg clientv3.Config, key string, codec contract.Codec) (core.CoreOption, core.CoreOption) {
 	r := Provider(cfg, key)
-	return core.WithConfigStack(r, config.CodecParser{Codec: codec}), core.WithConfigWatcher(r)
+	return core.WithNamedConfigStack("etcd "+key, r, config.CodecParser{Codec: codec}), core.WithConfigWatcher(r)
 }
 
 // ReadBytes reads the contents of a key from etcd and returns the bytes.
@@ -0,0 +1,287 @@
+package etcd
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/DoNewsCode/core"
+
+	"github.com/knadh/koanf/maps"
+	"go.etcd.io/etcd/api/v3/v3rpc/rpctypes"
+	"go.etcd.io/etcd/client/v3"
+	"go.uber.org/zap"
+	"gopkg.in/yaml.v3"
+)
+
+const (
+	batchInterval = 100 * time.Millisecond
+	minBackoff    = time.Second
+	maxBackoff    = time.Minute
+)
+
+// Prefix is a core.ConfProvider and contract.ConfigWatcher implementation to
+// read and watch the keys under a prefix on etcd. The keys are mapped onto the
+// config paths by their segments, so with the prefix /app/config/, the key
+// /app/config/redis/default/addrs holds redis.default.addrs. The prefix always
+// ends with a "/", so that /app/config doesn't match /app/configx.
+// The values are parsed as YAML, so that 8080 is an int and [a, b] is a list.
+//
+// A single client is shared by reads and watches. It is closed when Watch
+// returns; if the prefix is only read and never watched, call Close to release
+// it. The watch is resumed from the last revision after a disconnect, with
+// exponential backoff, and the changes within a short interval are reloaded at
+// once. The failures of the watch are logged by the logger of the client, see
+// clientv3.Config.Logger.
+type Prefix struct {
+	prefix       string
+	clientConfig clientv3.Config
+
+	mu     sync.Mutex
+	client *clientv3.Client
+	rev    int64
+}
+
+// PrefixProvider creates a *Prefix. A "/" is appended to the prefix if it doesn't
+// end with one.
+func PrefixProvider(clientConfig clientv3.Config, prefix string) *Prefix {
+	if !strings.HasSuffix(prefix, "/") {
+		prefix += "/"
+	}
+	return &Prefix{
+		prefix:       prefix,
+		clientConfig: clientConfig,
+	}
+}
+
+// WithPrefix is a two-in-one coreOption. It uses the keys under the prefix on
+// etcd as the source of configuration, and watches the changes of those keys
+// for hot reloading.
+func WithPrefix(cfg clientv3.Config, prefix string) (core.CoreOption, core.CoreOption) {
+	r := PrefixProvider(cfg, prefix)
+	return core.WithNamedConfigStack("etcd "+r.prefix, r, nil), core.WithConfigWatcher(r)
+}
+
+// ReadBytes is not supported by the prefix provider.
+func (r *Prefix) ReadBytes() ([]byte, error) {
+	return nil, errors.New("prefix provider does not support this method")
+}
+
+// Read reads the keys under the prefix from etcd, and returns them as a nested
+// map.
+func (r *Prefix) Read() (map[string]any, error) {
+	client, err := r.getClient()
+	if err != nil {
+		return nil, err
+	}
+	resp, err := client.Get(r.context(), r.prefix, clientv3.WithPrefix())
+	if err != nil {
+		return nil, err
+	}
+
+	out := make(map[string]any, len(resp.Kvs))
+	for _, kv := range resp.Kvs {
+		if path := r.path(string(kv.Key)); path != "" {
+			out[path] = parseValue(kv.Value)
+		}
+	}
+	r.advance(resp.Header.Revision)
+	return maps.Unflatten(out, "."), nil
+}
+
+// Watch watches the changes to the keys under the prefix. The changes within a
+// short interval trigger a single call to the reload function. Note the reload
+// function should reload the whole config stack. If the watch fails, it is
+// resumed from the last reloaded revision after a backoff. If the revision has
+// been compacted, the config is reloaded, and the watch resumes from the
+// compacted revision. Watch returns when the context is canceled, or the reload
+// fails, and closes the client.
+func (r *Prefix) Watch(ctx context.Context, reload func() error) error {
+	client, err := r.getClient()
+	if err != nil {
+		return err
+	}
+	defer r.Close()
+
+	backoff := minBackoff
+	for {
+		r.mu.Lock()
+		rev := r.rev
+		r.mu.Unlock()
+
+		start := time.Now()
+		err := r.watch(ctx, client, rev, reload)
+		if ctx.Err() != nil {
+			return nil
+		}
+		if errors.Is(err, rpctypes.ErrCompacted) {
+			// The events since the revision are lost. Read the whole prefix
+			// again.
+			if err := reload(); err != nil {
+				return err
+			}
+			continue
+		}
+		var reloadErr reloadError
+		if errors.As(err, &reloadErr) {
+			return reloadErr.err
+		}
+		// A watch that lasted is not part of a series of failures.
+		if time.Since(start) > maxBackoff {
+			backoff = minBackoff
+		}
+		client.GetLogger().Warn(
+			"failed to watch config, retrying",
+			zap.String("prefix", r.prefix),
+			zap.Duration("backoff", backoff),
+			zap.Error(err),
+		)
+		if !sleep(ctx, backoff) {
+			return nil
+		}
+		if backoff *= 2; backoff > maxBackoff {
+			backoff = maxBackoff
+		}
+	}
+}
+
+// Close closes the client.
+func (r *Prefix) Close() error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if r.client == nil {
+		return nil
+	}
+	err := r.client.Close()
+	r.client = nil
+	return err
+}
+
+type reloadError struct {
+	err error
+}
+
+func (e reloadError) Error() string {
+	return e.err.Error()
+}
+
+// watch watches the prefix after the revision until the watch channel fails or
+// closes. The events are batched into reloads. The revision moves forward only
+// once the events are reloaded, so that a resumed watch replays the rest.
+func (r *Prefix) watch(ctx context.Context, client *clientv3.Client, rev int64, reload func() error) error {
+	ctx, cancel := context.WithCancel(clientv3.WithRequireLeader(ctx))
+	defer cancel()
+
+	opts := []clientv3.OpOption{clientv3.WithPrefix()}
+	if rev > 0 {
+		opts = append(opts, clientv3.WithRev(rev+1))
+	}
+	wch := client.Watch(ctx, r.prefix, opts...)
+	var (
+		pending int64
+		timer   = time.NewTimer(batchInterval)
+	)
+	timer.Stop()
+	defer timer.Stop()
+
+	for {
+		select {
+		case resp, ok := <-wch:
+			if !ok {
+				return errors.New("etcd watch channel closed")
+			}
+			if resp.CompactRevision != 0 {
+				// Resume from the oldest revision available.
+				r.advance(resp.CompactRevision - 1)
+			}
+			if err := resp.Err(); err != nil {
+				return err
+			}
+			if len(resp.Events) == 0 {
+				continue
+			}
+			if pending == 0 {
+				timer.Reset(batchInterval)
+			}
+			pending = resp.Header.Revision
+		case <-timer.C:
+			// Trigger event.
+			if err := reload(); err != nil {
+				return reloadError{err}
+			}
+			r.advance(pending)
+			pending = 0
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+	}
+}
+
+// advance moves the revision forward.
+func (r *Prefix) advance(rev int64) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if rev > r.rev {
+		r.rev = rev
+	}
+}
+
+func (r *Prefix) getClient() (*clientv3.Client, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if r.client != nil {
+		return r.client, nil
+	}
+	client, err := clientv3.New(r.clientConfig)
+	if err != nil {
+		return nil, err
+	}
+	r.client = client
+	return client, nil
+}
+
+// path maps the key onto the config path, or returns "" if the key is the
+// prefix itself.
+func (r *Prefix) path(key string) string {
+	var segments []string
+	for _, segment := range strings.Split(strings.TrimPrefix(key, r.prefix), "/") {
+		if segment != "" {
+			segments = append(segments, segment)
+		}
+	}
+	return strings.Join(segments, ".")
+}
+
+func (r *Prefix) context() context.Context {
+	if r.clientConfig.Context != nil {
+		return r.clientConfig.Context
+	}
+	return context.Background()
+}
+
+// parseValue parses the value as YAML. Values that are not valid YAML are kept
+// as strings.
+func parseValue(value []byte) any {
+	var v any
+	if err := yaml.Unmarshal(value, &v); err != nil {
+		return string(value)
+	}
+	return v
+}
+
+func sleep(ctx context.Context, d time.Duration) bool {
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+
+	select {
+	case <-timer.C:
+		return true
+	case <-ctx.Done():
+		return false
+	}
+}
@@ -0,0 +1,89 @@
+package etcd
+
+import (
+	"context"
+	"os"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"go.etcd.io/etcd/client/v3"
+)
+
+func TestPrefix_path(t *testing.T) {
+	r := PrefixProvider(clientv3.Config{}, "/app/config/")
+	assert.Equal(t, "redis.default.addrs", r.path("/app/config/redis/default/addrs"))
+	assert.Equal(t, "log.level", r.path("/app/config//log/level/"))
+	assert.Equal(t, "", r.path("/app/config/"))
+
+	r = PrefixProvider(clientv3.Config{}, "/app/config")
+	assert.Equal(t, "/app/config/", r.prefix)
+	assert.Equal(t, "log.level", r.path("/app/config/log/level"))
+
+	_, err := r.ReadBytes()
+	assert.Error(t, err)
+}
+
+func TestParseValue(t *testing.T) {
+	assert.Equal(t, 8080, parseValue([]byte("8080")))
+	assert.Equal(t, true, parseValue([]byte("true")))
+	assert.Equal(t, []any{"127.0.0.1:6379"}, parseValue([]byte("[127.0.0.1:6379]")))
+	assert.Equal(t, map[string]any{"level": "info"}, parseValue([]byte("level: info")))
+	assert.Equal(t, ":8080", parseValue([]byte(":8080")))
+	assert.Equal(t, "a: b: c", parseValue([]byte("a: b: c")))
+}
+
+func TestPrefix(t *testing.T) {
+	if os.Getenv("ETCD_ADDR") == "" {
+		t.Skip("set ETCD_ADDR to run TestPrefix")
+		return
+	}
+	addrs := strings.Split(os.Getenv("ETCD_ADDR"), ",")
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	cfg := clientv3.Config{
+		Endpoints:   addrs,
+		DialTimeout: time.Second,
+	}
+	client, err := clientv3.New(cfg)
+	assert.NoError(t, err)
+	defer client.Close()
+	_, err = client.Delete(ctx, "/test/prefix/", clientv3.WithPrefix())
+	assert.NoError(t, err)
+	_, err = client.Put(ctx, "/test/prefix/redis/default/addrs", "[127.0.0.1:6379]")
+	assert.NoError(t, err)
+
+	r := PrefixProvider(cfg, "/test/prefix/")
+	defer r.Close()
+
+	data, err := r.Read()
+	assert.NoError(t, err)
+	assert.Equal(t, map[string]any{"redis": map[string]any{"default": map[string]any{"addrs": []any{"127.0.0.1:6379"}}}}, data)
+
+	reloads := make(chan map[string]any, 10)
+	go r.Watch(ctx, func() error {
+		data, err := r.Read()
+		if err != nil {
+			return err
+		}
+		reloads <- data
+		return nil
+	})
+	time.Sleep(100 * time.Millisecond)
+
+	// Changes in a burst are reloaded at once.
+	_, err = client.Put(ctx, "/test/prefix/redis/default/db", "1")
+	assert.NoError(t, err)
+	_, err = client.Put(ctx, "/test/prefix/log/level", "debug")
+	assert.NoError(t, err)
+
+	data = <-reloads
+	assert.Equal(t, 1, data["redis"].(map[string]any)["default"].(map[string]any)["db"])
+	assert.Equal(t, "debug", data["log"].(map[string]any)["level"])
+	select {
+	case <-reloads:
+		t.Fatal("expected a single reload")
+	case <-time.After(300 * time.Millisecond):
+	}
+}
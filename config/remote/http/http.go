@@ -0,0 +1,211 @@
+// Package http allows the core package to bootstrap its configuration from a
+// plain HTTP endpoint.
+//
+// The endpoint serves the configuration document on GET. The changes are
+// detected by conditional requests: the ETag of the last response is sent back
+// in the If-None-Match header, and the endpoint responds 304 Not Modified if the
+// document is unchanged. Endpoints that support long polling hold the
+// conditional request until the document changes, or until the wait time sent
+// in the Prefer header elapses:
+//
+//	GET /config.yaml HTTP/1.1
+//	If-None-Match: "v42"
+//	Prefer: wait=300
+package http
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	neturl "net/url"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/DoNewsCode/core"
+	"github.com/DoNewsCode/core/config"
+	"github.com/DoNewsCode/core/contract"
+)
+
+const (
+	defaultInterval = 30 * time.Second
+	minBackoff      = time.Second
+	maxBackoff      = time.Minute
+)
+
+// Config is the configuration of the HTTP endpoint.
+type Config struct {
+	// Header is added to every request, such as Authorization. Optional.
+	Header http.Header
+	// LongPoll is the maximum duration the endpoint may hold a conditional
+	// request, sent in the Prefer header. If zero, the endpoint is polled.
+	LongPoll time.Duration
+	// Interval is the pause between two requests when watching. Defaults to 30s
+	// if LongPoll is zero, or no pause otherwise.
+	Interval time.Duration
+	// HTTPClient sends the requests. Defaults to http.DefaultClient. If set, its
+	// Timeout should exceed LongPoll.
+	HTTPClient *http.Client
+}
+
+// HTTP is a core.ConfProvider and contract.ConfigWatcher implementation to read
+// and watch a configuration document served over HTTP.
+type HTTP struct {
+	url    string
+	config Config
+
+	mu    sync.Mutex
+	etag  string
+	value []byte
+}
+
+// Provider creates a *HTTP.
+func Provider(cfg Config, url string) *HTTP {
+	return &HTTP{
+		url:    url,
+		config: cfg,
+	}
+}
+
+// WithKey is a two-in-one coreOption. It uses the document at the URL as the
+// source of configuration, and watches the change of that document for hot
+// reloading.
+func WithKey(cfg Config, url string, codec contract.Codec) (core.CoreOption, core.CoreOption) {
+	r := Provider(cfg, url)
+	return core.WithNamedConfigStack(layerName(url), r, config.CodecParser{Codec: codec}), core.WithConfigWatcher(r)
+}
+
+// layerName names the layer after the URL, without the credentials and the
+// query, which may carry secrets.
+func layerName(rawURL string) string {
+	u, err := neturl.Parse(rawURL)
+	if err != nil {
+		return "http"
+	}
+	u.User, u.RawQuery, u.Fragment = nil, "", ""
+	return "http " + u.String()
+}
+
+// ReadBytes reads the document from the URL and returns the bytes.
+func (r *HTTP) ReadBytes() ([]byte, error) {
+	value, etag, err := r.get(context.Background(), "")
+	if err != nil {
+		return nil, err
+	}
+	r.mu.Lock()
+	r.etag, r.value = etag, value
+	r.mu.Unlock()
+	return value, nil
+}
+
+// Read is not supported by the remote provider.
+func (r *HTTP) Read() (map[string]any, error) {
+	return nil, errors.New("remote provider does not support this method")
+}
+
+// Watch watches the change to the document by conditional requests. If the
+// document is changed, the reload function will be called. Note the reload
+// function should not just load the changes made within this document, but
+// rather it should reload the whole config stack. Failed requests are retried
+// with exponential backoff, until the context is canceled.
+func (r *HTTP) Watch(ctx context.Context, reload func() error) error {
+	interval := r.config.Interval
+	if interval <= 0 && r.config.LongPoll <= 0 {
+		interval = defaultInterval
+	}
+	backoff := minBackoff
+	for {
+		r.mu.Lock()
+		etag, last := r.etag, r.value
+		r.mu.Unlock()
+
+		value, next, err := r.get(ctx, etag)
+		if ctx.Err() != nil {
+			return nil
+		}
+		if err != nil {
+			if !sleep(ctx, backoff) {
+				return nil
+			}
+			if backoff *= 2; backoff > maxBackoff {
+				backoff = maxBackoff
+			}
+			continue
+		}
+		backoff = minBackoff
+
+		r.mu.Lock()
+		r.etag = next
+		r.mu.Unlock()
+
+		// value is nil if the document is not modified.
+		if value != nil && !bytes.Equal(value, last) {
+			// Trigger event.
+			if err := reload(); err != nil {
+				return err
+			}
+		}
+		if interval > 0 && !sleep(ctx, interval) {
+			return nil
+		}
+	}
+}
+
+// get requests the document. If etag is not empty, the request is conditional,
+// and a nil value is returned if the document is not modified. It returns the
+// document along with its ETag.
+func (r *HTTP) get(ctx context.Context, etag string) ([]byte, string, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, r.url, nil)
+	if err != nil {
+		return nil, "", err
+	}
+	for key, values := range r.config.Header {
+		req.Header[key] = values
+	}
+	if etag != "" {
+		req.Header.Set("If-None-Match", etag)
+		if r.config.LongPoll > 0 {
+			req.Header.Set("Prefer", "wait="+strconv.Itoa(int(r.config.LongPoll/time.Second)))
+		}
+	}
+	client := r.config.HTTPClient
+	if client == nil {
+		client = http.DefaultClient
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		return nil, "", err
+	}
+	defer resp.Body.Close()
+
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, "", err
+	}
+	switch resp.StatusCode {
+	case http.StatusOK:
+		if body == nil {
+			body = []byte{}
+		}
+		return body, resp.Header.Get("ETag"), nil
+	case http.StatusNotModified:
+		return nil, etag, nil
+	default:
+		return nil, "", fmt.Errorf("failed to read config from %s: %s", r.url, resp.Status)
+	}
+}
+
+func sleep(ctx context.Context, d time.Duration) bool {
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+
+	select {
+	case <-timer.C:
+		return true
+	case <-ctx.Done():
+		return false
+	}
+}
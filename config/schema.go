@@ -0,0 +1,391 @@
+package config
+
+import (
+	"fmt"
+	"reflect"
+	"regexp"
+	"sort"
+	"strings"
+	"time"
+)
+
+// SchemaURI is the JSON Schema draft of the generated schemas. It is the
+// latest draft supported by yaml-language-server.
+const SchemaURI = "http://json-schema.org/draft-07/schema#"
+
+// durationPattern matches the strings accepted by time.ParseDuration.
+const durationPattern = `^[-+]?(\d+(\.\d*)?|\.\d+)(ns|us|µs|ms|s|m|h)(((\d+(\.\d*)?|\.\d+)(ns|us|µs|ms|s|m|h))*)$|^0$`
+
+// SchemaProvider can be implemented by the types in ExportedConfig.Data to
+// describe themselves in JSON Schema, instead of the schema derived by
+// reflection.
+type SchemaProvider interface {
+	JSONSchema() map[string]any
+}
+
+// JSONSchema implements SchemaProvider. A duration is a string such as "1m30s",
+// or a number of nanoseconds.
+func (d Duration) JSONSchema() map[string]any {
+	return durationSchema()
+}
+
+func durationSchema() map[string]any {
+	return map[string]any{
+		"anyOf": []any{
+			map[string]any{"type": "string", "pattern": durationPattern},
+			map[string]any{"type": "number"},
+		},
+	}
+}
+
+var (
+	schemaProviderType = reflect.TypeOf((*SchemaProvider)(nil)).Elem()
+	durationType       = reflect.TypeOf(time.Duration(0))
+)
+
+// Schema derives a JSON Schema from the exported configs. The schema of each
+// value in ExportedConfig.Data is derived from its Go type:
+//
+//   - Structs are objects whose properties are named by the json tags of the
+//     fields. Other properties are allowed, see StrictSchema otherwise.
+//     The description and enum tags of the fields, such as
+//     `description:"the driver" enum:"mysql,sqlite"`, are copied to the schema.
+//     So is the oneof rule of the validate tags of strings.
+//   - Maps with string keys and typed values, such as map[string]databaseConf,
+//     are objects with arbitrary keys of the value type, as used by named
+//     instances.
+//   - Maps of interfaces, such as map[string]any, are objects with the
+//     properties derived from the default values.
+//   - config.Duration and time.Duration are strings like "1m30s" or numbers.
+//   - Types implementing SchemaProvider describe themselves.
+//
+// The comment of an exported config describes its top-level keys. Top-level
+// keys not exported by any config are allowed.
+func Schema(configs []ExportedConfig) map[string]any {
+	return schemaGenerator{}.schema(configs)
+}
+
+// StrictSchema is like Schema, except that the objects derived from structs
+// don't allow the properties other than their fields, so that misspelled keys
+// are reported. Only use it if the modules model every key they read.
+func StrictSchema(configs []ExportedConfig) map[string]any {
+	return schemaGenerator{strict: true}.schema(configs)
+}
+
+// schemaGenerator derives the schemas. If strict, the objects derived from
+// structs don't allow additional properties.
+type schemaGenerator struct {
+	strict bool
+}
+
+func (g schemaGenerator) schema(configs []ExportedConfig) map[string]any {
+	properties := make(map[string]any)
+	for _, config := range configs {
+		for key, value := range config.Data {
+			schema := g.schemaOf(reflect.ValueOf(value))
+			if config.Comment != "" {
+				if _, ok := schema["description"]; !ok {
+					schema["description"] = config.Comment
+				}
+			}
+			if existing, ok := properties[key].(map[string]any); ok {
+				schema = mergeSchema(existing, schema)
+			}
+			properties[key] = schema
+		}
+	}
+	return map[string]any{
+		"$schema":    SchemaURI,
+		"type":       "object",
+		"properties": properties,
+	}
+}
+
+// mergeSchema merges the properties of two object schemas exported for the
+// same key, such as kafka.reader and kafka.writer. Otherwise, the latter wins.
+func mergeSchema(a, b map[string]any) map[string]any {
+	pa, ok1 := a["properties"].(map[string]any)
+	pb, ok2 := b["properties"].(map[string]any)
+	if !ok1 || !ok2 {
+		return b
+	}
+	for key, value := range pb {
+		if existing, ok := pa[key].(map[string]any); ok {
+			if schema, ok := value.(map[string]any); ok {
+				value = mergeSchema(existing, schema)
+			}
+		}
+		pa[key] = value
+	}
+	return a
+}
+
+// schemaOf derives the schema from a value. The value provides the properties
+// of untyped maps and the items of untyped slices. It may be invalid, in which
+// case only the type is known.
+func (g schemaGenerator) schemaOf(v reflect.Value) map[string]any {
+	if !v.IsValid() {
+		return map[string]any{}
+	}
+	t := v.Type()
+	if t.Kind() == reflect.Interface {
+		if v.IsNil() {
+			return map[string]any{}
+		}
+		return g.schemaOf(v.Elem())
+	}
+	if t.Kind() == reflect.Pointer {
+		if v.IsNil() {
+			return g.schemaOfType(t.Elem())
+		}
+		return g.schemaOf(v.Elem())
+	}
+	if t.Implements(schemaProviderType) {
+		return v.Interface().(SchemaProvider).JSONSchema()
+	}
+	switch t.Kind() {
+	case reflect.Map:
+		if t.Key().Kind() != reflect.String || t.Elem().Kind() != reflect.Interface {
+			break
+		}
+		properties := make(map[string]any, v.Len())
+		iter := v.MapRange()
+		for iter.Next() {
+			properties[iter.Key().String()] = g.schemaOf(iter.Value())
+		}
+		return map[string]any{"type": "object", "properties": properties}
+	case reflect.Slice, reflect.Array:
+		if t.Elem().Kind() != reflect.Interface {
+			break
+		}
+		schema := map[string]any{"type": "array"}
+		if v.Len() > 0 {
+			schema["items"] = g.schemaOf(v.Index(0))
+		}
+		return schema
+	}
+	return g.schemaOfType(t)
+}
+
+// schemaOfType derives the schema from a type.
+func (g schemaGenerator) schemaOfType(t reflect.Type) map[string]any {
+	if t.Kind() == reflect.Pointer {
+		return g.schemaOfType(t.Elem())
+	}
+	if t.Implements(schemaProviderType) {
+		return reflect.Zero(t).Interface().(SchemaProvider).JSONSchema()
+	}
+	if t == durationType {
+		return durationSchema()
+	}
+	switch t.Kind() {
+	case reflect.Bool:
+		return map[string]any{"type": "boolean"}
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return map[string]any{"type": "integer"}
+	case reflect.Float32, reflect.Float64:
+		return map[string]any{"type": "number"}
+	case reflect.String:
+		return map[string]any{"type": "string"}
+	case reflect.Slice, reflect.Array:
+		return map[string]any{"type": "array", "items": g.schemaOfType(t.Elem())}
+	case reflect.Map:
+		if t.Key().Kind() != reflect.String {
+			return map[string]any{"type": "object"}
+		}
+		return map[string]any{"type": "object", "additionalProperties": g.schemaOfType(t.Elem())}
+	case reflect.Struct:
+		properties := make(map[string]any)
+		g.structProperties(t, properties)
+		schema := map[string]any{"type": "object", "properties": properties}
+		if g.strict {
+			schema["additionalProperties"] = false
+		}
+		return schema
+	}
+	return map[string]any{}
+}
+
+// structProperties collects the properties of the exported fields. The fields
+// of embedded structs tagged with squash are inlined, as in mapstructure.
+func (g schemaGenerator) structProperties(t reflect.Type, properties map[string]any) {
+	for i := 0; i < t.NumField(); i++ {
+		field := t.Field(i)
+		if !field.IsExported() {
+			continue
+		}
+		tag := field.Tag.Get("json")
+		if tag == "-" {
+			continue
+		}
+		name, opts, _ := strings.Cut(tag, ",")
+		if field.Anonymous && strings.Contains(opts, "squash") && field.Type.Kind() == reflect.Struct {
+			g.structProperties(field.Type, properties)
+			continue
+		}
+		if name == "" {
+			name = field.Name
+		}
+		schema := g.schemaOfType(field.Type)
+		if description, ok := field.Tag.Lookup("description"); ok {
+			schema["description"] = description
+		}
+		if enum, ok := field.Tag.Lookup("enum"); ok {
+			var values []any
+			for _, value := range strings.Split(enum, ",") {
+				values = append(values, value)
+			}
+			schema["enum"] = values
+		}
+		for _, rule := range strings.Split(field.Tag.Get("validate"), ",") {
+			if rule == "dive" {
+				break
+			}
+			if strings.HasPrefix(rule, "oneof=") && schema["type"] == "string" {
+				var values []any
+				for _, value := range strings.Fields(strings.TrimPrefix(rule, "oneof=")) {
+					values = append(values, value)
+				}
+				schema["enum"] = values
+			}
+		}
+		properties[name] = schema
+	}
+}
+
+// validateSchema checks the structure of the config tree against the schema
+// produced by Schema. It supports the keywords used by Schema, and reports the
+// first violation along with the key path.
+func validateSchema(schema map[string]any, value any) error {
+	return validateNode("", schema, value)
+}
+
+func validateNode(path string, schema map[string]any, value any) error {
+	// Empty values are filled by the lower layers.
+	if value == nil {
+		return nil
+	}
+	at := path
+	if at == "" {
+		at = "the root"
+	}
+	if anyOf, ok := schema["anyOf"].([]any); ok {
+		var err error
+		for _, s := range anyOf {
+			if err = validateNode(path, s.(map[string]any), value); err == nil {
+				break
+			}
+		}
+		if err != nil {
+			return err
+		}
+	}
+	if typ, ok := schema["type"].(string); ok && !isSchemaType(typ, value) {
+		return fmt.Errorf("%s must be of type %s, got %T", at, typ, value)
+	}
+	if enum, ok := schema["enum"].([]any); ok {
+		var found bool
+		for _, e := range enum {
+			if fmt.Sprint(e) == fmt.Sprint(value) {
+				found = true
+				break
+			}
+		}
+		if !found {
+			return fmt.Errorf("%s must be one of %v, got %v", at, enum, value)
+		}
+	}
+	if pattern, ok := schema["pattern"].(string); ok {
+		if s, ok := value.(string); ok && !regexp.MustCompile(pattern).MatchString(s) {
+			return fmt.Errorf("%s must match %s, got %s", at, pattern, s)
+		}
+	}
+	switch x := value.(type) {
+	case map[string]any:
+		properties, _ := schema["properties"].(map[string]any)
+		for _, key := range sortedKeys(x) {
+			if s, ok := lookupProperty(properties, key); ok {
+				if err := validateNode(joinPath(path, key), s, x[key]); err != nil {
+					return err
+				}
+				continue
+			}
+			switch additional := schema["additionalProperties"].(type) {
+			case bool:
+				if !additional {
+					return fmt.Errorf("%s is not allowed, expects one of %s", joinPath(path, key), strings.Join(propertyNames(properties), ", "))
+				}
+			case map[string]any:
+				if err := validateNode(joinPath(path, key), additional, x[key]); err != nil {
+					return err
+				}
+			}
+		}
+	case []any:
+		if items, ok := schema["items"].(map[string]any); ok {
+			for i, item := range x {
+				if err := validateNode(fmt.Sprintf("%s[%d]", path, i), items, item); err != nil {
+					return err
+				}
+			}
+		}
+	}
+	return nil
+}
+
+// lookupProperty finds the property case-insensitively, as the keys are
+// matched when unmarshalling.
+func lookupProperty(properties map[string]any, key string) (map[string]any, bool) {
+	if s, ok := properties[key].(map[string]any); ok {
+		return s, true
+	}
+	for name, s := range properties {
+		if strings.EqualFold(name, key) {
+			schema, ok := s.(map[string]any)
+			return schema, ok
+		}
+	}
+	return nil, false
+}
+
+func propertyNames(properties map[string]any) []string {
+	names := make([]string, 0, len(properties))
+	for name := range properties {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+func isSchemaType(typ string, value any) bool {
+	switch typ {
+	case "object":
+		_, ok := value.(map[string]any)
+		return ok
+	case "array":
+		_, ok := value.([]any)
+		return ok
+	case "string":
+		_, ok := value.(string)
+		return ok
+	case "boolean":
+		_, ok := value.(bool)
+		return ok
+	case "integer":
+		switch n := value.(type) {
+		case int, int64, uint64:
+			return true
+		case float64:
+			return n == float64(int64(n))
+		}
+		return false
+	case "number":
+		switch value.(type) {
+		case int, int64, uint64, float64:
+			return true
+		}
+		return false
+	}
+	return true
+}
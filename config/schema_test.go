@@ -0,0 +1,177 @@
+package config
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/spf13/cobra"
+	"github.com/stretchr/testify/assert"
+)
+
+type schemaPool struct {
+	MaxOpenConns int      `json:"maxOpenConns" description:"the maximum number of open connections"`
+	Idle         Duration `json:"idle"`
+}
+
+type schemaConf struct {
+	Driver  string        `json:"driver" enum:"mysql,sqlite"`
+	Addrs   []string      `json:"addrs,omitempty"`
+	Timeout time.Duration `json:"timeout"`
+	Pool    schemaPool    `json:"pool"`
+	Ignored string        `json:"-"`
+	hidden  string
+}
+
+func TestSchema(t *testing.T) {
+	schema := Schema([]ExportedConfig{
+		{
+			Owner: "db",
+			Data: map[string]any{
+				"db": map[string]schemaConf{"default": {Driver: "mysql"}},
+			},
+			Comment: "The databases",
+		},
+		{
+			Owner: "core",
+			Data: map[string]any{
+				"http": map[string]any{"addr": ":8080", "disable": false, "ports": []any{8080}},
+			},
+		},
+	})
+
+	actual, err := json.Marshal(schema)
+	assert.NoError(t, err)
+	expected, err := json.Marshal(map[string]any{
+		"$schema": SchemaURI,
+		"type":    "object",
+		"properties": map[string]any{
+			"db": map[string]any{
+				"type":        "object",
+				"description": "The databases",
+				"additionalProperties": map[string]any{
+					"type": "object",
+					"properties": map[string]any{
+						"driver":  map[string]any{"type": "string", "enum": []any{"mysql", "sqlite"}},
+						"addrs":   map[string]any{"type": "array", "items": map[string]any{"type": "string"}},
+						"timeout": durationSchema(),
+						"pool": map[string]any{
+							"type": "object",
+							"properties": map[string]any{
+								"maxOpenConns": map[string]any{"type": "integer", "description": "the maximum number of open connections"},
+								"idle":         durationSchema(),
+							},
+						},
+					},
+				},
+			},
+			"http": map[string]any{
+				"type": "object",
+				"properties": map[string]any{
+					"addr":    map[string]any{"type": "string"},
+					"disable": map[string]any{"type": "boolean"},
+					"ports":   map[string]any{"type": "array", "items": map[string]any{"type": "integer"}},
+				},
+			},
+		},
+	})
+	assert.NoError(t, err)
+	assert.JSONEq(t, string(expected), string(actual))
+
+	strict := StrictSchema([]ExportedConfig{{Data: map[string]any{
+		"db":   map[string]schemaConf{},
+		"http": map[string]any{"addr": ":8080"},
+	}}})
+	db := strict["properties"].(map[string]any)["db"].(map[string]any)["additionalProperties"].(map[string]any)
+	assert.Equal(t, false, db["additionalProperties"])
+	assert.Equal(t, false, db["properties"].(map[string]any)["pool"].(map[string]any)["additionalProperties"])
+	assert.NotContains(t, strict["properties"].(map[string]any)["http"], "additionalProperties")
+}
+
+func TestSchema_merge(t *testing.T) {
+	schema := Schema([]ExportedConfig{
+		{Data: map[string]any{"kafka": map[string]any{"reader": map[string]any{"brokers": []any{"a"}}}}},
+		{Data: map[string]any{"kafka": map[string]any{"writer": map[string]any{"topic": "b"}}}},
+	})
+	kafka := schema["properties"].(map[string]any)["kafka"].(map[string]any)
+	assert.Contains(t, kafka["properties"], "reader")
+	assert.Contains(t, kafka["properties"], "writer")
+}
+
+func TestValidateSchema(t *testing.T) {
+	schema := Schema([]ExportedConfig{
+		{
+			Data: map[string]any{
+				"db":   map[string]schemaConf{"default": {Driver: "mysql"}},
+				"http": map[string]any{"addr": ":8080"},
+			},
+		},
+	})
+	cases := []struct {
+		name string
+		conf map[string]any
+		err  string
+	}{
+		{"valid", map[string]any{
+			"db": map[string]any{
+				"default": map[string]any{"driver": "sqlite", "timeout": "1s", "pool": map[string]any{"idle": 1000, "MaxOpenConns": 10}},
+				"other":   map[string]any{"addrs": []any{"127.0.0.1"}, "timeout": nil},
+			},
+			"http":    map[string]any{"addr": ":8080", "tls": map[string]any{}},
+			"unknown": true,
+		}, ""},
+		{"unknown property", map[string]any{"db": map[string]any{"default": map[string]any{"drive": "mysql"}}}, ""},
+		{"enum", map[string]any{"db": map[string]any{"default": map[string]any{"driver": "oracle"}}}, "db.default.driver must be one of [mysql sqlite], got oracle"},
+		{"type", map[string]any{"db": map[string]any{"default": map[string]any{"pool": map[string]any{"maxOpenConns": "ten"}}}}, "db.default.pool.maxOpenConns must be of type integer, got string"},
+		{"items", map[string]any{"db": map[string]any{"default": map[string]any{"addrs": []any{"a", 1}}}}, "db.default.addrs[1] must be of type string, got int"},
+		{"duration", map[string]any{"db": map[string]any{"default": map[string]any{"timeout": "soon"}}}, "db.default.timeout must"},
+	}
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			err := validateSchema(schema, c.conf)
+			if c.err == "" {
+				assert.NoError(t, err)
+				return
+			}
+			assert.ErrorContains(t, err, c.err)
+		})
+	}
+
+	t.Run("strict", func(t *testing.T) {
+		schema := StrictSchema([]ExportedConfig{{Data: map[string]any{"db": map[string]schemaConf{}}}})
+		err := validateSchema(schema, map[string]any{"db": map[string]any{"default": map[string]any{"drive": "mysql"}}})
+		assert.EqualError(t, err, "db.default.drive is not allowed, expects one of addrs, driver, pool, timeout")
+		assert.NoError(t, validateSchema(schema, map[string]any{"db": map[string]any{"default": map[string]any{"Driver": "mysql"}}, "unknown": true}))
+	})
+}
+
+func TestModule_ProvideCommand_schemaCmd(t *testing.T) {
+	var buf strings.Builder
+	rootCmd := &cobra.Command{Use: "root"}
+	Module{exportedConfigs: []ExportedConfig{
+		{Owner: "foo", Data: map[string]any{"foo": "bar"}},
+		{Owner: "db", Data: map[string]any{"db": map[string]schemaConf{}}},
+	}}.ProvideCommand(rootCmd)
+	rootCmd.SetOut(&buf)
+	rootCmd.SetArgs([]string{"config", "schema", "foo"})
+	assert.NoError(t, rootCmd.Execute())
+	assert.JSONEq(t, `{
+		"$schema": "http://json-schema.org/draft-07/schema#",
+		"type": "object",
+		"properties": {"foo": {"type": "string"}}
+	}`, buf.String())
+
+	path := filepath.Join(t.TempDir(), "config.yaml")
+	assert.NoError(t, os.WriteFile(path, []byte("db:\n  default:\n    driver: oracle\n"), os.ModePerm))
+	rootCmd.SetArgs([]string{"config", "verify", "--targetFile", path})
+	assert.ErrorContains(t, rootCmd.Execute(), "invalid config: db.default.driver must be one of [mysql sqlite], got oracle")
+
+	assert.NoError(t, os.WriteFile(path, []byte("db:\n  default:\n    driver: mysql\n    charset: utf8\n"), os.ModePerm))
+	rootCmd.SetArgs([]string{"config", "verify", "--targetFile", path})
+	assert.NoError(t, rootCmd.Execute())
+	rootCmd.SetArgs([]string{"config", "verify", "--strict", "--targetFile", path})
+	assert.ErrorContains(t, rootCmd.Execute(), "invalid config: db.default.charset is not allowed")
+}
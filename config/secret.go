@@ -0,0 +1,253 @@
+package config
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"os"
+	"regexp"
+	"sort"
+	"strings"
+	"time"
+)
+
+// SecretMask replaces secrets in dumps and logs.
+const SecretMask = "******"
+
+// minRedactLength is the length from which a secret is masked wherever it
+// appears in a text. Shorter secrets, such as "1" or "dev", would mask unrelated
+// text, so they are only masked when they make up the whole text.
+const minRedactLength = 6
+
+// SecretResolver resolves the secret references of a scheme. The reference
+// ${vault:kv/data/app#password} is resolved by the resolver registered for the
+// scheme "vault", with the ref "kv/data/app#password".
+type SecretResolver interface {
+	Resolve(ctx context.Context, ref string) (string, error)
+}
+
+// SecretResolverFunc is an adapter to allow the use of ordinary functions as
+// SecretResolver.
+type SecretResolverFunc func(ctx context.Context, ref string) (string, error)
+
+// Resolve implements SecretResolver.
+func (f SecretResolverFunc) Resolve(ctx context.Context, ref string) (string, error) {
+	return f(ctx, ref)
+}
+
+// EnvResolver resolves ${env:NAME} to the value of the environment variable
+// NAME. It fails if the variable is not set.
+var EnvResolver = SecretResolverFunc(func(ctx context.Context, ref string) (string, error) {
+	value, ok := os.LookupEnv(ref)
+	if !ok {
+		return "", fmt.Errorf("environment variable %s is not set", ref)
+	}
+	return value, nil
+})
+
+// FileResolver resolves ${file:/run/secrets/db} to the content of the file,
+// without the trailing newline.
+var FileResolver = SecretResolverFunc(func(ctx context.Context, ref string) (string, error) {
+	content, err := os.ReadFile(ref)
+	if err != nil {
+		return "", err
+	}
+	return strings.TrimRight(string(content), "\r\n"), nil
+})
+
+// VaultResolver resolves ${vault:path#field} by reading the secret at path from
+// the HTTP API of HashiCorp Vault, and picking the field. Both KV version 1 and
+// version 2 engines are supported. For example, ${vault:kv/data/app#password}
+// reads the password field of the secret app in the KV version 2 engine mounted
+// at kv.
+type VaultResolver struct {
+	// Address is the address of Vault, such as https://vault:8200. Defaults to
+	// the VAULT_ADDR environment variable.
+	Address string
+	// Token authenticates the requests. Defaults to the VAULT_TOKEN environment
+	// variable.
+	Token string
+	// Client sends the requests. Defaults to a client with a 10s timeout.
+	Client *http.Client
+}
+
+// Resolve implements SecretResolver.
+func (v VaultResolver) Resolve(ctx context.Context, ref string) (string, error) {
+	path, field, ok := strings.Cut(ref, "#")
+	if !ok || field == "" {
+		return "", fmt.Errorf("vault reference %s must be in the form of path#field", ref)
+	}
+	address, token, client := v.Address, v.Token, v.Client
+	if address == "" {
+		address = os.Getenv("VAULT_ADDR")
+	}
+	if token == "" {
+		token = os.Getenv("VAULT_TOKEN")
+	}
+	if client == nil {
+		client = &http.Client{Timeout: 10 * time.Second}
+	}
+	u := strings.TrimRight(address, "/") + "/v1/" + strings.TrimPrefix(path, "/")
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, u, nil)
+	if err != nil {
+		return "", err
+	}
+	req.Header.Set("X-Vault-Token", token)
+	resp, err := client.Do(req)
+	if err != nil {
+		return "", err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return "", fmt.Errorf("vault responded %s for %s", resp.Status, path)
+	}
+
+	var body struct {
+		Data map[string]any `json:"data"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&body); err != nil {
+		return "", fmt.Errorf("failed to decode vault response for %s: %w", path, err)
+	}
+	data := body.Data
+	// The data of KV version 2 is nested along with the metadata.
+	if nested, ok := data["data"].(map[string]any); ok {
+		if _, ok := data["metadata"]; ok {
+			data = nested
+		}
+	}
+	value, ok := data[field]
+	if !ok {
+		return "", fmt.Errorf("field %s not found in vault secret %s", field, path)
+	}
+	if s, ok := value.(string); ok {
+		return s, nil
+	}
+	return fmt.Sprint(value), nil
+}
+
+var secretRef = regexp.MustCompile(`\$\{([a-zA-Z][a-zA-Z0-9_-]*):([^}]*)\}`)
+
+// resolveSecrets replaces the secret references in the string values of the
+// config tree. References of unknown schemes are left untouched. The paths of
+// the resolved values and the secrets themselves are collected in the secrets.
+func resolveSecrets(ctx context.Context, resolvers map[string]SecretResolver, tree map[string]any) (*secrets, error) {
+	s := &secrets{paths: make(map[string]bool)}
+	if len(resolvers) == 0 {
+		return s, nil
+	}
+	var resolve func(path string, v any) (any, bool, error)
+	resolve = func(path string, v any) (any, bool, error) {
+		switch x := v.(type) {
+		case string:
+			var (
+				err      error
+				resolved bool
+			)
+			out := secretRef.ReplaceAllStringFunc(x, func(ref string) string {
+				m := secretRef.FindStringSubmatch(ref)
+				resolver, ok := resolvers[m[1]]
+				if !ok || err != nil {
+					return ref
+				}
+				var value string
+				value, err = resolver.Resolve(ctx, m[2])
+				if err != nil {
+					err = fmt.Errorf("failed to resolve %s at %s: %w", ref, path, err)
+					return ref
+				}
+				resolved = true
+				if value != "" {
+					s.values = append(s.values, value)
+				}
+				return value
+			})
+			return out, resolved, err
+		case map[string]any:
+			for key, value := range x {
+				child := key
+				if path != "" {
+					child = path + "." + key
+				}
+				out, resolved, err := resolve(child, value)
+				if err != nil {
+					return nil, false, err
+				}
+				if resolved {
+					s.paths[child] = true
+				}
+				x[key] = out
+			}
+			return x, false, nil
+		case []any:
+			var found bool
+			for i, value := range x {
+				out, resolved, err := resolve(path, value)
+				if err != nil {
+					return nil, false, err
+				}
+				found = found || resolved
+				x[i] = out
+			}
+			return x, found, nil
+		}
+		return v, false, nil
+	}
+	if _, _, err := resolve("", tree); err != nil {
+		return nil, err
+	}
+	// Replace longer secrets first, in case one contains another.
+	sort.Slice(s.values, func(i, j int) bool { return len(s.values[i]) > len(s.values[j]) })
+	return s, nil
+}
+
+// secrets records the values resolved from secret references.
+type secrets struct {
+	paths  map[string]bool
+	values []string
+}
+
+func (s *secrets) isSecret(path string) bool {
+	return s != nil && s.paths[path]
+}
+
+func (s *secrets) redact(text string) string {
+	if s == nil {
+		return text
+	}
+	for _, value := range s.values {
+		if len(value) < minRedactLength {
+			if text == value {
+				return SecretMask
+			}
+			continue
+		}
+		text = strings.ReplaceAll(text, value, SecretMask)
+	}
+	return text
+}
+
+// redactTree returns a copy of the config tree with the secrets redacted.
+func (s *secrets) redactTree(path string, v any) any {
+	switch x := v.(type) {
+	case map[string]any:
+		out := make(map[string]any, len(x))
+		for key, value := range x {
+			child := key
+			if path != "" {
+				child = path + "." + key
+			}
+			out[key] = s.redactTree(child, value)
+		}
+		return out
+	case []any:
+		if s.isSecret(path) {
+			return SecretMask
+		}
+		return x
+	}
+	if s.isSecret(path) {
+		return SecretMask
+	}
+	return v
+}
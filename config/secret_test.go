@@ -0,0 +1,129 @@
+package config
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/DoNewsCode/core/contract/lifecycle"
+	"github.com/DoNewsCode/core/events"
+	"github.com/knadh/koanf/providers/confmap"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestKoanfAdapter_secrets(t *testing.T) {
+	t.Setenv("DB_PASS", "hunter2")
+	path := filepath.Join(t.TempDir(), "secret")
+	os.WriteFile(path, []byte("s3cr3t\n"), 0o644)
+
+	vault := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("X-Vault-Token") != "token" {
+			w.WriteHeader(http.StatusForbidden)
+			return
+		}
+		switch r.URL.Path {
+		case "/v1/kv/data/app":
+			w.Write([]byte(`{"data": {"data": {"password": "v2pass"}, "metadata": {"version": 1}}}`))
+		case "/v1/secret/app":
+			w.Write([]byte(`{"data": {"password": "v1pass"}}`))
+		default:
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+	defer vault.Close()
+
+	ka, err := NewConfig(
+		WithProviderLayer(confmap.Provider(map[string]any{
+			"gorm.default.dsn":        "root:${env:DB_PASS}@tcp(127.0.0.1:3306)/app",
+			"redis.default.password":  "${file:" + path + "}",
+			"s3.default.accessSecret": "${vault:kv/data/app#password}",
+			"s3.backup.accessSecret":  "${vault:secret/app#password}",
+			"http.addr":               "${unknown:foo}",
+			"kafka.brokers":           []any{"${env:DB_PASS}", "b"},
+		}, "."), nil),
+		WithSecretResolver("vault", VaultResolver{Address: vault.URL, Token: "token"}),
+	)
+	assert.NoError(t, err)
+	assert.Equal(t, "root:hunter2@tcp(127.0.0.1:3306)/app", ka.String("gorm.default.dsn"))
+	assert.Equal(t, "s3cr3t", ka.String("redis.default.password"))
+	assert.Equal(t, "v2pass", ka.String("s3.default.accessSecret"))
+	assert.Equal(t, "v1pass", ka.String("s3.backup.accessSecret"))
+	assert.Equal(t, "${unknown:foo}", ka.String("http.addr"))
+	assert.Equal(t, []string{"hunter2", "b"}, ka.Strings("kafka.brokers"))
+
+	assert.True(t, ka.IsSecret("gorm.default.dsn"))
+	assert.True(t, ka.IsSecret("kafka.brokers"))
+	assert.False(t, ka.IsSecret("http.addr"))
+	assert.Equal(t, "failed to connect with ******", ka.Redact("failed to connect with v2pass"))
+
+	redacted := ka.RedactedRaw()
+	assert.Equal(t, SecretMask, redacted["gorm"].(map[string]any)["default"].(map[string]any)["dsn"])
+	assert.Equal(t, SecretMask, redacted["kafka"].(map[string]any)["brokers"])
+	assert.Equal(t, "${unknown:foo}", redacted["http"].(map[string]any)["addr"])
+}
+
+func TestKoanfAdapter_shortSecrets(t *testing.T) {
+	t.Setenv("CORE_SHORT_SECRET", "dev")
+	ka, err := NewConfig(
+		WithProviderLayer(confmap.Provider(map[string]any{"redis.default.password": "${env:CORE_SHORT_SECRET}"}, "."), nil),
+	)
+	assert.NoError(t, err)
+	assert.Equal(t, SecretMask, ka.Redact("dev"))
+	assert.Equal(t, "serving the dev environment", ka.Redact("serving the dev environment"))
+}
+
+func TestKoanfAdapter_secretsError(t *testing.T) {
+	_, err := NewConfig(
+		WithProviderLayer(confmap.Provider(map[string]any{"foo": "${env:CORE_NOT_EXIST}"}, "."), nil),
+	)
+	assert.ErrorContains(t, err, "${env:CORE_NOT_EXIST} at foo")
+
+	_, err = NewConfig(
+		WithProviderLayer(confmap.Provider(map[string]any{"foo": "${custom:bar}"}, "."), nil),
+		WithSecretResolver("custom", SecretResolverFunc(func(ctx context.Context, ref string) (string, error) {
+			return "", errors.New("denied")
+		})),
+	)
+	assert.ErrorContains(t, err, "denied")
+}
+
+func TestVaultResolver(t *testing.T) {
+	vault := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusForbidden)
+	}))
+	defer vault.Close()
+
+	_, err := VaultResolver{Address: vault.URL}.Resolve(context.Background(), "kv/data/app#password")
+	assert.ErrorContains(t, err, "403")
+	_, err = VaultResolver{Address: vault.URL}.Resolve(context.Background(), "kv/data/app")
+	assert.ErrorContains(t, err, "path#field")
+}
+
+func TestKoanfAdapter_secretChanges(t *testing.T) {
+	t.Setenv("CORE_DB_PASS", "hunter2")
+	data := map[string]any{"gorm.default.dsn": "${env:CORE_DB_PASS}", "log.level": "info"}
+	var changes lifecycle.ConfigChanges
+	dispatcher := &events.Event[lifecycle.ConfigReloadPayload]{}
+	dispatcher.On(func(ctx context.Context, payload lifecycle.ConfigReloadPayload) error {
+		changes = payload.Changes
+		return nil
+	})
+	conf, err := NewConfig(WithDispatcher(dispatcher), WithProviderLayer(readerFunc(func() (map[string]any, error) {
+		return data, nil
+	}), nil))
+	assert.NoError(t, err)
+	assert.True(t, conf.HasSecrets())
+
+	t.Setenv("CORE_DB_PASS", "hunter3")
+	assert.NoError(t, conf.Reload())
+	assert.Equal(t, lifecycle.ConfigChanges{"gorm.default.dsn": {Old: SecretMask, New: SecretMask}}, changes)
+
+	data = map[string]any{"gorm.default.dsn": "root@db", "log.level": "info"}
+	assert.NoError(t, conf.Reload())
+	assert.Equal(t, lifecycle.ConfigChanges{"gorm.default.dsn": {Old: SecretMask, New: "root@db"}}, changes)
+	assert.False(t, conf.HasSecrets())
+}
@@ -0,0 +1,208 @@
+package config
+
+import (
+	"fmt"
+	"reflect"
+	"regexp"
+	"strings"
+
+	"github.com/go-playground/validator/v10"
+)
+
+// FieldError is a violation of a validate tag.
+type FieldError struct {
+	// Path is the key path of the field, such as gorm.default.pool.maxOpenConns.
+	Path string
+	// Rule is the violated rule, such as min=0.
+	Rule string
+	// Message describes the violation.
+	Message string
+}
+
+// Error implements error.
+func (e FieldError) Error() string {
+	return e.Path + " " + e.Message
+}
+
+// ValidationErrors are the violations of the validate tags in a config.
+type ValidationErrors []FieldError
+
+// Error implements error.
+func (e ValidationErrors) Error() string {
+	messages := make([]string, len(e))
+	for i := range e {
+		messages[i] = e[i].Error()
+	}
+	return strings.Join(messages, "; ")
+}
+
+// tagValidate validates the structs against their validate tags. The fields are
+// named after their json tags, and Duration is validated as a time.Duration.
+var tagValidate = func() *validator.Validate {
+	v := validator.New()
+	v.RegisterTagNameFunc(func(field reflect.StructField) string {
+		name, _, _ := strings.Cut(field.Tag.Get("json"), ",")
+		if name == "-" {
+			return ""
+		}
+		return name
+	})
+	v.RegisterCustomTypeFunc(func(v reflect.Value) any {
+		return v.Interface().(Duration).Duration
+	}, Duration{})
+	return v
+}()
+
+// mapKeyPattern matches the map keys in the namespaces of the validator, such as
+// [default] in gorm[default].database. Slice indexes are left alone.
+var mapKeyPattern = regexp.MustCompile(`\[([^\]]*[^\]0-9][^\]]*)\]`)
+
+// ValidateStruct validates v against the validate tags of its fields, such as:
+//
+//	type poolConf struct {
+//		Addr         string `json:"addr" validate:"required,hostname_port"`
+//		MaxOpenConns int    `json:"maxOpenConns" validate:"min=0"`
+//	}
+//
+// The tags are evaluated by github.com/go-playground/validator/v10, see its
+// documentation for the rules. Nested structs are always validated, while the
+// elements of maps and slices are validated only after a dive rule. v itself
+// may also be a map or a slice of structs, such as map[string]poolConf.
+//
+// The violations are reported with the key paths built from the json tags,
+// prefixed by path. If there are any, the returned error is ValidationErrors.
+func ValidateStruct(path string, v any) (err error) {
+	defer func() {
+		// The validator panics on malformed tags.
+		if r := recover(); r != nil {
+			err = fmt.Errorf("%s has an invalid validate tag: %v", path, r)
+		}
+	}()
+
+	var errs ValidationErrors
+	validateValue(path, reflect.ValueOf(v), &errs)
+	if len(errs) > 0 {
+		return errs
+	}
+	return nil
+}
+
+// validateValue validates the structs in v, which is a struct or a map or a
+// slice of them.
+func validateValue(path string, v reflect.Value, errs *ValidationErrors) {
+	for v.Kind() == reflect.Interface || v.Kind() == reflect.Pointer {
+		if v.IsNil() {
+			return
+		}
+		v = v.Elem()
+	}
+
+	switch v.Kind() {
+	case reflect.Struct:
+		if v.Type() == reflect.TypeOf(Duration{}) {
+			return
+		}
+		err := tagValidate.Struct(v.Interface())
+		fieldErrs, ok := err.(validator.ValidationErrors)
+		if !ok {
+			return
+		}
+		for _, fieldErr := range fieldErrs {
+			*errs = append(*errs, fieldError(path, fieldErr))
+		}
+	case reflect.Map:
+		keys := v.MapKeys()
+		names := make(map[string]any, len(keys))
+		for _, key := range keys {
+			names[fmt.Sprint(key.Interface())] = key
+		}
+		for _, name := range sortedKeys(names) {
+			validateValue(joinPath(path, name), v.MapIndex(names[name].(reflect.Value)), errs)
+		}
+	case reflect.Slice, reflect.Array:
+		for i := 0; i < v.Len(); i++ {
+			validateValue(fmt.Sprintf("%s[%d]", path, i), v.Index(i), errs)
+		}
+	}
+}
+
+// fieldError converts the error of the validator, whose namespace starts with
+// the name of the struct type.
+func fieldError(path string, err validator.FieldError) FieldError {
+	_, namespace, _ := strings.Cut(err.Namespace(), ".")
+	namespace = mapKeyPattern.ReplaceAllString(namespace, ".$1")
+	rule := err.Tag()
+	if err.Param() != "" {
+		rule += "=" + err.Param()
+	}
+	message := "does not satisfy " + rule
+	if rule == "required" {
+		message = "is required"
+	}
+	return FieldError{Path: joinPath(path, namespace), Rule: rule, Message: message}
+}
+
+// tagValidator returns a validator of the typed values in the data of an
+// exported config, or nil if they don't have any validate tag. The values are
+// unmarshalled from the config into the same types as the default values.
+func tagValidator(data map[string]any) Validator {
+	types := make(map[string]reflect.Type)
+	for key, value := range data {
+		if t := reflect.TypeOf(value); t != nil && hasValidateTags(t, make(map[reflect.Type]bool)) {
+			types[key] = t
+		}
+	}
+	if len(types) == 0 {
+		return nil
+	}
+	return func(data map[string]any) error {
+		conf := MapAdapter(data)
+		var errs ValidationErrors
+		for _, key := range sortedTypeKeys(types) {
+			value := reflect.New(types[key])
+			if err := conf.Unmarshal(key, value.Interface()); err != nil {
+				return fmt.Errorf("%s is not valid: %w", key, err)
+			}
+			err := ValidateStruct(key, value.Interface())
+			if fieldErrs, ok := err.(ValidationErrors); ok {
+				errs = append(errs, fieldErrs...)
+			} else if err != nil {
+				return err
+			}
+		}
+		if len(errs) > 0 {
+			return errs
+		}
+		return nil
+	}
+}
+
+func sortedTypeKeys(types map[string]reflect.Type) []string {
+	keys := make(map[string]any, len(types))
+	for key := range types {
+		keys[key] = nil
+	}
+	return sortedKeys(keys)
+}
+
+func hasValidateTags(t reflect.Type, seen map[reflect.Type]bool) bool {
+	if seen[t] {
+		return false
+	}
+	seen[t] = true
+	switch t.Kind() {
+	case reflect.Pointer, reflect.Slice, reflect.Array, reflect.Map:
+		return hasValidateTags(t.Elem(), seen)
+	case reflect.Struct:
+		for i := 0; i < t.NumField(); i++ {
+			field := t.Field(i)
+			if !field.IsExported() {
+				continue
+			}
+			if _, ok := field.Tag.Lookup("validate"); ok || hasValidateTags(field.Type, seen) {
+				return true
+			}
+		}
+	}
+	return false
+}
@@ -0,0 +1,123 @@
+package config
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+type validatePool struct {
+	MaxOpenConns int           `json:"maxOpenConns" validate:"min=0,max=100"`
+	Idle         Duration      `json:"idle" validate:"max=1m"`
+	Timeout      time.Duration `json:"timeout" validate:"omitempty,min=1s"`
+}
+
+type validateConf struct {
+	Driver string            `json:"driver" validate:"required,oneof=mysql sqlite"`
+	Addrs  []string          `json:"addrs" validate:"min=1,dive,hostname_port"`
+	URL    string            `json:"url" validate:"omitempty,url"`
+	Host   string            `json:"host" validate:"omitempty,hostname"`
+	IP     string            `json:"ip" validate:"omitempty,ip"`
+	Name   string            `json:"name" validate:"max=3"`
+	Pool   validatePool      `json:"pool"`
+	Tags   map[string]string `json:"tags" validate:"dive,required"`
+}
+
+func TestValidateStruct(t *testing.T) {
+	valid := validateConf{
+		Driver: "mysql",
+		Addrs:  []string{"localhost:3306", ":3306", "127.0.0.1:3306"},
+		URL:    "http://example.com",
+		Host:   "example.com",
+		IP:     "::1",
+		Pool:   validatePool{MaxOpenConns: 10, Timeout: time.Second},
+		Tags:   map[string]string{"a": "b"},
+	}
+	assert.NoError(t, ValidateStruct("db", valid))
+	assert.NoError(t, ValidateStruct("db", &valid))
+	assert.NoError(t, ValidateStruct("db", map[string]validateConf{}))
+
+	invalid := validateConf{
+		Addrs: []string{"localhost", "localhost:0", "[::1]:3306"},
+		URL:   "/relative",
+		Host:  "-bad-",
+		IP:    "256.0.0.1",
+		Name:  "toolong",
+		Pool: validatePool{
+			MaxOpenConns: -1,
+			Idle:         Duration{Duration: time.Hour},
+			Timeout:      time.Millisecond,
+		},
+		Tags: map[string]string{"b": ""},
+	}
+	err := ValidateStruct("db", map[string]validateConf{"default": invalid})
+	assert.Equal(t, ValidationErrors{
+		{Path: "db.default.driver", Rule: "required", Message: "is required"},
+		// The host of hostname_port must be a RFC 1123 hostname, and the port
+		// must be within 1 and 65535.
+		{Path: "db.default.addrs[0]", Rule: "hostname_port", Message: "does not satisfy hostname_port"},
+		{Path: "db.default.addrs[1]", Rule: "hostname_port", Message: "does not satisfy hostname_port"},
+		{Path: "db.default.addrs[2]", Rule: "hostname_port", Message: "does not satisfy hostname_port"},
+		{Path: "db.default.url", Rule: "url", Message: "does not satisfy url"},
+		{Path: "db.default.host", Rule: "hostname", Message: "does not satisfy hostname"},
+		{Path: "db.default.ip", Rule: "ip", Message: "does not satisfy ip"},
+		// min and max bound the length of strings.
+		{Path: "db.default.name", Rule: "max=3", Message: "does not satisfy max=3"},
+		{Path: "db.default.pool.maxOpenConns", Rule: "min=0", Message: "does not satisfy min=0"},
+		// min and max bound durations with duration parameters.
+		{Path: "db.default.pool.idle", Rule: "max=1m", Message: "does not satisfy max=1m"},
+		{Path: "db.default.pool.timeout", Rule: "min=1s", Message: "does not satisfy min=1s"},
+		{Path: "db.default.tags.b", Rule: "required", Message: "is required"},
+	}, err)
+	assert.EqualError(t, ValidateStruct("", validateConf{Driver: "oracle", Addrs: []string{":1"}}), "driver does not satisfy oneof=mysql sqlite")
+
+	// omitempty skips the zero values, and min=1 bounds the length of slices.
+	err = ValidateStruct("db", validateConf{Driver: "sqlite"})
+	assert.Equal(t, ValidationErrors{{Path: "db.addrs", Rule: "min=1", Message: "does not satisfy min=1"}}, err)
+
+	// The elements of maps are not validated without dive.
+	assert.NoError(t, ValidateStruct("db", struct {
+		Conns map[string]validateConf `json:"conns"`
+	}{Conns: map[string]validateConf{"default": {}}}))
+
+	assert.ErrorContains(t, ValidateStruct("db", struct {
+		Name string `json:"name" validate:"unknown"`
+	}{}), "db has an invalid validate tag")
+}
+
+func TestTagValidator(t *testing.T) {
+	assert.Nil(t, tagValidator(map[string]any{"foo": "bar", "pool": Duration{}}))
+
+	validate := tagValidator(map[string]any{
+		"foo": "bar",
+		"db":  map[string]validateConf{},
+	})
+	assert.NoError(t, validate(map[string]any{
+		"db": map[string]any{"default": map[string]any{"driver": "sqlite", "addrs": []any{":3306"}}},
+	}))
+	assert.EqualError(t, validate(map[string]any{
+		"db": map[string]any{"default": map[string]any{"driver": "sqlite", "addrs": []any{":3306"}, "pool": map[string]any{"maxOpenConns": -1}}},
+	}), "db.default.pool.maxOpenConns does not satisfy min=0")
+	assert.ErrorContains(t, validate(map[string]any{
+		"db": map[string]any{"default": map[string]any{"unknown": true}},
+	}), "db is not valid")
+}
+
+func TestModule_validateTags(t *testing.T) {
+	conns := 10
+	conf, err := NewConfig(WithProviderLayer(readerFunc(func() (map[string]any, error) {
+		return map[string]any{"db": map[string]any{
+			"default": map[string]any{"driver": "sqlite", "addrs": []any{":3306"}, "pool": map[string]any{"maxOpenConns": conns}},
+		}}, nil
+	}), nil))
+	assert.NoError(t, err)
+	_, err = New(ConfigIn{Conf: conf, ExportedConfigs: []ExportedConfig{
+		{Owner: "db", Data: map[string]any{"db": map[string]validateConf{}}},
+	}})
+	assert.NoError(t, err)
+
+	conns = 1000
+	assert.EqualError(t, conf.Reload(), "validation failed: db.default.pool.maxOpenConns does not satisfy max=100")
+	assert.Equal(t, 10, conf.Int("db.default.pool.maxOpenConns"))
+}
@@ -0,0 +1,105 @@
+package watcher
+
+import (
+	"context"
+	"fmt"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/fsnotify/fsnotify"
+	"github.com/pkg/errors"
+)
+
+// Dir is a watcher implementation to watch the changes of the files in a
+// directory, such as a mounted Kubernetes ConfigMap or a conf.d directory.
+//
+// Changes often come in bursts. Kubernetes, for instance, updates a ConfigMap by
+// writing a new timestamped directory and swapping the ..data symlink to it,
+// which fires a series of events. The events are debounced, so that a burst
+// results in a single reload once the directory has been quiet for the
+// Debounce period.
+type Dir struct {
+	// Path is the directory.
+	Path string
+	// Pattern is the glob pattern of the file names to watch. Changes to the
+	// hidden entries of Kubernetes, such as ..data, are always watched. Defaults
+	// to "*.yaml".
+	Pattern string
+	// Debounce is the quiet period after the last event before reloading.
+	// Defaults to 100ms.
+	Debounce time.Duration
+}
+
+// Watch watches the changes in the directory. Once a burst of changes has
+// settled, the reload function is called. Like File, the reload function should
+// reload the whole config stack.
+func (d Dir) Watch(ctx context.Context, reload func() error) error {
+	pattern, debounce := d.Pattern, d.Debounce
+	if pattern == "" {
+		pattern = "*.yaml"
+	}
+	if debounce <= 0 {
+		debounce = 100 * time.Millisecond
+	}
+	dir := filepath.Clean(d.Path)
+
+	w, err := fsnotify.NewWatcher()
+	if err != nil {
+		return err
+	}
+	defer w.Close()
+
+	if err := w.Add(dir); err != nil {
+		return errors.Wrap(err, "unable to add watch dir")
+	}
+
+	timer := time.NewTimer(debounce)
+	timer.Stop()
+	defer timer.Stop()
+
+	for {
+		select {
+		case event, ok := <-w.Events:
+			if !ok {
+				return errors.New("fsnotify watch channel closed")
+			}
+
+			evFile := filepath.Clean(event.Name)
+			if evFile == dir && event.Op&(fsnotify.Remove|fsnotify.Rename) != 0 {
+				return fmt.Errorf("dir %s was removed", d.Path)
+			}
+			name := filepath.Base(evFile)
+			if matched, _ := filepath.Match(pattern, name); !matched && !strings.HasPrefix(name, "..") {
+				continue
+			}
+			if event.Op == fsnotify.Chmod {
+				continue
+			}
+
+			// Restart the quiet period.
+			if !timer.Stop() {
+				select {
+				case <-timer.C:
+				default:
+				}
+			}
+			timer.Reset(debounce)
+
+		case <-timer.C:
+			if err := reload(); err != nil {
+				return err
+			}
+
+		// There's an error.
+		case err, ok := <-w.Errors:
+			if !ok {
+				return errors.New("fsnotify err channel closed")
+			}
+
+			return err
+		case <-ctx.Done():
+			return nil
+		}
+	}
+}
@@ -0,0 +1,57 @@
+package watcher
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"go.uber.org/atomic"
+)
+
+func TestDir_Watch(t *testing.T) {
+	t.Parallel()
+	dir := t.TempDir()
+	var reloads atomic.Int32
+
+	w := Dir{Path: dir, Debounce: 200 * time.Millisecond}
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	go w.Watch(ctx, func() error {
+		reloads.Inc()
+		return nil
+	})
+	time.Sleep(time.Second)
+
+	// A burst of changes, like a ConfigMap update, results in a single reload.
+	assert.NoError(t, os.Mkdir(filepath.Join(dir, "..2021_01_01"), os.ModePerm))
+	assert.NoError(t, os.WriteFile(filepath.Join(dir, "..2021_01_01", "a.yaml"), []byte("foo: bar"), os.ModePerm))
+	assert.NoError(t, os.Symlink(filepath.Join(dir, "..2021_01_01"), filepath.Join(dir, "..data_tmp")))
+	assert.NoError(t, os.Rename(filepath.Join(dir, "..data_tmp"), filepath.Join(dir, "..data")))
+	for i := 0; i < 5; i++ {
+		assert.NoError(t, os.WriteFile(filepath.Join(dir, "b.yaml"), []byte("foo: baz"), os.ModePerm))
+	}
+	// Other files are ignored.
+	assert.NoError(t, os.WriteFile(filepath.Join(dir, "notes.txt"), []byte("foo"), os.ModePerm))
+
+	assert.Eventually(t, func() bool { return reloads.Load() == 1 }, 2*time.Second, 10*time.Millisecond)
+	time.Sleep(500 * time.Millisecond)
+	assert.Equal(t, int32(1), reloads.Load())
+
+	assert.NoError(t, os.WriteFile(filepath.Join(dir, "b.yaml"), []byte("foo: qux"), os.ModePerm))
+	assert.Eventually(t, func() bool { return reloads.Load() == 2 }, 2*time.Second, 10*time.Millisecond)
+}
+
+func TestDir_Watch_cancel(t *testing.T) {
+	t.Parallel()
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan error)
+	go func() { done <- Dir{Path: t.TempDir()}.Watch(ctx, func() error { return nil }) }()
+	cancel()
+	assert.NoError(t, <-done)
+
+	assert.Error(t, Dir{Path: filepath.Join(t.TempDir(), "missing")}.Watch(context.Background(), func() error { return nil }))
+}
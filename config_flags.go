@@ -0,0 +1,194 @@
+package core
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"sync"
+
+	"github.com/DoNewsCode/core/codec/yaml"
+	"github.com/DoNewsCode/core/config"
+
+	"github.com/knadh/koanf"
+	"github.com/knadh/koanf/providers/confmap"
+	"github.com/knadh/koanf/providers/file"
+	"github.com/spf13/cobra"
+	yamlv3 "gopkg.in/yaml.v3"
+)
+
+// argsProvider is the configuration layer of the --config and --set flags. It
+// is on top of the configuration stack. The files are merged in order, and the
+// values set by --set take precedence over the files.
+type argsProvider struct {
+	mu    sync.Mutex
+	files []string
+	sets  []string
+}
+
+// scanArgs finds the --config and --set flags in the command-line arguments.
+// The arguments are scanned when the core is created, before the root command
+// parses them, so that the flags take effect before any module is constructed.
+func scanArgs(args []string) (files, sets []string) {
+	for i := 0; i < len(args); i++ {
+		arg := args[i]
+		if arg == "--" {
+			break
+		}
+		for _, flag := range []struct {
+			name   string
+			values *[]string
+		}{{"--config", &files}, {"--set", &sets}} {
+			if strings.HasPrefix(arg, flag.name+"=") {
+				*flag.values = append(*flag.values, strings.TrimPrefix(arg, flag.name+"="))
+			}
+			if arg == flag.name && i+1 < len(args) {
+				i++
+				*flag.values = append(*flag.values, args[i])
+			}
+		}
+	}
+	return files, sets
+}
+
+// ReadBytes is not supported by argsProvider.
+func (p *argsProvider) ReadBytes() ([]byte, error) {
+	return nil, errors.New("args provider does not support this method")
+}
+
+// Read merges the config files and the values set on the command line.
+func (p *argsProvider) Read() (map[string]any, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	k := koanf.New(".")
+	for _, path := range p.files {
+		if err := k.Load(file.Provider(path), config.CodecParser{Codec: yaml.Codec{}}); err != nil {
+			return nil, fmt.Errorf("failed to load --config %s: %w", path, err)
+		}
+	}
+	for _, set := range p.sets {
+		key, value, ok := strings.Cut(set, "=")
+		if !ok || key == "" {
+			return nil, fmt.Errorf("--set expects path=value, got %s", set)
+		}
+		if err := k.Load(confmap.Provider(map[string]any{key: parseArgValue(value)}, "."), nil); err != nil {
+			return nil, fmt.Errorf("failed to load --set %s: %w", set, err)
+		}
+	}
+	return k.Raw(), nil
+}
+
+// update replaces the flags. It reports whether they have changed.
+func (p *argsProvider) update(files, sets []string) bool {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if equalStrings(p.files, files) && equalStrings(p.sets, sets) {
+		return false
+	}
+	p.files, p.sets = files, sets
+	return true
+}
+
+// parseArgValue parses the value as YAML, so that true is a bool and [a, b] is a
+// list. An empty value is an empty string.
+func parseArgValue(value string) any {
+	var v any
+	if value == "" || yamlv3.Unmarshal([]byte(value), &v) != nil {
+		return value
+	}
+	return v
+}
+
+func equalStrings(a, b []string) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if a[i] != b[i] {
+			return false
+		}
+	}
+	return true
+}
+
+// configFlagAnnotation marks the flags added by applyConfigFlags.
+const configFlagAnnotation = "core_config_flag"
+
+// applyConfigFlags adds the persistent --config and --set flags to the root
+// command. The flags found in os.Args are already applied when the core is
+// created. If the command is executed with other flags, the configuration is
+// reloaded with them before the command runs.
+//
+// Cobra only runs the persistent pre-run hook nearest to the executed command,
+// so the reload is chained into the hook of the root command, and into the hook
+// of every subcommand that defines its own. Call applyConfigFlags after the
+// subcommands are added.
+//
+// A flag already defined by the root command is left alone, and does not
+// override the configuration.
+func (c *C) applyConfigFlags(command *cobra.Command) {
+	var files, sets []string
+	for _, flag := range []struct {
+		name   string
+		values *[]string
+		usage  string
+	}{
+		{"config", &files, "load a YAML config file on top of the configuration stack, repeatable"},
+		{"set", &sets, "override a config value, such as --set http.disable=true, repeatable"},
+	} {
+		if f := command.PersistentFlags().Lookup(flag.name); f != nil {
+			if _, ok := f.Annotations[configFlagAnnotation]; ok {
+				// The flags are applied already.
+				return
+			}
+			c.logger.Warnf("the --%s flag is already defined by the root command, and does not override the configuration", flag.name)
+			continue
+		}
+		if command.Flags().Lookup(flag.name) != nil {
+			c.logger.Warnf("the --%s flag is already defined by the root command, and does not override the configuration", flag.name)
+			continue
+		}
+		command.PersistentFlags().StringArrayVar(flag.values, flag.name, nil, flag.usage)
+		command.PersistentFlags().SetAnnotation(flag.name, configFlagAnnotation, nil)
+	}
+
+	reload := func() error {
+		if !c.args.update(files, sets) {
+			return nil
+		}
+		reloader, ok := c.conf.(interface{ Reload() error })
+		if !ok {
+			return fmt.Errorf("the config %T cannot be reloaded with --config and --set", c.conf)
+		}
+		return reloader.Reload()
+	}
+	var walk func(cmd *cobra.Command)
+	walk = func(cmd *cobra.Command) {
+		if cmd == command || cmd.PersistentPreRunE != nil || cmd.PersistentPreRun != nil {
+			chainPersistentPreRun(cmd, reload)
+		}
+		for _, sub := range cmd.Commands() {
+			walk(sub)
+		}
+	}
+	walk(command)
+}
+
+// chainPersistentPreRun runs the hook before the persistent pre-run hook of the
+// command.
+func chainPersistentPreRun(command *cobra.Command, hook func() error) {
+	preRunE, preRun := command.PersistentPreRunE, command.PersistentPreRun
+	command.PersistentPreRunE = func(cmd *cobra.Command, args []string) error {
+		if err := hook(); err != nil {
+			return err
+		}
+		if preRunE != nil {
+			return preRunE(cmd, args)
+		}
+		if preRun != nil {
+			preRun(cmd, args)
+		}
+		return nil
+	}
+}
@@ -0,0 +1,124 @@
+package core
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/spf13/cobra"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestScanArgs(t *testing.T) {
+	files, sets := scanArgs([]string{
+		"serve", "--config", "a.yaml", "--set=log.level=warn", "--config=b.yaml",
+		"--set", "http.disable=true", "--", "--set", "foo=bar",
+	})
+	assert.Equal(t, []string{"a.yaml", "b.yaml"}, files)
+	assert.Equal(t, []string{"log.level=warn", "http.disable=true"}, sets)
+}
+
+func TestC_configFlags(t *testing.T) {
+	dir := t.TempDir()
+	a, b := filepath.Join(dir, "a.yaml"), filepath.Join(dir, "b.yaml")
+	os.WriteFile(a, []byte("log:\n  level: info\nhttp:\n  addr: :8081\n"), 0o644)
+	os.WriteFile(b, []byte("log:\n  level: error\n"), 0o644)
+
+	c := New(WithInline("log.level", "debug"), WithInline("grpc.addr", ":9090"))
+	var (
+		level    string
+		addr     string
+		disabled any
+		grpcAddr string
+	)
+	rootCmd := &cobra.Command{Use: "app"}
+	rootCmd.AddCommand(&cobra.Command{
+		Use: "run",
+		Run: func(cmd *cobra.Command, args []string) {
+			level = c.conf.String("log.level")
+			addr = c.conf.String("http.addr")
+			disabled = c.conf.Get("http.disable")
+			grpcAddr = c.conf.String("grpc.addr")
+		},
+	})
+	c.ApplyRootCommand(rootCmd)
+	rootCmd.SetArgs([]string{"run", "--config", a, "--config", b, "--set", "http.disable=true"})
+	assert.NoError(t, rootCmd.Execute())
+
+	assert.Equal(t, "error", level)
+	assert.Equal(t, ":8081", addr)
+	assert.Equal(t, true, disabled)
+	assert.Equal(t, ":9090", grpcAddr)
+
+	rootCmd.SetArgs([]string{"run", "--set", "log.level"})
+	assert.Error(t, rootCmd.Execute())
+}
+
+func TestC_configFlagsScanned(t *testing.T) {
+	c := New(WithConfigFlags([]string{"serve", "--set", "log.level=warn"}), WithInline("log.level", "debug"))
+	assert.Equal(t, "warn", c.conf.String("log.level"))
+
+	c = New(WithConfigFlags(nil), WithInline("log.level", "debug"))
+	assert.Equal(t, "debug", c.conf.String("log.level"))
+
+	t.Run("os.Args", func(t *testing.T) {
+		args := os.Args
+		defer func() { os.Args = args }()
+		os.Args = []string{"app", "serve", "--set=log.level=warn"}
+
+		c := New(WithInline("log.level", "debug"))
+		assert.Equal(t, "warn", c.conf.String("log.level"))
+	})
+}
+
+func TestC_configFlagsSubcommandHook(t *testing.T) {
+	c := New(WithConfigFlags(nil), WithInline("log.level", "debug"))
+
+	var (
+		hooked bool
+		level  string
+	)
+	rootCmd := &cobra.Command{Use: "app"}
+	rootCmd.AddCommand(&cobra.Command{
+		Use: "run",
+		PersistentPreRunE: func(cmd *cobra.Command, args []string) error {
+			hooked = true
+			return nil
+		},
+		Run: func(cmd *cobra.Command, args []string) {
+			level = c.conf.String("log.level")
+		},
+	})
+	c.ApplyRootCommand(rootCmd)
+	rootCmd.SetArgs([]string{"run", "--set", "log.level=warn"})
+	assert.NoError(t, rootCmd.Execute())
+	assert.True(t, hooked)
+	assert.Equal(t, "warn", level)
+}
+
+func TestC_configFlagsConflict(t *testing.T) {
+	c := New(WithInline("log.level", "debug"))
+
+	var (
+		conf  string
+		level string
+	)
+	rootCmd := &cobra.Command{Use: "app"}
+	rootCmd.PersistentFlags().StringVar(&conf, "config", "", "the config of the root command")
+	rootCmd.AddCommand(&cobra.Command{
+		Use: "run",
+		Run: func(cmd *cobra.Command, args []string) {
+			level = c.conf.String("log.level")
+		},
+	})
+	assert.NotPanics(t, func() { c.ApplyRootCommand(rootCmd) })
+	assert.Nil(t, rootCmd.PersistentFlags().Lookup("config").Annotations)
+
+	rootCmd.SetArgs([]string{"run", "--config", "app.toml", "--set", "log.level=warn"})
+	assert.NoError(t, rootCmd.Execute())
+	assert.Equal(t, "app.toml", conf)
+	assert.Equal(t, "warn", level)
+
+	// Applying twice leaves the flags alone.
+	assert.NotPanics(t, func() { c.ApplyRootCommand(rootCmd) })
+}
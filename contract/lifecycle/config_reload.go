@@ -2,11 +2,78 @@ package lifecycle
 
 import (
 	"context"
+	"strings"
 
 	"github.com/DoNewsCode/core/contract"
 )
 
+// ConfigReload is fired after the configuration is reloaded.
 type ConfigReload interface {
-	Fire(ctx context.Context, Config contract.ConfigUnmarshaler) error
-	On(func(ctx context.Context, Config contract.ConfigUnmarshaler) error) func()
+	Fire(ctx context.Context, payload ConfigReloadPayload) error
+	On(func(ctx context.Context, payload ConfigReloadPayload) error) (unsubscribe func())
+}
+
+// ConfigReloadPayload is the payload of ConfigReload event.
+//
+// The payload used to be the reloaded contract.ConfigUnmarshaler itself. To
+// migrate a listener, take the configuration from the Config field, and use
+// Changes to skip the reloads that don't concern it:
+//
+//	reload.On(func(ctx context.Context, payload lifecycle.ConfigReloadPayload) error {
+//		if !payload.Changes.Changed("redis") {
+//			return nil
+//		}
+//		return payload.Config.Unmarshal("redis", &conf)
+//	})
+type ConfigReloadPayload struct {
+	// Config is the reloaded configuration.
+	Config contract.ConfigUnmarshaler
+	// Changes are the changes made by the reload. A nil Changes means the
+	// changes are unknown.
+	Changes ConfigChanges
+}
+
+// ConfigChange is the change of the value at a key path. Old is nil if the key
+// path is added, and New is nil if it is removed. A value resolved from a
+// secret reference is replaced with a mask.
+type ConfigChange struct {
+	Old any
+	New any
+}
+
+// ConfigChanges are the changes keyed by the leaf key paths, such as
+// redis.default.addrs.
+type ConfigChanges map[string]ConfigChange
+
+// Changed reports whether the value at the key path, or any value under it, is
+// changed. Key paths are compared case-insensitively. If the changes are
+// unknown, that is c is nil, it always reports true.
+func (c ConfigChanges) Changed(path string) bool {
+	if c == nil {
+		return true
+	}
+	path = strings.ToLower(path)
+	for key := range c {
+		key = strings.ToLower(key)
+		if key == path || strings.HasPrefix(key, path+".") {
+			return true
+		}
+	}
+	return false
+}
+
+// ConfigReloadFailed is fired when a reload triggered by the config watcher
+// fails in the tolerant reload mode, and the last good configuration is kept.
+// If a listener returns an error, the watcher stops with it.
+type ConfigReloadFailed interface {
+	Fire(ctx context.Context, payload ConfigReloadFailedPayload) error
+	On(func(ctx context.Context, payload ConfigReloadFailedPayload) error) (unsubscribe func())
+}
+
+// ConfigReloadFailedPayload is the payload of ConfigReloadFailed event
+type ConfigReloadFailedPayload struct {
+	// Err is the reason of the failure.
+	Err error
+	// Config is the last good configuration, which is still in use.
+	Config contract.ConfigUnmarshaler
 }
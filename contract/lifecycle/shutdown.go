@@ -0,0 +1,20 @@
+package lifecycle
+
+import (
+	"context"
+	"time"
+)
+
+// ShutdownStart is fired at the beginning of the graceful shutdown of the serve
+// command, before any server stops accepting traffic. Listeners should use it to
+// mark the application as not ready.
+type ShutdownStart interface {
+	Fire(ctx context.Context, payload ShutdownStartPayload) error
+	On(func(ctx context.Context, payload ShutdownStartPayload) error) (unsubscribe func())
+}
+
+// ShutdownStartPayload is the payload of ShutdownStart event
+type ShutdownStartPayload struct {
+	// Deadline is the time by which the whole shutdown will be forced to complete.
+	Deadline time.Time
+}
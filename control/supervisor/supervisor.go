@@ -0,0 +1,163 @@
+// Package supervisor restarts failed long-running actors, such as kafka
+// consumer loops, with exponential backoff. The serve command uses it to run
+// every core.Runnable, so that a transient failure of one actor does not bring
+// down the whole process:
+//
+//	serve:
+//	  restart:
+//	    maxAttempts: 5
+//	    backoff: 1s
+//	    maxBackoff: 1m
+//	    resetAfter: 10m
+//
+// The attempts are counted in a row: once a restarted actor has run for
+// resetAfter, its next failure starts over with the first attempt.
+//
+// A Runnable can also choose its own policy by implementing
+// core.SupervisedRunnable. The process exits only after the policy is
+// exhausted.
+package supervisor
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/DoNewsCode/core/config"
+	"github.com/DoNewsCode/core/logging"
+
+	"github.com/go-kit/kit/metrics"
+	"github.com/go-kit/log"
+)
+
+const (
+	defaultBackoff    = time.Second
+	defaultMaxBackoff = time.Minute
+)
+
+// Policy is the restart policy of an actor.
+type Policy struct {
+	// MaxAttempts is the maximum number of restarts. Zero disables restarts.
+	MaxAttempts int `json:"maxAttempts" yaml:"maxAttempts"`
+	// Backoff is the delay before the first restart. It doubles on every
+	// restart. Defaults to 1s.
+	Backoff config.Duration `json:"backoff" yaml:"backoff"`
+	// MaxBackoff caps the delay between restarts. Defaults to 1m.
+	MaxBackoff config.Duration `json:"maxBackoff" yaml:"maxBackoff"`
+	// ResetAfter is how long a run has to last to be considered healthy. A
+	// failure after a healthy run starts over from the first attempt and the
+	// initial backoff. Defaults to MaxBackoff.
+	ResetAfter config.Duration `json:"resetAfter" yaml:"resetAfter"`
+}
+
+// resetAfter returns the duration of a healthy run.
+func (p Policy) resetAfter() time.Duration {
+	if p.ResetAfter.Duration > 0 {
+		return p.ResetAfter.Duration
+	}
+	if p.MaxBackoff.Duration > 0 {
+		return p.MaxBackoff.Duration
+	}
+	return defaultMaxBackoff
+}
+
+// delay returns the delay before the given restart, starting from 1.
+func (p Policy) delay(attempt int) time.Duration {
+	backoff, maxBackoff := p.Backoff.Duration, p.MaxBackoff.Duration
+	if backoff <= 0 {
+		backoff = defaultBackoff
+	}
+	if maxBackoff <= 0 {
+		maxBackoff = defaultMaxBackoff
+	}
+	for i := 1; i < attempt && backoff < maxBackoff; i++ {
+		backoff *= 2
+	}
+	if backoff > maxBackoff {
+		return maxBackoff
+	}
+	return backoff
+}
+
+// RestartMetrics counts the restarts of actors.
+type RestartMetrics struct {
+	restarts metrics.Counter
+
+	// labels that have been set
+	module string
+}
+
+// NewRestartMetrics constructs a new *RestartMetrics, setting default labels to "unknown".
+func NewRestartMetrics(counter metrics.Counter) *RestartMetrics {
+	return &RestartMetrics{
+		restarts: counter,
+		module:   "unknown",
+	}
+}
+
+// Module specifies the module label for RestartMetrics.
+func (r *RestartMetrics) Module(module string) *RestartMetrics {
+	return &RestartMetrics{
+		restarts: r.restarts,
+		module:   module,
+	}
+}
+
+// Restart marks the actor as restarted.
+func (r *RestartMetrics) Restart() {
+	r.restarts.With("module", r.module).Add(1)
+}
+
+// Supervisor runs an actor under a restart policy.
+type Supervisor struct {
+	// Name identifies the actor in logs and metrics.
+	Name string
+	// Policy is the restart policy.
+	Policy Policy
+	// Logger logs the restarts. Optional.
+	Logger log.Logger
+	// Metrics counts the restarts. Optional.
+	Metrics *RestartMetrics
+}
+
+// Run calls run until it returns nil or the context is canceled. If run returns
+// an error, it is called again after a backoff, for at most Policy.MaxAttempts
+// times in a row. A run that lasted longer than Policy.ResetAfter resets the
+// count and the backoff. Run returns the last error once the policy is
+// exhausted.
+func (s Supervisor) Run(ctx context.Context, run func(ctx context.Context) error) error {
+	logger := logging.WithLevel(log.NewNopLogger())
+	if s.Logger != nil {
+		logger = logging.WithLevel(s.Logger)
+	}
+	for attempt := 1; ; attempt++ {
+		start := time.Now()
+		err := run(ctx)
+		if err == nil || ctx.Err() != nil {
+			return err
+		}
+		// A run that lasted is not part of a series of failures.
+		if time.Since(start) > s.Policy.resetAfter() {
+			attempt = 1
+		}
+		if attempt > s.Policy.MaxAttempts {
+			if s.Policy.MaxAttempts > 0 {
+				err = fmt.Errorf("%s gave up after %d restarts: %w", s.Name, s.Policy.MaxAttempts, err)
+			}
+			return err
+		}
+
+		delay := s.Policy.delay(attempt)
+		logger.Warnf("%s failed: %s, restarting in %s (%d/%d)", s.Name, err, delay, attempt, s.Policy.MaxAttempts)
+		if s.Metrics != nil {
+			s.Metrics.Module(s.Name).Restart()
+		}
+		timer := time.NewTimer(delay)
+		select {
+		case <-timer.C:
+		case <-ctx.Done():
+			timer.Stop()
+			return nil
+		}
+	}
+}
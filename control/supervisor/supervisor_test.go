@@ -0,0 +1,106 @@
+package supervisor
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/DoNewsCode/core/config"
+	"github.com/DoNewsCode/core/internal/stub"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestPolicy_delay(t *testing.T) {
+	p := Policy{Backoff: config.Duration{Duration: time.Second}, MaxBackoff: config.Duration{Duration: 5 * time.Second}}
+	assert.Equal(t, time.Second, p.delay(1))
+	assert.Equal(t, 2*time.Second, p.delay(2))
+	assert.Equal(t, 4*time.Second, p.delay(3))
+	assert.Equal(t, 5*time.Second, p.delay(4))
+	assert.Equal(t, 5*time.Second, p.delay(100))
+	assert.Equal(t, defaultBackoff, Policy{}.delay(1))
+}
+
+func TestSupervisor_Run(t *testing.T) {
+	policy := Policy{MaxAttempts: 3, Backoff: config.Duration{Duration: time.Millisecond}}
+	errFailed := errors.New("failed")
+
+	t.Run("recovered", func(t *testing.T) {
+		var (
+			calls   int
+			counter = &stub.Counter{}
+		)
+		s := Supervisor{Name: "foo", Policy: policy, Metrics: NewRestartMetrics(counter)}
+		err := s.Run(context.Background(), func(ctx context.Context) error {
+			calls++
+			if calls < 3 {
+				return errFailed
+			}
+			return nil
+		})
+		assert.NoError(t, err)
+		assert.Equal(t, 3, calls)
+		assert.Equal(t, 2.0, counter.CounterValue)
+		assert.Equal(t, []string{"module", "foo"}, counter.LabelValues)
+	})
+
+	t.Run("exhausted", func(t *testing.T) {
+		var calls int
+		s := Supervisor{Name: "foo", Policy: policy}
+		err := s.Run(context.Background(), func(ctx context.Context) error {
+			calls++
+			return errFailed
+		})
+		assert.ErrorIs(t, err, errFailed)
+		assert.Equal(t, 4, calls)
+	})
+
+	t.Run("reset after a healthy run", func(t *testing.T) {
+		var calls int
+		s := Supervisor{Name: "foo", Policy: Policy{
+			MaxAttempts: 1,
+			Backoff:     config.Duration{Duration: time.Millisecond},
+			ResetAfter:  config.Duration{Duration: 20 * time.Millisecond},
+		}}
+		err := s.Run(context.Background(), func(ctx context.Context) error {
+			calls++
+			if calls == 2 {
+				time.Sleep(30 * time.Millisecond)
+			}
+			return errFailed
+		})
+		assert.ErrorIs(t, err, errFailed)
+		assert.Equal(t, 3, calls)
+	})
+
+	t.Run("no restart", func(t *testing.T) {
+		var calls int
+		err := Supervisor{}.Run(context.Background(), func(ctx context.Context) error {
+			calls++
+			return errFailed
+		})
+		assert.Equal(t, errFailed, err)
+		assert.Equal(t, 1, calls)
+	})
+
+	t.Run("canceled", func(t *testing.T) {
+		ctx, cancel := context.WithCancel(context.Background())
+		s := Supervisor{Policy: policy}
+		err := s.Run(ctx, func(ctx context.Context) error {
+			cancel()
+			return errFailed
+		})
+		assert.ErrorIs(t, err, errFailed)
+	})
+
+	t.Run("canceled during backoff", func(t *testing.T) {
+		ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+		defer cancel()
+		s := Supervisor{Policy: Policy{MaxAttempts: 3, Backoff: config.Duration{Duration: time.Hour}}}
+		err := s.Run(ctx, func(ctx context.Context) error {
+			return errFailed
+		})
+		assert.NoError(t, err)
+	})
+}
@@ -0,0 +1,178 @@
+/*
+Package coretest starts applications built with package core in tests.
+
+Instead of hard coding ports and sleeping until the servers are up, create the
+core with New and start it with Serve:
+
+	func TestApp(t *testing.T) {
+		c := coretest.New(t, coretest.WithInline("cron.disable", true))
+		c.AddModule(app.Module{})
+		srv := coretest.Serve(t, c)
+
+		resp, err := http.Get(srv.URL + "/hello")
+		...
+		client := pb.NewGreeterClient(srv.Conn)
+		...
+	}
+
+The servers listen on ephemeral ports, and everything is shut down in
+t.Cleanup.
+*/
+package coretest
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/DoNewsCode/core"
+	"github.com/DoNewsCode/core/contract"
+	"github.com/DoNewsCode/core/contract/lifecycle"
+	"github.com/DoNewsCode/core/di"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/credentials/insecure"
+)
+
+// StartTimeout is the time Serve waits for the servers to start.
+var StartTimeout = 10 * time.Second
+
+type options struct {
+	overrides   []core.CoreOption
+	coreOptions []core.CoreOption
+}
+
+// Option is the option of New.
+type Option func(*options)
+
+// WithInline overrides a config value. The overrides take precedence over every
+// other configuration layer, including the ephemeral addresses set by New.
+func WithInline(key string, entry any) Option {
+	return func(o *options) {
+		o.overrides = append(o.overrides, core.WithInline(key, entry))
+	}
+}
+
+// WithCoreOptions passes options to core.New, such as core.WithYamlFile. The
+// configuration layers defined by them rank below the ephemeral addresses and
+// the overrides.
+func WithCoreOptions(opts ...core.CoreOption) Option {
+	return func(o *options) {
+		o.coreOptions = append(o.coreOptions, opts...)
+	}
+}
+
+// New creates a *core.C for tests, with the core dependencies provided like
+// core.Default. The http server and the gRPC server listen on ephemeral ports of
+// the loopback interface, and logging is turned off. Use WithInline to change
+// any of them.
+func New(t testing.TB, opts ...Option) *core.C {
+	t.Helper()
+
+	var o options
+	for _, f := range opts {
+		f(&o)
+	}
+	stack := append([]core.CoreOption{}, o.overrides...)
+	stack = append(stack,
+		core.WithInline("http.addr", "127.0.0.1:0"),
+		core.WithInline("grpc.addr", "127.0.0.1:0"),
+		core.WithInline("log.level", "none"),
+	)
+	stack = append(stack, o.coreOptions...)
+	return core.Default(stack...)
+}
+
+// Server is an application served by Serve.
+type Server struct {
+	// URL is the base URL of the http server, such as http://127.0.0.1:51234.
+	// It is empty if the http server is disabled.
+	URL string
+	// GRPCAddr is the address of the gRPC server. It is empty if the gRPC
+	// server is disabled.
+	GRPCAddr string
+	// Conn is a plaintext client connection to the gRPC server. It is nil if
+	// the gRPC server is disabled.
+	Conn *grpc.ClientConn
+}
+
+type serveIn struct {
+	di.In
+
+	Conf            contract.ConfigAccessor
+	HTTPServerStart lifecycle.HTTPServerStart `optional:"true"`
+	GRPCServerStart lifecycle.GRPCServerStart `optional:"true"`
+}
+
+// Serve runs c.Serve in the background, and waits until the enabled servers have
+// started. The serve command is stopped in t.Cleanup, and the test fails if it
+// does not return nil.
+func Serve(t testing.TB, c *core.C) *Server {
+	t.Helper()
+
+	var (
+		srv      Server
+		httpAddr = make(chan string, 1)
+		grpcAddr = make(chan string, 1)
+		waitHTTP bool
+		waitGRPC bool
+	)
+	c.Invoke(func(in serveIn) {
+		if !in.Conf.Bool("http.disable") && in.HTTPServerStart != nil {
+			waitHTTP = true
+			in.HTTPServerStart.On(func(ctx context.Context, payload lifecycle.HTTPServerStartPayload) error {
+				httpAddr <- payload.Listener.Addr().String()
+				return nil
+			})
+		}
+		if !in.Conf.Bool("grpc.disable") && in.GRPCServerStart != nil {
+			waitGRPC = true
+			in.GRPCServerStart.On(func(ctx context.Context, payload lifecycle.GRPCServerStartPayload) error {
+				grpcAddr <- payload.Listener.Addr().String()
+				return nil
+			})
+		}
+	})
+
+	ctx, cancel := context.WithCancel(context.Background())
+	served := make(chan error, 1)
+	go func() {
+		served <- c.Serve(ctx)
+	}()
+	t.Cleanup(func() {
+		if srv.Conn != nil {
+			srv.Conn.Close()
+		}
+		cancel()
+		if err := <-served; err != nil {
+			t.Errorf("serve returned an error: %s", err)
+		}
+	})
+
+	timeout := time.After(StartTimeout)
+	for waitHTTP || waitGRPC {
+		select {
+		case addr := <-httpAddr:
+			srv.URL = "http://" + addr
+			waitHTTP = false
+		case addr := <-grpcAddr:
+			srv.GRPCAddr = addr
+			waitGRPC = false
+		case err := <-served:
+			// The error is reported here. Unblock the cleanup.
+			served <- nil
+			t.Fatalf("serve returned before the servers started: %v", err)
+		case <-timeout:
+			t.Fatalf("the servers did not start in %s", StartTimeout)
+		}
+	}
+
+	if srv.GRPCAddr != "" {
+		conn, err := grpc.Dial(srv.GRPCAddr, grpc.WithTransportCredentials(insecure.NewCredentials()))
+		if err != nil {
+			t.Fatalf("failed to dial the gRPC server: %s", err)
+		}
+		srv.Conn = conn
+	}
+	return &srv
+}
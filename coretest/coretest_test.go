@@ -0,0 +1,57 @@
+package coretest_test
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"testing"
+
+	"github.com/DoNewsCode/core"
+	"github.com/DoNewsCode/core/contract"
+	"github.com/DoNewsCode/core/coretest"
+	"github.com/DoNewsCode/core/srvgrpc"
+
+	"github.com/gorilla/mux"
+	"github.com/stretchr/testify/assert"
+	healthpb "google.golang.org/grpc/health/grpc_health_v1"
+)
+
+func TestServe(t *testing.T) {
+	c := coretest.New(t, coretest.WithInline("name", "coretest"))
+	c.AddModule(srvgrpc.HealthCheckModule{})
+	c.AddModule(core.HttpFunc(func(router *mux.Router) {
+		router.HandleFunc("/", func(writer http.ResponseWriter, request *http.Request) {
+			writer.Write([]byte("hello"))
+		})
+	}))
+	srv := coretest.Serve(t, c)
+
+	resp, err := http.Get(srv.URL + "/")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+	body, _ := io.ReadAll(resp.Body)
+	assert.Equal(t, "hello", string(body))
+
+	check, err := healthpb.NewHealthClient(srv.Conn).Check(context.Background(), &healthpb.HealthCheckRequest{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal(t, healthpb.HealthCheckResponse_SERVING, check.Status)
+
+	c.Invoke(func(name contract.AppName) {
+		assert.Equal(t, "coretest", name.String())
+	})
+}
+
+func TestServe_disabled(t *testing.T) {
+	c := coretest.New(t,
+		coretest.WithInline("grpc.disable", true),
+		coretest.WithCoreOptions(core.WithInline("http.disable", true)),
+	)
+	srv := coretest.Serve(t, c)
+	assert.Empty(t, srv.URL)
+	assert.Empty(t, srv.GRPCAddr)
+	assert.Nil(t, srv.Conn)
+}
@@ -4,6 +4,8 @@ import (
 	"fmt"
 	stdlog "log"
 	"net"
+	"strings"
+	"time"
 
 	"github.com/DoNewsCode/core/config"
 	"github.com/DoNewsCode/core/contract"
@@ -28,11 +30,25 @@ http:
   readTimeout: 2s
   writeTimeout: 10s
   idleTimeout: 10s
+  builtinMiddlewares: false
+  gateway:
+    prefix: /
 grpc:
   addr: :9090
   disable: false
+  builtinInterceptors: false
 cron:
   disable: false
+serve:
+  shutdownTimeout: 30s
+  closerTimeout: 10s
+  singlePort: false
+  restart:
+    maxAttempts: 0
+    backoff: 1s
+    maxBackoff: 1m
+config:
+  tolerantReload: false
 log:
   level: debug
   format: logfmt
@@ -56,9 +72,9 @@ func ProvideConfig(configStack []config.ProviderSet, configWatcher contract.Conf
 	)
 
 	for _, layer := range configStack {
-		stack = append(stack, config.WithProviderLayer(layer.Provider, layer.Parser))
+		stack = append(stack, config.WithNamedProviderLayer(layer.Name, layer.Provider, layer.Parser))
 	}
-	stack = append(stack, config.WithProviderLayer(rawbytes.Provider([]byte(defaultConfig)), yaml.Parser()))
+	stack = append(stack, config.WithNamedProviderLayer("default", rawbytes.Provider([]byte(defaultConfig)), yaml.Parser()))
 	if configWatcher != nil {
 		stack = append(stack, config.WithWatcher(configWatcher))
 	}
@@ -85,7 +101,9 @@ func ProvideAppName(conf contract.ConfigUnmarshaler) contract.AppName {
 	return appName
 }
 
-// ProvideLogger is the default LoggerProvider for package Core.
+// ProvideLogger is the default LoggerProvider for package Core. If the config
+// is a logging.Redactor, the secrets are masked in the entries that pass the
+// level filter.
 func ProvideLogger(conf contract.ConfigUnmarshaler, appName contract.AppName, env contract.Env) log.Logger {
 	var (
 		lvl    string
@@ -101,6 +119,9 @@ func ProvideLogger(conf contract.ConfigUnmarshaler, appName contract.AppName, en
 		format = "logfmt"
 	}
 	logger := logging.NewLogger(format)
+	if redactor, ok := conf.(logging.Redactor); ok {
+		logger = logging.WithRedaction(logger, redactor)
+	}
 	logger = level.NewFilter(logger, logging.LevelFilter(lvl))
 	logger = level.NewInjector(logger, level.DebugValue())
 	return logger
@@ -114,19 +135,23 @@ func ProvideDi(conf contract.ConfigUnmarshaler) *dig.Container {
 type lifecycleOut struct {
 	di.Out
 	lifecycle.ConfigReload
+	lifecycle.ConfigReloadFailed
 	lifecycle.HTTPServerStart
 	lifecycle.HTTPServerShutdown
 	lifecycle.GRPCServerStart
 	lifecycle.GRPCServerShutdown
+	lifecycle.ShutdownStart
 }
 
 func provideLifecycle() lifecycleOut {
 	return lifecycleOut{
-		ConfigReload:       &events.Event[contract.ConfigUnmarshaler]{},
+		ConfigReload:       &events.Event[lifecycle.ConfigReloadPayload]{},
+		ConfigReloadFailed: &events.Event[lifecycle.ConfigReloadFailedPayload]{},
 		HTTPServerStart:    &events.Event[lifecycle.HTTPServerStartPayload]{},
 		HTTPServerShutdown: &events.Event[lifecycle.HTTPServerShutdownPayload]{},
 		GRPCServerStart:    &events.Event[lifecycle.GRPCServerStartPayload]{},
 		GRPCServerShutdown: &events.Event[lifecycle.GRPCServerShutdownPayload]{},
+		ShutdownStart:      &events.Event[lifecycle.ShutdownStartPayload]{},
 	}
 }
 
@@ -169,16 +194,25 @@ func provideDefaultConfig() []config.ExportedConfig {
 			Owner: "core",
 			Data: map[string]any{
 				"http": map[string]any{
-					"addr":    ":8080",
-					"disable": false,
+					"addr":               ":8080",
+					"disable":            false,
+					"builtinMiddlewares": false,
+					"gateway": map[string]any{
+						"prefix": "/",
+					},
 				},
 			},
-			Comment: "The http address",
+			Comment: "The http address, and the path prefix at which the grpc gateway is mounted. If builtinMiddlewares is true, the tracing, metrics and recovery middlewares are installed",
 			Validate: func(data map[string]any) error {
 				disable, err := getBool(data, "http", "disable")
 				if err != nil {
 					return fmt.Errorf("the http.disable field is not valid: %w", err)
 				}
+				if _, ok := data["http"].(map[string]any)["builtinMiddlewares"]; ok {
+					if _, err := getBool(data, "http", "builtinMiddlewares"); err != nil {
+						return fmt.Errorf("the http.builtinMiddlewares field is not valid: %w", err)
+					}
+				}
 				if disable {
 					return nil
 				}
@@ -189,6 +223,16 @@ func provideDefaultConfig() []config.ExportedConfig {
 				if _, err := net.ResolveTCPAddr("tcp", str); err != nil {
 					return fmt.Errorf("the http.addr field must be an valid address like :8080, got %s", str)
 				}
+				if _, ok := data["http"].(map[string]any)["gateway"]; !ok {
+					return nil
+				}
+				prefix, err := getString(data, "http", "gateway", "prefix")
+				if err != nil {
+					return fmt.Errorf("the http.gateway.prefix field is not valid: %w", err)
+				}
+				if prefix != "" && !strings.HasPrefix(prefix, "/") {
+					return fmt.Errorf("the http.gateway.prefix field must be a path like /api, got %s", prefix)
+				}
 				return nil
 			},
 		},
@@ -196,16 +240,22 @@ func provideDefaultConfig() []config.ExportedConfig {
 			Owner: "core",
 			Data: map[string]any{
 				"grpc": map[string]any{
-					"addr":    ":9090",
-					"disable": false,
+					"addr":                ":9090",
+					"disable":             false,
+					"builtinInterceptors": false,
 				},
 			},
-			Comment: "The gRPC address",
+			Comment: "The gRPC address. If builtinInterceptors is true, the tracing, metrics and recovery interceptors are installed",
 			Validate: func(data map[string]any) error {
 				disable, err := getBool(data, "grpc", "disable")
 				if err != nil {
 					return fmt.Errorf("the grpc.disable field is not valid: %w", err)
 				}
+				if _, ok := data["grpc"].(map[string]any)["builtinInterceptors"]; ok {
+					if _, err := getBool(data, "grpc", "builtinInterceptors"); err != nil {
+						return fmt.Errorf("the grpc.builtinInterceptors field is not valid: %w", err)
+					}
+				}
 				if disable {
 					return nil
 				}
@@ -235,6 +285,55 @@ func provideDefaultConfig() []config.ExportedConfig {
 				return nil
 			},
 		},
+		{
+			Owner: "core",
+			Data: map[string]any{
+				"serve": map[string]any{
+					"shutdownTimeout": "30s",
+					"closerTimeout":   "10s",
+					"singlePort":      false,
+					"restart": map[string]any{
+						"maxAttempts": 0,
+						"backoff":     "1s",
+						"maxBackoff":  "1m",
+					},
+				},
+			},
+			Comment: "The serve command. The shutdown timeout is shared by all shutdown phases. Each closer is abandoned after the closer timeout, and unrelated closers run in parallel. In the single port mode, http, h2c and gRPC are all served at http.addr. A failed Runnable is restarted with exponential backoff for at most restart.maxAttempts times in a row. A run that lasts restart.resetAfter, which defaults to restart.maxBackoff, resets the count",
+			Validate: func(data map[string]any) error {
+				str, err := getString(data, "serve", "shutdownTimeout")
+				if err != nil {
+					return fmt.Errorf("the serve.shutdownTimeout field is not valid: %w", err)
+				}
+				if _, err := time.ParseDuration(str); err != nil {
+					return fmt.Errorf("the serve.shutdownTimeout field must be a duration like 30s, got %s", str)
+				}
+				str, err = getString(data, "serve", "closerTimeout")
+				if err != nil {
+					return fmt.Errorf("the serve.closerTimeout field is not valid: %w", err)
+				}
+				if d, err := time.ParseDuration(str); err != nil || d <= 0 {
+					return fmt.Errorf("the serve.closerTimeout field must be a positive duration like 10s, got %s", str)
+				}
+				if _, err := getBool(data, "serve", "singlePort"); err != nil {
+					return fmt.Errorf("the serve.singlePort field is not valid: %w", err)
+				}
+				return nil
+			},
+		},
+		{
+			Owner: "core",
+			Data: map[string]any{
+				"config": map[string]any{"tolerantReload": false},
+			},
+			Comment: "The config reload. If tolerantReload is true, a failed reload keeps the last good config instead of stopping the app",
+			Validate: func(data map[string]any) error {
+				if _, err := getBool(data, "config", "tolerantReload"); err != nil {
+					return fmt.Errorf("the config.tolerantReload field is not valid: %w", err)
+				}
+				return nil
+			},
+		},
 		{
 			Owner: "core",
 			Data: map[string]any{
@@ -58,6 +58,41 @@ func TestDefaultConfig_invalid(t *testing.T) {
 		}
 	})
 
+	t.Run("invalid gateway prefix", func(t *testing.T) {
+		conf := provideDefaultConfig()
+		for _, c := range conf {
+			if c.Validate != nil {
+				err := c.Validate(map[string]any{
+					"http": map[string]any{
+						"addr":    ":8080",
+						"disable": false,
+						"gateway": map[string]any{"prefix": "api"},
+					},
+				})
+				assert.Error(t, err)
+			}
+		}
+	})
+
+	t.Run("invalid closer timeout", func(t *testing.T) {
+		conf := provideDefaultConfig()
+		for _, c := range conf {
+			if _, ok := c.Data["serve"]; !ok || c.Validate == nil {
+				continue
+			}
+			for _, timeout := range []string{"0s", "-1s", "forever"} {
+				err := c.Validate(map[string]any{
+					"serve": map[string]any{
+						"shutdownTimeout": "30s",
+						"closerTimeout":   timeout,
+						"singlePort":      false,
+					},
+				})
+				assert.Error(t, err, timeout)
+			}
+		}
+	})
+
 	t.Run("disabled transport http", func(t *testing.T) {
 		conf := provideDefaultConfig()
 		for _, c := range conf {
@@ -0,0 +1,58 @@
+package di
+
+import (
+	"fmt"
+	"sort"
+
+	"github.com/DoNewsCode/core/contract"
+)
+
+// ConfigDeps is a set of providers built from the configuration. When ConfigDeps
+// is used as the element in di.Deps, core.Provide calls it with the
+// configuration, and provides the returned providers. It allows the shape of
+// the graph to depend on the configuration, such as a named value for each
+// configured connection. The configuration at the time of providing is used;
+// later reloads don't change the graph. If the configuration is not valid,
+// ConfigDeps returns an error, and core.Provide panics with it.
+type ConfigDeps func(conf contract.ConfigUnmarshaler) (Deps, error)
+
+// Named returns a ConfigDeps that provides a named T for each key under the
+// path in the configuration, made by the maker M. For example, with the
+// configuration
+//
+//	gorm:
+//	  default: ...
+//	  reporting: ...
+//
+// Named[otgorm.Maker, *gorm.DB]("gorm") provides the *gorm.DB named "default"
+// and "reporting", so that they can be injected by name:
+//
+//	type in struct {
+//		di.In
+//		DB *gorm.DB `name:"reporting"`
+//	}
+//
+// A misspelled name fails when the graph is built, rather than when the instance
+// is made. The instances are made lazily by the maker, which caches them.
+func Named[M interface{ Make(name string) (T, error) }, T any](path string) ConfigDeps {
+	return func(conf contract.ConfigUnmarshaler) (Deps, error) {
+		var instances map[string]any
+		if err := conf.Unmarshal(path, &instances); err != nil {
+			return nil, fmt.Errorf("the %s configuration is not valid: %w", path, err)
+		}
+		names := make([]string, 0, len(instances))
+		for name := range instances {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+
+		deps := make(Deps, 0, len(names))
+		for _, name := range names {
+			name := name
+			deps = append(deps, Name(func(maker M) (T, error) {
+				return maker.Make(name)
+			}, name))
+		}
+		return deps, nil
+	}
+}
@@ -0,0 +1,87 @@
+package di_test
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/DoNewsCode/core"
+	"github.com/DoNewsCode/core/di"
+
+	"github.com/stretchr/testify/assert"
+)
+
+type conn struct {
+	name string
+}
+
+type maker interface {
+	Make(name string) (*conn, error)
+}
+
+type connMaker struct {
+	made []string
+}
+
+func (m *connMaker) Make(name string) (*conn, error) {
+	if name == "broken" {
+		return nil, errors.New("broken connection")
+	}
+	m.made = append(m.made, name)
+	return &conn{name: name}, nil
+}
+
+func TestNamed(t *testing.T) {
+	m := &connMaker{}
+	c := core.New(core.WithInline("conn", map[string]any{
+		"default":   map[string]any{"addr": ":1"},
+		"reporting": map[string]any{"addr": ":2"},
+		"broken":    map[string]any{},
+	}))
+	c.Provide(di.Deps{
+		func() maker { return m },
+		di.Named[maker, *conn]("conn"),
+		di.Named[maker, *conn]("missing"),
+	})
+
+	invoke := func(function any) (err error) {
+		defer func() {
+			if r := recover(); r != nil {
+				err = r.(error)
+			}
+		}()
+		c.Invoke(function)
+		return nil
+	}
+
+	type in struct {
+		di.In
+
+		Reporting *conn `name:"reporting"`
+	}
+	assert.NoError(t, invoke(func(in in) {
+		assert.Equal(t, "reporting", in.Reporting.name)
+	}))
+	// The instances are made on demand.
+	assert.Equal(t, []string{"reporting"}, m.made)
+
+	type broken struct {
+		di.In
+
+		Conn *conn `name:"broken"`
+	}
+	assert.ErrorContains(t, invoke(func(broken) {}), "broken connection")
+
+	type misspelled struct {
+		di.In
+
+		Conn *conn `name:"reportin"`
+	}
+	assert.ErrorContains(t, invoke(func(misspelled) {}), `missing type: *di_test.conn[name="reportin"]`)
+
+	t.Run("malformed", func(t *testing.T) {
+		c := core.New(core.WithInline("conn", []any{"default"}))
+		assert.PanicsWithError(t, "the conn configuration is not valid: '[0]' expected a map, got 'string'", func() {
+			c.Provide(di.Deps{di.Named[maker, *conn]("conn")})
+		})
+	})
+}
@@ -12,6 +12,25 @@ import (
 type OptionalProvider struct {
 	Constructor any
 	Options     []dig.ProvideOption
+
+	info ProvideInfo
+}
+
+// ProvideInfo describes the options added by Name, As and LocationForPC, which
+// cannot be read back from the dig options. It is used to render the
+// dependency graph.
+type ProvideInfo struct {
+	// Name is the name set by Name.
+	Name string
+	// As is the types set by As.
+	As []reflect.Type
+	// PC is the location set by LocationForPC.
+	PC uintptr
+}
+
+// Info returns the options added by Name, As and LocationForPC.
+func (o OptionalProvider) Info() ProvideInfo {
+	return o.info
 }
 
 // LocationForPC sets the constructor pointer to a specified location. Use this
@@ -21,11 +40,13 @@ type OptionalProvider struct {
 func LocationForPC(constructor any, pc uintptr) any {
 	if op, ok := constructor.(OptionalProvider); ok {
 		op.Options = append(op.Options, dig.LocationForPC(pc))
+		op.info.PC = pc
 		return op
 	}
 	return OptionalProvider{
 		Constructor: constructor,
 		Options:     []dig.ProvideOption{dig.LocationForPC(pc)},
+		info:        ProvideInfo{PC: pc},
 	}
 }
 
@@ -35,11 +56,13 @@ func LocationForPC(constructor any, pc uintptr) any {
 func As(constructor any, as any) any {
 	if op, ok := constructor.(OptionalProvider); ok {
 		op.Options = append(op.Options, dig.As(as))
+		op.info.As = append(op.info.As, reflect.TypeOf(as).Elem())
 		return op
 	}
 	return OptionalProvider{
 		Constructor: constructor,
 		Options:     []dig.ProvideOption{dig.As(as)},
+		info:        ProvideInfo{As: []reflect.Type{reflect.TypeOf(as).Elem()}},
 	}
 }
 
@@ -49,11 +72,13 @@ func As(constructor any, as any) any {
 func Name(constructor any, name string) any {
 	if op, ok := constructor.(OptionalProvider); ok {
 		op.Options = append(op.Options, dig.Name(name))
+		op.info.Name = name
 		return op
 	}
 	return OptionalProvider{
 		Constructor: constructor,
 		Options:     []dig.ProvideOption{dig.Name(name)},
+		info:        ProvideInfo{Name: name},
 	}
 }
 
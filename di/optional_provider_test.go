@@ -90,3 +90,14 @@ func TestChainedOptions(t *testing.T) {
 	}) {
 	})
 }
+
+func TestOptionalProvider_Info(t *testing.T) {
+	pc := reflect.ValueOf(ctor).Pointer()
+	op := di.As(di.Name(di.LocationForPC(ctor, pc), "foo"), new(Fooer)).(di.OptionalProvider)
+	assert.Equal(t, di.ProvideInfo{
+		Name: "foo",
+		As:   []reflect.Type{reflect.TypeOf(new(Fooer)).Elem()},
+		PC:   pc,
+	}, op.Info())
+	assert.Len(t, op.Options, 3)
+}
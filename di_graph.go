@@ -0,0 +1,388 @@
+package core
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/url"
+	"reflect"
+	"runtime"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/DoNewsCode/core/di"
+
+	"github.com/spf13/cobra"
+	"go.uber.org/dig"
+)
+
+// diKey identifies a value in the container: a type, optionally qualified by a
+// name or a value group.
+type diKey struct {
+	Type  string `json:"type"`
+	Name  string `json:"name,omitempty"`
+	Group string `json:"group,omitempty"`
+
+	typ reflect.Type
+}
+
+func (k diKey) String() string {
+	switch {
+	case k.Name != "":
+		return fmt.Sprintf("%s[name=%q]", k.Type, k.Name)
+	case k.Group != "":
+		return fmt.Sprintf("%s[group=%q]", k.Type, k.Group)
+	}
+	return k.Type
+}
+
+// diParam is a dependency of a constructor.
+type diParam struct {
+	diKey
+	Optional bool `json:"optional,omitempty"`
+}
+
+// diConstructor is a constructor provided through C.Provide, along with the
+// location it was defined at.
+type diConstructor struct {
+	ID       int       `json:"id"`
+	Function string    `json:"function"`
+	Package  string    `json:"package"`
+	File     string    `json:"file"`
+	Line     int       `json:"line"`
+	Params   []diParam `json:"params"`
+	Results  []diKey   `json:"results"`
+	Failed   bool      `json:"failed,omitempty"`
+}
+
+// diNode is a value in the container. Nodes of value groups list the
+// constructors contributing to the group.
+type diNode struct {
+	diKey
+	Providers []int `json:"providers,omitempty"`
+	// Missing is true if the value is required by a constructor, but nothing
+	// provides it.
+	Missing bool `json:"missing,omitempty"`
+	// Failed is true if the value cannot be constructed. It is only known when
+	// the graph is checked.
+	Failed bool `json:"failed,omitempty"`
+	// RootCause is true if the value failed while all of its dependencies
+	// succeeded.
+	RootCause bool   `json:"rootCause,omitempty"`
+	Error     string `json:"error,omitempty"`
+}
+
+// diGraph is the dependency graph rendered by the di graph command.
+type diGraph struct {
+	Constructors []*diConstructor `json:"constructors"`
+	Nodes        []*diNode        `json:"nodes"`
+}
+
+// recordConstructor keeps the signature of a provided constructor, so that it
+// can be rendered by the di graph command later. The name, the As types and the
+// location are the ones added by di.Name, di.As and di.LocationForPC. Options
+// passed to di.OptionalProvider by other means are not reflected in the graph.
+func (c *C) recordConstructor(constructor any, info di.ProvideInfo) {
+	ftype := reflect.TypeOf(constructor)
+	ctor := &diConstructor{ID: len(c.constructors)}
+	pc := reflect.ValueOf(constructor).Pointer()
+	if info.PC != 0 {
+		pc = info.PC
+	}
+	ctor.Package, ctor.Function, ctor.File, ctor.Line = inspectPC(pc)
+
+	for i := 0; i < ftype.NumIn(); i++ {
+		ctor.Params = appendParams(ctor.Params, ftype.In(i), "", "", false)
+	}
+	for i := 0; i < ftype.NumOut(); i++ {
+		outT := ftype.Out(i)
+		if isCleanup(outT) || outT == _errType {
+			continue
+		}
+		if len(info.As) > 0 && !dig.IsOut(outT) {
+			for _, t := range info.As {
+				ctor.Results = append(ctor.Results, newDIKey(t, info.Name, ""))
+			}
+			continue
+		}
+		ctor.Results = appendResults(ctor.Results, outT, info.Name, "")
+	}
+	c.constructors = append(c.constructors, ctor)
+}
+
+func appendParams(params []diParam, t reflect.Type, name, group string, optional bool) []diParam {
+	if !dig.IsIn(t) {
+		if group != "" && t.Kind() == reflect.Slice {
+			t = t.Elem()
+		}
+		return append(params, diParam{diKey: newDIKey(t, name, group), Optional: optional})
+	}
+	for i := 0; i < t.NumField(); i++ {
+		f := t.Field(i)
+		if f.PkgPath != "" && !f.Anonymous {
+			continue
+		}
+		optional, _ := strconv.ParseBool(f.Tag.Get("optional"))
+		params = appendParams(params, f.Type, f.Tag.Get("name"), tagName(f.Tag.Get("group")), optional)
+	}
+	return params
+}
+
+func appendResults(results []diKey, t reflect.Type, name, group string) []diKey {
+	if !dig.IsOut(t) {
+		return append(results, newDIKey(t, name, group))
+	}
+	for i := 0; i < t.NumField(); i++ {
+		f := t.Field(i)
+		if f.PkgPath != "" && !f.Anonymous {
+			continue
+		}
+		group := f.Tag.Get("group")
+		if strings.Contains(group, ",flatten") {
+			results = appendResults(results, f.Type.Elem(), "", tagName(group))
+			continue
+		}
+		results = appendResults(results, f.Type, f.Tag.Get("name"), tagName(group))
+	}
+	return results
+}
+
+func newDIKey(t reflect.Type, name, group string) diKey {
+	return diKey{Type: t.String(), Name: name, Group: group, typ: t}
+}
+
+// tagName strips the options, such as soft or flatten, from a group tag.
+func tagName(tag string) string {
+	name, _, _ := strings.Cut(tag, ",")
+	return name
+}
+
+// inspectPC splits the function at pc into the package and the function name,
+// the same way dig does.
+func inspectPC(pc uintptr) (pkg, function, file string, line int) {
+	f := runtime.FuncForPC(pc)
+	if f == nil {
+		return "", "", "", 0
+	}
+	file, line = f.FileLine(pc)
+	name := f.Name()
+	idx := 0
+	if i := strings.LastIndex(name, "/"); i >= 0 {
+		idx = i
+	}
+	if i := strings.Index(name[idx:], "."); i >= 0 {
+		idx += i
+	}
+	pkg, function = name[:idx], strings.TrimPrefix(name[idx:], ".")
+	if i := strings.Index(pkg, "/vendor/"); i > 0 {
+		pkg = pkg[i+len("/vendor/"):]
+	}
+	if unescaped, err := url.QueryUnescape(pkg); err == nil {
+		pkg = unescaped
+	}
+	return pkg, function, file, line
+}
+
+// graph builds the dependency graph of the constructors provided so far. If
+// check is true, every value is constructed to find out which of them fail,
+// which runs every constructor along with its side effects.
+func (c *C) graph(check bool) *diGraph {
+	g := &diGraph{Constructors: c.constructors}
+	nodes := make(map[string]*diNode)
+	node := func(key diKey) *diNode {
+		if n, ok := nodes[key.String()]; ok {
+			return n
+		}
+		n := &diNode{diKey: key}
+		nodes[key.String()] = n
+		g.Nodes = append(g.Nodes, n)
+		return n
+	}
+	for _, ctor := range c.constructors {
+		for _, result := range ctor.Results {
+			n := node(result)
+			n.Providers = append(n.Providers, ctor.ID)
+		}
+	}
+	for _, ctor := range c.constructors {
+		for _, param := range ctor.Params {
+			n := node(param.diKey)
+			if len(n.Providers) == 0 && param.Group == "" && !param.Optional {
+				n.Missing = true
+			}
+		}
+	}
+	sort.SliceStable(g.Nodes, func(i, j int) bool {
+		return g.Nodes[i].String() < g.Nodes[j].String()
+	})
+	if !check {
+		return g
+	}
+
+	for _, n := range g.Nodes {
+		if len(n.Providers) == 0 {
+			continue
+		}
+		if err := c.invokeKey(n.diKey); err != nil {
+			n.Failed = true
+			n.Error = err.Error()
+		}
+	}
+	for _, ctor := range c.constructors {
+		for _, result := range ctor.Results {
+			if nodes[result.String()].Failed {
+				ctor.Failed = true
+			}
+		}
+	}
+	for _, n := range g.Nodes {
+		if !n.Failed {
+			continue
+		}
+		n.RootCause = true
+		for _, id := range n.Providers {
+			for _, param := range c.constructors[id].Params {
+				if p := nodes[param.String()]; (p.Failed || p.Missing) && !param.Optional {
+					n.RootCause = false
+				}
+			}
+		}
+	}
+	return g
+}
+
+// invokeKey constructs the value of the given key. Panics in constructors are
+// reported as errors.
+func (c *C) invokeKey(key diKey) (err error) {
+	t := key.typ
+	tag := ""
+	switch {
+	case key.Name != "":
+		tag = fmt.Sprintf(`name:"%s"`, key.Name)
+	case key.Group != "":
+		t = reflect.SliceOf(t)
+		tag = fmt.Sprintf(`group:"%s"`, key.Group)
+	}
+	in := reflect.StructOf([]reflect.StructField{
+		{Name: "In", Type: reflect.TypeOf(dig.In{}), Anonymous: true},
+		{Name: "Value", Type: t, Tag: reflect.StructTag(tag)},
+	})
+	fn := reflect.MakeFunc(reflect.FuncOf([]reflect.Type{in}, nil, false), func([]reflect.Value) []reflect.Value {
+		return nil
+	})
+	defer func() {
+		if r := recover(); r != nil {
+			err = fmt.Errorf("panic: %v", r)
+		}
+	}()
+	return c.di.Invoke(fn.Interface())
+}
+
+// writeDOT renders the graph in the Graphviz DOT language. Every constructor is
+// a cluster labeled with its package. Missing values are dashed red, values that
+// failed are red, and values that failed because of their dependencies are
+// orange.
+func (g *diGraph) writeDOT(w io.Writer) error {
+	var b strings.Builder
+	b.WriteString("digraph {\n\trankdir=RL;\n\tgraph [compound=true];\n")
+	resultID := func(ctor *diConstructor, key diKey) string {
+		if key.Group != "" {
+			return fmt.Sprintf("%s#%d", key, ctor.ID)
+		}
+		return key.String()
+	}
+	failed := make(map[string]*diNode)
+	for _, n := range g.Nodes {
+		failed[n.String()] = n
+	}
+	for _, ctor := range g.Constructors {
+		fmt.Fprintf(&b, "\tsubgraph cluster_%d {\n", ctor.ID)
+		fmt.Fprintf(&b, "\t\tlabel = %q;\n", ctor.Package)
+		fmt.Fprintf(&b, "\t\tconstructor_%d [shape=plaintext label=%q];\n", ctor.ID, ctor.Function)
+		if ctor.Failed {
+			b.WriteString("\t\tcolor=red;\n")
+		}
+		for _, result := range ctor.Results {
+			fmt.Fprintf(&b, "\t\t%q [label=%q%s];\n", resultID(ctor, result), result.String(), nodeAttributes(failed[result.String()]))
+		}
+		b.WriteString("\t}\n")
+		for _, param := range ctor.Params {
+			style := ""
+			if param.Optional {
+				style = " style=dashed"
+			}
+			fmt.Fprintf(&b, "\tconstructor_%d -> %q [ltail=cluster_%d%s];\n", ctor.ID, param.String(), ctor.ID, style)
+		}
+	}
+	for _, n := range g.Nodes {
+		switch {
+		case n.Group != "":
+			fmt.Fprintf(&b, "\t%q [shape=diamond%s];\n", n.String(), nodeAttributes(n))
+			for _, id := range n.Providers {
+				fmt.Fprintf(&b, "\t%q -> %q;\n", n.String(), resultID(g.Constructors[id], n.diKey))
+			}
+		case n.Missing:
+			fmt.Fprintf(&b, "\t%q [style=dashed%s];\n", n.String(), nodeAttributes(n))
+		}
+	}
+	b.WriteString("}\n")
+	_, err := io.WriteString(w, b.String())
+	return err
+}
+
+func nodeAttributes(n *diNode) string {
+	switch {
+	case n == nil:
+		return ""
+	case n.Missing, n.RootCause:
+		return " color=red"
+	case n.Failed:
+		return " color=orange"
+	}
+	return ""
+}
+
+// writeJSON renders the graph as indented JSON.
+func (g *diGraph) writeJSON(w io.Writer) error {
+	encoder := json.NewEncoder(w)
+	encoder.SetIndent("", "  ")
+	return encoder.Encode(g)
+}
+
+func newDICmd(c *C) *cobra.Command {
+	var (
+		format string
+		check  bool
+	)
+	graphCmd := &cobra.Command{
+		Use:   "graph",
+		Short: "render the dependency graph.",
+		Long: `render the dependency graph of the container in the Graphviz DOT format or in JSON.
+Every type is attributed to the package of its constructor, and the members of value groups are listed.
+Missing types are highlighted. With --check, every type is constructed and the failed ones are highlighted too.
+Note that --check invokes every constructor in the graph, including the ones that open connections to databases,
+message queues and other services, so run it where those connections are safe to open.`,
+		Example: "di graph | dot -Tsvg > graph.svg",
+		RunE: func(cmd *cobra.Command, args []string) error {
+			g := c.graph(check)
+			switch format {
+			case "dot":
+				return g.writeDOT(cmd.OutOrStdout())
+			case "json":
+				return g.writeJSON(cmd.OutOrStdout())
+			}
+			return fmt.Errorf("unknown format %s, expecting dot or json", format)
+		},
+	}
+	graphCmd.Flags().StringVarP(&format, "format", "f", "dot", "the output format, dot or json")
+	graphCmd.Flags().BoolVar(&check, "check", false, "construct every type, opening any connections they hold, to find the failed ones")
+
+	diCmd := &cobra.Command{
+		Use:   "di",
+		Short: "inspect the dependency injection container",
+		Long:  "inspect the dependency injection container, such as rendering the dependency graph.",
+	}
+	diCmd.AddCommand(graphCmd)
+	return diCmd
+}
@@ -0,0 +1,118 @@
+package core
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"reflect"
+	"testing"
+
+	"github.com/DoNewsCode/core/config"
+	"github.com/DoNewsCode/core/di"
+
+	"github.com/spf13/cobra"
+	"github.com/stretchr/testify/assert"
+)
+
+type graphFoo struct{}
+
+type graphBar struct{}
+
+type graphBaz struct{}
+
+type graphMissing struct{}
+
+type graphIn struct {
+	di.In
+
+	Bar     graphBar
+	Missing graphMissing            `optional:"true"`
+	Configs []config.ExportedConfig `group:"config"`
+}
+
+func provideGraphFoo(in graphIn) graphFoo { return graphFoo{} }
+
+func provideGraphBar() (graphBar, error) { return graphBar{}, errors.New("no bar") }
+
+func provideGraphBaz(missing graphMissing) graphBaz { return graphBaz{} }
+
+func runDIGraph(t *testing.T, c *C, args ...string) []byte {
+	t.Helper()
+	var buf bytes.Buffer
+	rootCmd := &cobra.Command{}
+	rootCmd.SetOut(&buf)
+	c.ApplyRootCommand(rootCmd)
+	rootCmd.SetArgs(append([]string{"di", "graph"}, args...))
+	assert.NoError(t, rootCmd.Execute())
+	return buf.Bytes()
+}
+
+func TestC_diGraph(t *testing.T) {
+	c := New()
+	c.ProvideEssentials()
+	c.Provide(di.Deps{provideGraphFoo, provideGraphBar, provideGraphBaz})
+
+	t.Run("json", func(t *testing.T) {
+		var g diGraph
+		assert.NoError(t, json.Unmarshal(runDIGraph(t, c, "--format", "json", "--check"), &g))
+
+		nodes := make(map[string]*diNode)
+		for _, n := range g.Nodes {
+			nodes[n.String()] = n
+		}
+		assert.True(t, nodes["core.graphMissing"].Missing)
+		assert.True(t, nodes["core.graphBar"].Failed)
+		assert.True(t, nodes["core.graphBar"].RootCause)
+		assert.Contains(t, nodes["core.graphBar"].Error, "no bar")
+		assert.True(t, nodes["core.graphFoo"].Failed)
+		assert.False(t, nodes["core.graphFoo"].RootCause)
+		assert.False(t, nodes["log.Logger"].Failed)
+
+		group := nodes[`config.ExportedConfig[group="config"]`]
+		assert.Len(t, group.Providers, 1)
+		essentials := g.Constructors[group.Providers[0]]
+		assert.Equal(t, "github.com/DoNewsCode/core", essentials.Package)
+		assert.Equal(t, "(*C).ProvideEssentials.func1", essentials.Function)
+
+		foo := g.Constructors[nodes["core.graphFoo"].Providers[0]]
+		assert.Equal(t, "provideGraphFoo", foo.Function)
+		assert.True(t, foo.Failed)
+		assert.Contains(t, foo.Params, diParam{diKey: diKey{Type: "core.graphMissing"}, Optional: true})
+	})
+
+	t.Run("dot", func(t *testing.T) {
+		out := string(runDIGraph(t, c, "--check"))
+		assert.Contains(t, out, "digraph {")
+		assert.Contains(t, out, `label = "github.com/DoNewsCode/core";`)
+		assert.Contains(t, out, `"core.graphMissing" [style=dashed color=red];`)
+		assert.Contains(t, out, `"core.graphBar" [label="core.graphBar" color=red];`)
+		assert.Contains(t, out, `"core.graphFoo" [label="core.graphFoo" color=orange];`)
+		assert.Contains(t, out, `"config.ExportedConfig[group=\"config\"]" [shape=diamond];`)
+	})
+}
+
+type graphFooer interface{ Foo() }
+
+func (graphFoo) Foo() {}
+
+func provideGraphNamedFoo() graphFoo { return graphFoo{} }
+
+func TestC_diGraph_options(t *testing.T) {
+	c := New()
+	c.Provide(di.Deps{
+		di.As(di.Name(di.LocationForPC(func() graphFoo { return graphFoo{} }, reflect.ValueOf(provideGraphNamedFoo).Pointer()), "named"), new(graphFooer)),
+		di.Bind(new(graphBar), new(graphBaz)),
+	})
+
+	var g diGraph
+	assert.NoError(t, json.Unmarshal(runDIGraph(t, c, "--format", "json"), &g))
+	assert.Len(t, g.Constructors, 2)
+
+	named := g.Constructors[0]
+	assert.Equal(t, "provideGraphNamedFoo", named.Function)
+	assert.Equal(t, []diKey{{Type: "core.graphFooer", Name: "named"}}, named.Results)
+
+	bind := g.Constructors[1]
+	assert.Equal(t, []diParam{{diKey: diKey{Type: "core.graphBar"}}}, bind.Params)
+	assert.Equal(t, []diKey{{Type: "core.graphBaz"}}, bind.Results)
+}
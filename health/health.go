@@ -0,0 +1,52 @@
+// Package health defines the health checks that modules contribute to the
+// readiness probe.
+//
+// Modules contribute a Checker by providing it to the DI container in the
+// "health" group, the same way they export configs to the "config" group:
+//
+//	type healthOut struct {
+//		di.Out
+//
+//		Checker health.Checker `group:"health"`
+//	}
+//
+// srvhttp.HealthCheckModule runs every Checker in the group when /ready is
+// requested.
+package health
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/DoNewsCode/core/di"
+)
+
+// Checker is a named health check. A nil error returned by Check means the
+// checked component is healthy.
+type Checker struct {
+	Name  string
+	Check func(ctx context.Context) error
+}
+
+// FactoryChecker returns a Checker that pings every connection the factory has
+// opened. Connections not yet opened are not checked.
+func FactoryChecker[T any](name string, factory *di.Factory[T], ping func(ctx context.Context, conn T) error) Checker {
+	return Checker{
+		Name: name,
+		Check: func(ctx context.Context) error {
+			var failed []string
+			for connName, pair := range factory.List() {
+				if err := ping(ctx, pair.Conn); err != nil {
+					failed = append(failed, fmt.Sprintf("%s: %s", connName, err))
+				}
+			}
+			if len(failed) == 0 {
+				return nil
+			}
+			sort.Strings(failed)
+			return fmt.Errorf("unhealthy connections: %s", strings.Join(failed, "; "))
+		},
+	}
+}
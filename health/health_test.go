@@ -0,0 +1,31 @@
+package health
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/DoNewsCode/core/di"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestFactoryChecker(t *testing.T) {
+	factory := di.NewFactory[string](func(name string) (di.Pair[string], error) {
+		return di.Pair[string]{Conn: name}, nil
+	})
+	checker := FactoryChecker("test", factory, func(ctx context.Context, conn string) error {
+		if conn == "bad" {
+			return errors.New("unreachable")
+		}
+		return nil
+	})
+	assert.Equal(t, "test", checker.Name)
+
+	assert.NoError(t, checker.Check(context.Background()))
+
+	factory.Make("good")
+	assert.NoError(t, checker.Check(context.Background()))
+
+	factory.Make("bad")
+	assert.EqualError(t, checker.Check(context.Background()), "unhealthy connections: bad: unreachable")
+}
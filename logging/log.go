@@ -19,6 +19,7 @@ package logging
 
 import (
 	"context"
+	"fmt"
 	"os"
 	"strings"
 
@@ -209,3 +210,58 @@ func WithLevel(logger log.Logger) LevelLogger {
 	}
 	return levelLogger{log.With(logger, "caller", log.Caller(5))}
 }
+
+type redactLogger struct {
+	base     log.Logger
+	redactor Redactor
+}
+
+func (r redactLogger) Log(keyvals ...any) error {
+	if !r.redactor.HasSecrets() {
+		return r.base.Log(keyvals...)
+	}
+	out := make([]any, len(keyvals))
+	for i, v := range keyvals {
+		switch x := v.(type) {
+		case level.Value:
+			// The level filter relies on the type of the level value.
+			out[i] = v
+		case string:
+			out[i] = r.redactor.Redact(x)
+		case error:
+			out[i] = r.redactor.Redact(x.Error())
+		case fmt.Stringer:
+			out[i] = redactStringer{stringer: x, redact: r.redactor.Redact}
+		default:
+			out[i] = v
+		}
+	}
+	return r.base.Log(out...)
+}
+
+// redactStringer redacts the stringer lazily, in case the value is filtered out.
+type redactStringer struct {
+	stringer fmt.Stringer
+	redact   func(string) string
+}
+
+func (r redactStringer) String() string {
+	return r.redact(r.stringer.String())
+}
+
+// Redactor masks the secrets in a text. *config.KoanfAdapter is a Redactor.
+type Redactor interface {
+	// Redact returns the text with the secrets masked.
+	Redact(text string) string
+	// HasSecrets reports whether there are any secrets to mask at the moment.
+	HasSecrets() bool
+}
+
+// WithRedaction decorates the log.Logger so that every string, error and
+// fmt.Stringer value is passed through the redactor before being logged. If the
+// redactor has no secrets, the values are passed through untouched. Package core
+// uses it to keep the resolved config secrets out of logs. Put it below the
+// level filter, so that the filtered out values are not redacted.
+func WithRedaction(logger log.Logger, redactor Redactor) log.Logger {
+	return redactLogger{base: logger, redactor: redactor}
+}
@@ -3,6 +3,8 @@ package logging
 import (
 	"bytes"
 	"context"
+	"errors"
+	"strings"
 	"testing"
 
 	"github.com/DoNewsCode/core/ctxmeta"
@@ -103,3 +105,43 @@ func TestPerformanceOptimization(t *testing.T) {
 		})
 	}
 }
+
+type redactor struct {
+	secret string
+	calls  int
+}
+
+func (r *redactor) Redact(text string) string {
+	r.calls++
+	return strings.ReplaceAll(text, r.secret, "******")
+}
+
+func (r *redactor) HasSecrets() bool {
+	return r.secret != ""
+}
+
+func TestWithRedaction(t *testing.T) {
+	var buf bytes.Buffer
+	logger := WithLevel(WithRedaction(log.NewLogfmtLogger(&buf), &redactor{secret: "hunter2"}))
+	logger.Infof("dsn: root:%s@db", "hunter2")
+	logger.Err(errors.New("hunter2"))
+	assert.Contains(t, buf.String(), "level=info")
+	assert.Contains(t, buf.String(), "root:******@db")
+	assert.NotContains(t, buf.String(), "hunter2")
+
+	t.Run("no secrets", func(t *testing.T) {
+		r := &redactor{}
+		logger := WithLevel(WithRedaction(log.NewNopLogger(), r))
+		logger.Info("foo")
+		assert.Zero(t, r.calls)
+	})
+
+	t.Run("filtered", func(t *testing.T) {
+		r := &redactor{secret: "hunter2"}
+		logger := WithLevel(level.NewFilter(WithRedaction(log.NewNopLogger(), r), LevelFilter("error")))
+		logger.Info("foo")
+		assert.Zero(t, r.calls)
+		logger.Err("foo")
+		assert.NotZero(t, r.calls)
+	})
+}
@@ -2,9 +2,12 @@ package core
 
 import (
 	"context"
+	"net/http"
 
+	"github.com/DoNewsCode/core/control/supervisor"
 	"github.com/DoNewsCode/core/cron"
 	"github.com/gorilla/mux"
+	"github.com/grpc-ecosystem/grpc-gateway/v2/runtime"
 	"github.com/oklog/run"
 	"github.com/spf13/cobra"
 	"google.golang.org/grpc"
@@ -35,6 +38,14 @@ type CloserProvider interface {
 	ProvideCloser()
 }
 
+// ContextCloserProvider is like CloserProvider, but the shutdown function
+// receives a context that expires at the deadline of the closer, and reports
+// the failure to close. If a module implements both interfaces, only
+// ProvideCloserContext is called.
+type ContextCloserProvider interface {
+	ProvideCloserContext(ctx context.Context) error
+}
+
 // RunProvider provides a runnable actor. Use it to register any server-like
 // actions. For example, kafka consumer can be started here.
 type RunProvider interface {
@@ -47,3 +58,76 @@ type RunProvider interface {
 type Runnable interface {
 	Run(ctx context.Context) error
 }
+
+// SupervisedRunnable is a Runnable with its own restart policy. When Run returns
+// an error, it is called again with exponential backoff until the policy is
+// exhausted. Runnables that don't implement this interface follow the policy at
+// serve.restart, which doesn't restart by default.
+type SupervisedRunnable interface {
+	Runnable
+	RestartPolicy() supervisor.Policy
+}
+
+// Priorities of the built-in HTTP middlewares and gRPC interceptors. They are
+// opt-in: the serve command registers them only if http.builtinMiddlewares or
+// grpc.builtinInterceptors is true. Each of them is then registered when its
+// dependency is available in the container: tracing requires an
+// opentracing.Tracer, metrics require a *srvhttp.RequestDurationSeconds or a
+// *srvgrpc.RequestDurationSeconds, and recovery is always registered.
+//
+// Applications that already wrap their handlers with srvhttp.Metrics,
+// srvhttp.Recover or nethttp.Middleware, or install the equivalent gRPC
+// interceptors, should remove them before turning the switches on, or requests
+// are counted and traced twice.
+const (
+	PriorityTracing  = 100
+	PriorityMetrics  = 200
+	PriorityRecovery = 300
+)
+
+// HTTPMiddleware is a http middleware contributed by a module. Middlewares are
+// applied in the ascending order of Priority: the one with the lowest priority
+// is the outermost. Middlewares with the same priority keep the order of
+// registration.
+type HTTPMiddleware struct {
+	Priority   int
+	Middleware func(handler http.Handler) http.Handler
+}
+
+// HTTPMiddlewareProvider provides http middlewares. The middlewares are applied
+// to the router of the serve command with mux.Router.Use, so they only run for
+// matched routes.
+type HTTPMiddlewareProvider interface {
+	ProvideHTTPMiddleware() []HTTPMiddleware
+}
+
+// GRPCInterceptor is a pair of gRPC interceptors contributed by a module. Either
+// of them can be nil. Interceptors are chained in the ascending order of
+// Priority: the one with the lowest priority is the outermost. Interceptors
+// with the same priority keep the order of registration.
+type GRPCInterceptor struct {
+	Priority int
+	Unary    grpc.UnaryServerInterceptor
+	Stream   grpc.StreamServerInterceptor
+}
+
+// GRPCInterceptorProvider provides gRPC interceptors. The interceptors are only
+// installed when the serve command creates the *grpc.Server. If a *grpc.Server
+// is provided to the container, its own options are used as is.
+type GRPCInterceptorProvider interface {
+	ProvideGRPCInterceptor() []GRPCInterceptor
+}
+
+// GRPCGatewayProvider provides grpc-gateway handlers. The endpoint and the dial
+// options connect to the in-process gRPC server, so the generated registration
+// functions can be used directly:
+//
+//	func (m Module) ProvideGRPCGateway(ctx context.Context, mux *runtime.ServeMux, endpoint string, opts []grpc.DialOption) error {
+//		return pb.RegisterGreeterHandlerFromEndpoint(ctx, mux, endpoint, opts)
+//	}
+//
+// The mux is mounted on the http router at http.gateway.prefix, which defaults
+// to "/". The context is canceled when the http server stops.
+type GRPCGatewayProvider interface {
+	ProvideGRPCGateway(ctx context.Context, mux *runtime.ServeMux, endpoint string, opts []grpc.DialOption) error
+}
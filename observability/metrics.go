@@ -1,6 +1,8 @@
 package observability
 
 import (
+	"github.com/DoNewsCode/core/config"
+	"github.com/DoNewsCode/core/control/supervisor"
 	"github.com/DoNewsCode/core/cron"
 	"github.com/DoNewsCode/core/di"
 	"github.com/DoNewsCode/core/otgorm"
@@ -83,6 +85,40 @@ func ProvideCronJobMetrics(in MetricsIn) *cron.CronJobMetrics {
 	return cron.NewCronJobMetrics(prometheus.NewHistogram(histogram), prometheus.NewCounter(counter))
 }
 
+// ProvideRestartMetrics returns a *supervisor.RestartMetrics that counts the
+// restarts of core.Runnable modules. It is meant to be consumed by the serve
+// command.
+func ProvideRestartMetrics(in MetricsIn) *supervisor.RestartMetrics {
+	counter := stdprometheus.NewCounterVec(stdprometheus.CounterOpts{
+		Name: "runnable_restarts_total",
+		Help: "Total number of restarts of failed runnables.",
+	}, []string{"module"})
+
+	if in.Registerer == nil {
+		in.Registerer = stdprometheus.DefaultRegisterer
+	}
+	in.Registerer.MustRegister(counter)
+
+	return supervisor.NewRestartMetrics(prometheus.NewCounter(counter))
+}
+
+// ProvideConfigReloadMetrics returns a *config.ReloadMetrics that counts the
+// failed reloads of the configuration. It is meant to be consumed by the config
+// module.
+func ProvideConfigReloadMetrics(in MetricsIn) *config.ReloadMetrics {
+	counter := stdprometheus.NewCounterVec(stdprometheus.CounterOpts{
+		Name: "config_reload_failures_total",
+		Help: "Total number of failed reloads of the configuration.",
+	}, []string{})
+
+	if in.Registerer == nil {
+		in.Registerer = stdprometheus.DefaultRegisterer
+	}
+	in.Registerer.MustRegister(counter)
+
+	return config.NewReloadMetrics(prometheus.NewCounter(counter))
+}
+
 // ProvideGORMMetrics returns a *otgorm.Gauges that measures the connection info
 // in databases. It is meant to be consumed by the otgorm.Providers.
 func ProvideGORMMetrics(in MetricsIn) *otgorm.Gauges {
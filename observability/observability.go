@@ -30,6 +30,8 @@ func Providers() di.Deps {
 		ProvideKafkaReaderMetrics,
 		ProvideKafkaWriterMetrics,
 		ProvideCronJobMetrics,
+		ProvideRestartMetrics,
+		ProvideConfigReloadMetrics,
 		provideConfig,
 	}
 }
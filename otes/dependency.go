@@ -9,6 +9,7 @@ import (
 	"github.com/DoNewsCode/core/contract"
 	"github.com/DoNewsCode/core/contract/lifecycle"
 	"github.com/DoNewsCode/core/di"
+	"github.com/DoNewsCode/core/health"
 
 	"github.com/go-kit/log"
 	"github.com/go-kit/log/level"
@@ -29,6 +30,8 @@ default *elastic.Client and exported configs.
 		Factory
 		Maker
 		*elastic.Client
+		*elastic.Client `name:"<name>"` (WithNamedInstances)
+		health.Checker `group:"health"`
 */
 func Providers(opts ...ProvidersOptionFunc) di.Deps {
 	options := providersOption{
@@ -38,12 +41,17 @@ func Providers(opts ...ProvidersOptionFunc) di.Deps {
 	for _, f := range opts {
 		f(&options)
 	}
-	return di.Deps{
+	deps := di.Deps{
 		provideEsFactory(&options),
+		provideHealthCheck,
 		provideDefaultClient,
 		provideConfig,
 		di.Bind(new(*Factory), new(Maker)),
 	}
+	if options.named {
+		deps = append(deps, di.Named[Maker, *elastic.Client]("es"))
+	}
+	return deps
 }
 
 // EsConfigInterceptor is an injector type hint that allows user to do
@@ -98,8 +106,12 @@ func provideEsFactory(option *providersOption) func(p factoryIn) (*Factory, func
 			}, nil
 		})
 		if option.reloadable && p.Dispatcher != nil {
-			p.Dispatcher.On(func(ctx context.Context, Config contract.ConfigUnmarshaler) error {
-				factory.Close()
+			p.Dispatcher.On(func(_ context.Context, payload lifecycle.ConfigReloadPayload) error {
+				for name := range factory.List() {
+					if payload.Changes.Changed("es." + name) {
+						factory.CloseConn(name)
+					}
+				}
 				return nil
 			})
 		}
@@ -190,3 +202,17 @@ func provideConfig() configOut {
 	}
 	return configOut{Config: configs}
 }
+
+type healthOut struct {
+	di.Out
+
+	Checker health.Checker `group:"health"`
+}
+
+// provideHealthCheck exports a health check that pings every opened elasticsearch client.
+func provideHealthCheck(factory *Factory) healthOut {
+	return healthOut{Checker: health.FactoryChecker("es", factory, func(ctx context.Context, client *elastic.Client) error {
+		_, err := client.ClusterHealth().Do(ctx)
+		return err
+	})}
+}
@@ -6,6 +6,7 @@ type providersOption struct {
 	interceptor       EsConfigInterceptor
 	clientConstructor func(args ClientArgs) (*elastic.Client, error)
 	reloadable        bool
+	named             bool
 }
 
 // ProvidersOptionFunc is the type of functional providersOption for Providers. Use this type to change how Providers work.
@@ -35,3 +36,13 @@ func WithReload(shouldReload bool) ProvidersOptionFunc {
 		options.reloadable = shouldReload
 	}
 }
+
+// WithNamedInstances toggles whether each configured client under "es" is
+// provided as a named *elastic.Client, which can be injected by the tag
+// `name:"<name>"`. A misspelled name fails when the graph is built. By default,
+// only the default client is provided.
+func WithNamedInstances(shouldProvide bool) ProvidersOptionFunc {
+	return func(options *providersOption) {
+		options.named = shouldProvide
+	}
+}
@@ -7,7 +7,7 @@ import (
 	"testing"
 
 	"github.com/DoNewsCode/core/config"
-	"github.com/DoNewsCode/core/contract"
+	"github.com/DoNewsCode/core/contract/lifecycle"
 	"github.com/DoNewsCode/core/di"
 	"github.com/DoNewsCode/core/events"
 
@@ -102,7 +102,7 @@ func TestNewEsFactory(t *testing.T) {
 	})
 
 	t.Run("should not reload if the providersOption forbids", func(t *testing.T) {
-		dispatcher := &events.Event[contract.ConfigUnmarshaler]{}
+		dispatcher := &events.Event[lifecycle.ConfigReloadPayload]{}
 		esFactory, cleanup := provideEsFactory(&providersOption{})(factoryIn{
 			Conf: config.MapAdapter{"es": map[string]any{
 				// elasticsearch server doesn't exist at this port
@@ -116,7 +116,7 @@ func TestNewEsFactory(t *testing.T) {
 
 		def1, err := esFactory.Make("default")
 		assert.NoError(t, err)
-		dispatcher.Fire(context.Background(), nil)
+		dispatcher.Fire(context.Background(), lifecycle.ConfigReloadPayload{})
 
 		def2, err := esFactory.Make("default")
 		assert.NoError(t, err)
@@ -125,7 +125,7 @@ func TestNewEsFactory(t *testing.T) {
 	})
 
 	t.Run("should reload if the providersOption allows", func(t *testing.T) {
-		dispatcher := &events.Event[contract.ConfigUnmarshaler]{}
+		dispatcher := &events.Event[lifecycle.ConfigReloadPayload]{}
 		esFactory, cleanup := provideEsFactory(&providersOption{reloadable: true})(factoryIn{
 			Conf: config.MapAdapter{"es": map[string]any{
 				// elasticsearch server doesn't exist at this port
@@ -139,11 +139,20 @@ func TestNewEsFactory(t *testing.T) {
 
 		def1, err := esFactory.Make("default")
 		assert.NoError(t, err)
-		dispatcher.Fire(context.Background(), nil)
+		dispatcher.Fire(context.Background(), lifecycle.ConfigReloadPayload{
+			Changes: lifecycle.ConfigChanges{"es.other.url": {New: "http://127.0.0.1:9998"}},
+		})
 		def2, err := esFactory.Make("default")
 		assert.NoError(t, err)
+		assert.Same(t, def1, def2)
+
+		dispatcher.Fire(context.Background(), lifecycle.ConfigReloadPayload{
+			Changes: lifecycle.ConfigChanges{"es.default.url": {Old: "http://127.0.0.1:9999"}},
+		})
+		def3, err := esFactory.Make("default")
+		assert.NoError(t, err)
 
-		assert.NotSame(t, def1, def2)
+		assert.NotSame(t, def1, def3)
 	})
 }
 
@@ -9,6 +9,7 @@ import (
 	"github.com/DoNewsCode/core/contract"
 	"github.com/DoNewsCode/core/contract/lifecycle"
 	"github.com/DoNewsCode/core/di"
+	"github.com/DoNewsCode/core/health"
 
 	"github.com/go-kit/log"
 	"github.com/opentracing-contrib/go-grpc"
@@ -19,6 +20,7 @@ import (
 
 type providersOption struct {
 	reloadable  bool
+	named       bool
 	interceptor EtcdConfigInterceptor
 }
 
@@ -42,6 +44,16 @@ func WithReload(shouldReload bool) ProvidersOptionFunc {
 	}
 }
 
+// WithNamedInstances toggles whether each configured client under "etcd" is
+// provided as a named *clientv3.Client, which can be injected by the tag
+// `name:"<name>"`. A misspelled name fails when the graph is built. By default,
+// only the default client is provided.
+func WithNamedInstances(shouldProvide bool) ProvidersOptionFunc {
+	return func(options *providersOption) {
+		options.named = shouldProvide
+	}
+}
+
 /*
 Providers returns a set of dependencies including the Maker, the default *clientv3.Client and the exported configs.
 	Depends On:
@@ -53,18 +65,25 @@ Providers returns a set of dependencies including the Maker, the default *client
 		Maker
 		Factory
 		*clientv3.Client
+		*clientv3.Client `name:"<name>"` (WithNamedInstances)
+		health.Checker `group:"health"`
 */
 func Providers(opts ...ProvidersOptionFunc) di.Deps {
 	option := providersOption{interceptor: func(name string, options *clientv3.Config) {}}
 	for _, f := range opts {
 		f(&option)
 	}
-	return di.Deps{
+	deps := di.Deps{
 		provideFactory(&option),
+		provideHealthCheck,
 		provideDefaultClient,
 		provideConfig,
 		di.Bind(new(*Factory), new(Maker)),
 	}
+	if option.named {
+		deps = append(deps, di.Named[Maker, *clientv3.Client]("etcd"))
+	}
+	return deps
 }
 
 // EtcdConfigInterceptor is an injector type hint that allows user to do
@@ -132,8 +151,12 @@ func provideFactory(option *providersOption) func(p factoryIn) (*Factory, func()
 			}, nil
 		})
 		if option.reloadable && p.Dispatcher != nil {
-			p.Dispatcher.On(func(_ context.Context, _ contract.ConfigUnmarshaler) error {
-				factory.Close()
+			p.Dispatcher.On(func(_ context.Context, payload lifecycle.ConfigReloadPayload) error {
+				for name := range factory.List() {
+					if payload.Changes.Changed("etcd." + name) {
+						factory.CloseConn(name)
+					}
+				}
 				return nil
 			})
 		}
@@ -186,3 +209,22 @@ func provideConfig() configOut {
 func duration(d config.Duration) time.Duration {
 	return d.Duration
 }
+
+type healthOut struct {
+	di.Out
+
+	Checker health.Checker `group:"health"`
+}
+
+// provideHealthCheck exports a health check that pings every opened etcd client.
+func provideHealthCheck(factory *Factory) healthOut {
+	return healthOut{Checker: health.FactoryChecker("etcd", factory, func(ctx context.Context, client *clientv3.Client) error {
+		var err error
+		for _, endpoint := range client.Endpoints() {
+			if _, err = client.Status(ctx, endpoint); err == nil {
+				return nil
+			}
+		}
+		return err
+	})}
+}
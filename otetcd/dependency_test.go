@@ -7,7 +7,7 @@ import (
 
 	"github.com/DoNewsCode/core"
 	"github.com/DoNewsCode/core/config"
-	"github.com/DoNewsCode/core/contract"
+	"github.com/DoNewsCode/core/contract/lifecycle"
 	"github.com/DoNewsCode/core/di"
 	"github.com/DoNewsCode/core/events"
 
@@ -51,7 +51,7 @@ func TestProvideFactory(t *testing.T) {
 		{"no reload", false},
 	} {
 		t.Run(c.name, func(t *testing.T) {
-			dispatcher := &events.Event[contract.ConfigUnmarshaler]{}
+			dispatcher := &events.Event[lifecycle.ConfigReloadPayload]{}
 			out, cleanup := provideFactory(&providersOption{reloadable: c.reload})(factoryIn{
 				Conf: config.MapAdapter{"etcd": map[string]Option{
 					"default": {
@@ -11,6 +11,7 @@ import (
 	"github.com/DoNewsCode/core/contract"
 	"github.com/DoNewsCode/core/contract/lifecycle"
 	"github.com/DoNewsCode/core/di"
+	"github.com/DoNewsCode/core/health"
 
 	"github.com/go-kit/log"
 	"github.com/oklog/run"
@@ -22,6 +23,7 @@ import (
 /*
 Providers returns a set of database related providers for package core. It includes
 the Maker, database configs and the default *gorm.DB instance.
+
 	Depends On:
 		contract.ConfigUnmarshaler
 		log.Logger
@@ -32,6 +34,8 @@ the Maker, database configs and the default *gorm.DB instance.
 		Maker
 		Factory
 		*gorm.DB
+		*gorm.DB `name:"<name>"` (WithNamedInstances)
+		health.Checker `group:"health"`
 */
 func Providers(opt ...ProvidersOptionFunc) di.Deps {
 	o := providersOption{
@@ -41,17 +45,22 @@ func Providers(opt ...ProvidersOptionFunc) di.Deps {
 	for _, f := range opt {
 		f(&o)
 	}
-	return di.Deps{
+	deps := di.Deps{
 		provideConfig,
 		provideDefaultDatabase,
 		provideDBFactory(&o),
+		provideHealthCheck,
 		di.Bind(new(*Factory), new(Maker)),
 	}
+	if o.named {
+		deps = append(deps, di.Named[Maker, *gorm.DB]("gorm"))
+	}
+	return deps
 }
 
 type databaseConf struct {
-	Database                                 string `json:"database" yaml:"database"`
-	Dsn                                      string `json:"dsn" yaml:"dsn"`
+	Database                                 string `json:"database" yaml:"database" validate:"required" description:"The name of the driver, such as mysql, sqlite or clickhouse"`
+	Dsn                                      string `json:"dsn" yaml:"dsn" description:"The data source name passed to the driver"`
 	SkipDefaultTransaction                   bool   `json:"skipDefaultTransaction" yaml:"skipDefaultTransaction"`
 	FullSaveAssociations                     bool   `json:"fullSaveAssociations" yaml:"fullSaveAssociations"`
 	DryRun                                   bool   `json:"dryRun" yaml:"dryRun"`
@@ -72,8 +81,8 @@ type databaseConf struct {
 type poolConf struct {
 	ConnMaxIdleTime config.Duration `json:"connMaxIdleTime" yaml:"connMaxIdleTime"`
 	ConnMaxLifeTime config.Duration `json:"connMaxLifeTime" yaml:"connMaxLifeTime"`
-	MaxIdleConns    int             `json:"maxIdleConns" yaml:"maxIdleConns"`
-	MaxOpenConns    int             `json:"maxOpenConns" yaml:"maxOpenConns"`
+	MaxIdleConns    int             `json:"maxIdleConns" yaml:"maxIdleConns" validate:"min=0" description:"The maximum number of idle connections"`
+	MaxOpenConns    int             `json:"maxOpenConns" yaml:"maxOpenConns" validate:"min=0" description:"The maximum number of open connections, 0 means unlimited"`
 }
 
 type metricsConf struct {
@@ -240,8 +249,12 @@ func provideDBFactory(options *providersOption) func(p factoryIn) (databaseOut,
 			}, err
 		})
 		if options.reloadable && factoryIn.OnReloadEvent != nil {
-			factoryIn.OnReloadEvent.On(func(_ context.Context, _ contract.ConfigUnmarshaler) error {
-				factory.Close()
+			factoryIn.OnReloadEvent.On(func(_ context.Context, payload lifecycle.ConfigReloadPayload) error {
+				for name := range factory.List() {
+					if payload.Changes.Changed("gorm." + name) {
+						factory.CloseConn(name)
+					}
+				}
 				return nil
 			})
 		}
@@ -306,3 +319,20 @@ func provideConfig() configOut {
 	}
 	return configOut{Config: exported}
 }
+
+type healthOut struct {
+	di.Out
+
+	Checker health.Checker `group:"health"`
+}
+
+// provideHealthCheck exports a health check that pings every opened database connection.
+func provideHealthCheck(factory *Factory) healthOut {
+	return healthOut{Checker: health.FactoryChecker("gorm", factory, func(ctx context.Context, db *gorm.DB) error {
+		sqlDB, err := db.DB()
+		if err != nil {
+			return err
+		}
+		return sqlDB.PingContext(ctx)
+	})}
+}
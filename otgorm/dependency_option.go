@@ -10,6 +10,7 @@ type providersOption struct {
 	interceptor GormConfigInterceptor
 	drivers     Drivers
 	reloadable  bool
+	named       bool
 }
 
 // ProvidersOptionFunc is the type of functional providersOption for Providers. Use this type to change how Providers work.
@@ -42,3 +43,13 @@ func WithReload(shouldReload bool) ProvidersOptionFunc {
 		options.reloadable = shouldReload
 	}
 }
+
+// WithNamedInstances toggles whether each configured database under "gorm" is
+// provided as a named *gorm.DB, which can be injected by the tag
+// `name:"<name>"`. A misspelled name fails when the graph is built. By default,
+// only the default database is provided.
+func WithNamedInstances(shouldProvide bool) ProvidersOptionFunc {
+	return func(options *providersOption) {
+		options.named = shouldProvide
+	}
+}
@@ -1,12 +1,13 @@
 package otgorm
 
 import (
+	"context"
 	"os"
 	"testing"
 
 	"github.com/DoNewsCode/core"
 	"github.com/DoNewsCode/core/config"
-	"github.com/DoNewsCode/core/contract"
+	"github.com/DoNewsCode/core/contract/lifecycle"
 	"github.com/DoNewsCode/core/di"
 	"github.com/DoNewsCode/core/events"
 
@@ -40,7 +41,7 @@ func TestProvideDBFactory(t *testing.T) {
 	for driverName := range gorms {
 		for _, reloadable := range []bool{true, false} {
 			t.Run(driverName, func(t *testing.T) {
-				dispatcher := &events.Event[contract.ConfigUnmarshaler]{}
+				dispatcher := &events.Event[lifecycle.ConfigReloadPayload]{}
 				out, cleanup, _ := provideDBFactory(&providersOption{reloadable: reloadable})(factoryIn{
 					Conf:          config.MapAdapter{"gorm": gorms},
 					Logger:        log.NewNopLogger(),
@@ -85,7 +86,52 @@ func TestGorm(t *testing.T) {
 	})
 }
 
+func TestGorm_namedInstances(t *testing.T) {
+	c := core.New(core.WithInline("gorm", map[string]any{
+		"default":   map[string]any{"database": "sqlite", "dsn": ":memory:"},
+		"reporting": map[string]any{"database": "sqlite", "dsn": ":memory:"},
+	}))
+	c.ProvideEssentials()
+	c.Provide(Providers(WithNamedInstances(true)))
+	c.Invoke(func(in struct {
+		di.In
+		Default   *gorm.DB
+		Reporting *gorm.DB `name:"reporting"`
+		Maker     Maker
+	}) {
+		reporting, err := in.Maker.Make("reporting")
+		assert.NoError(t, err)
+		assert.Same(t, reporting, in.Reporting)
+		assert.NotSame(t, in.Default, in.Reporting)
+	})
+}
+
 func TestProvideConfigs(t *testing.T) {
 	c := provideConfig()
 	assert.NotEmpty(t, c.Config)
 }
+
+func TestProvideHealthCheck(t *testing.T) {
+	out, cleanup, _ := provideDBFactory(&providersOption{})(factoryIn{
+		Conf: config.MapAdapter{"gorm": map[string]any{
+			"default": map[string]any{
+				"database": "sqlite",
+				"dsn":      ":memory:",
+			},
+		}},
+		Logger: log.NewNopLogger(),
+	})
+	defer cleanup()
+
+	checker := provideHealthCheck(out.Factory).Checker
+	assert.Equal(t, "gorm", checker.Name)
+	assert.NoError(t, checker.Check(context.Background()))
+
+	db, err := out.Factory.Make("default")
+	assert.NoError(t, err)
+	assert.NoError(t, checker.Check(context.Background()))
+
+	sqlDB, _ := db.DB()
+	sqlDB.Close()
+	assert.Error(t, checker.Check(context.Background()))
+}
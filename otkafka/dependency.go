@@ -29,6 +29,8 @@ Providers is a set of dependencies including ReaderMaker, WriterMaker and export
 		WriterMaker
 		*kafka.Reader
 		*kafka.Writer
+		*kafka.Reader `name:"<name>"` (WithNamedReaders)
+		*kafka.Writer `name:"<name>"` (WithNamedWriters)
 		*readerCollector
 		*writerCollector
 */
@@ -40,12 +42,19 @@ func Providers(optionFunc ...ProvidersOptionFunc) di.Deps {
 	for _, f := range optionFunc {
 		f(&option)
 	}
-	return di.Deps{
+	deps := di.Deps{
 		provideKafkaFactory(&option),
 		provideConfig,
 		di.Bind(new(WriterFactory), new(WriterMaker)),
 		di.Bind(new(ReaderFactory), new(ReaderMaker)),
 	}
+	if option.namedReaders {
+		deps = append(deps, di.Named[ReaderMaker, *kafka.Reader]("kafka.reader"))
+	}
+	if option.namedWriters {
+		deps = append(deps, di.Named[WriterMaker, *kafka.Writer]("kafka.writer"))
+	}
+	return deps
 }
 
 // WriterMaker models a WriterFactory
@@ -164,9 +173,17 @@ func provideKafkaFactory(option *providersOption) func(p factoryIn) (factoryOut,
 		}
 
 		if option.writerReloadable && p.Dispatcher != nil {
-			p.Dispatcher.On(func(ctx context.Context, Config contract.ConfigUnmarshaler) error {
-				wf.Close()
-				rf.Close()
+			p.Dispatcher.On(func(_ context.Context, payload lifecycle.ConfigReloadPayload) error {
+				for name := range wf.List() {
+					if payload.Changes.Changed("kafka.writer." + name) {
+						wf.CloseConn(name)
+					}
+				}
+				for name := range rf.List() {
+					if payload.Changes.Changed("kafka.reader." + name) {
+						rf.CloseConn(name)
+					}
+				}
 				return nil
 			})
 		}
@@ -15,6 +15,8 @@ type providersOption struct {
 	readerInterceptor ReaderInterceptor
 	writerReloadable  bool
 	writerInterceptor WriterInterceptor
+	namedReaders      bool
+	namedWriters      bool
 }
 
 // ProvidersOptionFunc is the type of functional providersOption for Providers. Use this type to change how Providers work.
@@ -53,3 +55,21 @@ func WithWriterReload(shouldReload bool) ProvidersOptionFunc {
 		options.writerReloadable = shouldReload
 	}
 }
+
+// WithNamedReaders toggles whether each configured reader under "kafka.reader"
+// is provided as a named *kafka.Reader, such as *kafka.Reader `name:"default"`.
+// A misspelled name fails when the graph is built.
+func WithNamedReaders(shouldProvide bool) ProvidersOptionFunc {
+	return func(options *providersOption) {
+		options.namedReaders = shouldProvide
+	}
+}
+
+// WithNamedWriters toggles whether each configured writer under "kafka.writer"
+// is provided as a named *kafka.Writer, such as *kafka.Writer `name:"default"`.
+// A misspelled name fails when the graph is built.
+func WithNamedWriters(shouldProvide bool) ProvidersOptionFunc {
+	return func(options *providersOption) {
+		options.namedWriters = shouldProvide
+	}
+}
@@ -6,7 +6,7 @@ import (
 	"testing"
 
 	"github.com/DoNewsCode/core/config"
-	"github.com/DoNewsCode/core/contract"
+	"github.com/DoNewsCode/core/contract/lifecycle"
 	"github.com/DoNewsCode/core/di"
 	"github.com/DoNewsCode/core/events"
 
@@ -86,7 +86,7 @@ func TestProvideKafka(t *testing.T) {
 		{"not reload", false},
 	} {
 		t.Run(c.name, func(t *testing.T) {
-			dispatcher := &events.Event[contract.ConfigUnmarshaler]{}
+			dispatcher := &events.Event[lifecycle.ConfigReloadPayload]{}
 			Out, cleanupReader, cleanupWriter, err := provideKafkaFactory(&providersOption{
 				readerReloadable: c.reloadable,
 				writerReloadable: c.reloadable,
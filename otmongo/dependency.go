@@ -8,6 +8,7 @@ import (
 	"github.com/DoNewsCode/core/contract"
 	"github.com/DoNewsCode/core/contract/lifecycle"
 	"github.com/DoNewsCode/core/di"
+	"github.com/DoNewsCode/core/health"
 
 	"github.com/go-kit/log"
 	"github.com/opentracing/opentracing-go"
@@ -28,18 +29,25 @@ default mongo.Client and exported configs.
 		Factory
 		Maker
 		*mongo.Client
+		*mongo.Client `name:"<name>"` (WithNamedInstances)
+		health.Checker `group:"health"`
 */
 func Providers(optionFunc ...ProvidersOptionFunc) di.Deps {
 	o := providersOption{interceptor: func(name string, clientOptions *options.ClientOptions) {}}
 	for _, f := range optionFunc {
 		f(&o)
 	}
-	return di.Deps{
+	deps := di.Deps{
 		provideMongoFactory(&o),
+		provideHealthCheck,
 		provideDefaultClient,
 		provideConfig,
 		di.Bind(new(*Factory), new(Maker)),
 	}
+	if o.named {
+		deps = append(deps, di.Named[Maker, *mongo.Client]("mongo"))
+	}
+	return deps
 }
 
 // factoryIn is the injection parameter for Provide.
@@ -52,13 +60,36 @@ type factoryIn struct {
 	Dispatcher lifecycle.ConfigReload `optional:"true"`
 }
 
-// Provide creates Factory and *mongo.Client. It is a valid dependency for
-// package core.
-func provideMongoFactory(providerOption *providersOption) func(p factoryIn) (*Factory, func()) {
+// factoryOut is the result of provideMongoFactory.
+type factoryOut struct {
+	di.Out
+
+	Factory *Factory
+}
+
+// Module implements di.Modular
+func (m factoryOut) Module() any {
+	return m
+}
+
+// ProvideCloserContext disconnects the opened mongo clients. The disconnection
+// is bounded by the deadline of ctx, i.e. serve.closerTimeout.
+func (m factoryOut) ProvideCloserContext(ctx context.Context) (err error) {
+	for name, pair := range m.Factory.List() {
+		if e := pair.Conn.Disconnect(ctx); e != nil && err == nil {
+			err = fmt.Errorf("failed to disconnect mongo %s: %w", name, e)
+		}
+	}
+	return err
+}
+
+// provideMongoFactory creates Factory and *mongo.Client. It is a valid
+// dependency for package core.
+func provideMongoFactory(providerOption *providersOption) func(p factoryIn) factoryOut {
 	if providerOption.interceptor == nil {
 		providerOption.interceptor = func(name string, clientOptions *options.ClientOptions) {}
 	}
-	return func(p factoryIn) (*Factory, func()) {
+	return func(p factoryIn) factoryOut {
 		factory := di.NewFactory[*mongo.Client](func(name string) (pair di.Pair[*mongo.Client], err error) {
 			var conf struct{ URI string }
 			if err := p.Conf.Unmarshal(fmt.Sprintf("mongo.%s", name), &conf); err != nil {
@@ -87,12 +118,16 @@ func provideMongoFactory(providerOption *providersOption) func(p factoryIn) (*Fa
 			}, nil
 		})
 		if providerOption.reloadable && p.Dispatcher != nil {
-			p.Dispatcher.On(func(_ context.Context, _ contract.ConfigUnmarshaler) error {
-				factory.Close()
+			p.Dispatcher.On(func(_ context.Context, payload lifecycle.ConfigReloadPayload) error {
+				for name := range factory.List() {
+					if payload.Changes.Changed("mongo." + name) {
+						factory.CloseConn(name)
+					}
+				}
 				return nil
 			})
 		}
-		return factory, factory.Close
+		return factoryOut{Factory: factory}
 	}
 }
 
@@ -125,3 +160,16 @@ func provideConfig() configOut {
 	}
 	return configOut{Config: configs}
 }
+
+type healthOut struct {
+	di.Out
+
+	Checker health.Checker `group:"health"`
+}
+
+// provideHealthCheck exports a health check that pings every opened mongo client.
+func provideHealthCheck(factory *Factory) healthOut {
+	return healthOut{Checker: health.FactoryChecker("mongo", factory, func(ctx context.Context, client *mongo.Client) error {
+		return client.Ping(ctx, nil)
+	})}
+}
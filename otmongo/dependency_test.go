@@ -1,10 +1,11 @@
 package otmongo
 
 import (
+	"context"
 	"testing"
 
 	"github.com/DoNewsCode/core/config"
-	"github.com/DoNewsCode/core/contract"
+	"github.com/DoNewsCode/core/contract/lifecycle"
 	"github.com/DoNewsCode/core/events"
 
 	"github.com/stretchr/testify/assert"
@@ -24,8 +25,8 @@ func TestNewMongoFactory(t *testing.T) {
 			"not reload", false,
 		},
 	} {
-		dispatcher := &events.Event[contract.ConfigUnmarshaler]{}
-		factory, cleanup := provideMongoFactory(&providersOption{
+		dispatcher := &events.Event[lifecycle.ConfigReloadPayload]{}
+		out := provideMongoFactory(&providersOption{
 			reloadable: c.reloadable,
 		})(factoryIn{
 			In: dig.In{},
@@ -42,15 +43,14 @@ func TestNewMongoFactory(t *testing.T) {
 			Tracer:     nil,
 			Dispatcher: dispatcher,
 		})
-		def, err := factory.Make("default")
+		def, err := out.Factory.Make("default")
 		assert.NoError(t, err)
 		assert.NotNil(t, def)
-		alt, err := factory.Make("alternative")
+		alt, err := out.Factory.Make("alternative")
 		assert.NoError(t, err)
 		assert.NotNil(t, alt)
-		assert.NotNil(t, cleanup)
 		assert.Equal(t, c.reloadable, dispatcher.ListenerCount() == 1)
-		cleanup()
+		assert.NoError(t, out.ProvideCloserContext(context.Background()))
 	}
 }
 
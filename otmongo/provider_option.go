@@ -11,6 +11,7 @@ type MongoConfigInterceptor func(name string, clientOptions *options.ClientOptio
 type providersOption struct {
 	interceptor MongoConfigInterceptor
 	reloadable  bool
+	named       bool
 }
 
 // ProvidersOptionFunc is the type of functional providersOption for Providers. Use this type to change how Providers work.
@@ -32,3 +33,13 @@ func WithReload(shouldReload bool) ProvidersOptionFunc {
 		options.reloadable = shouldReload
 	}
 }
+
+// WithNamedInstances toggles whether each configured client under "mongo" is
+// provided as a named *mongo.Client, which can be injected by the tag
+// `name:"<name>"`. A misspelled name fails when the graph is built. By default,
+// only the default client is provided.
+func WithNamedInstances(shouldProvide bool) ProvidersOptionFunc {
+	return func(options *providersOption) {
+		options.named = shouldProvide
+	}
+}
@@ -9,6 +9,7 @@ import (
 	"github.com/DoNewsCode/core/contract"
 	"github.com/DoNewsCode/core/contract/lifecycle"
 	"github.com/DoNewsCode/core/di"
+	"github.com/DoNewsCode/core/health"
 
 	"github.com/go-kit/log"
 	"github.com/go-kit/log/level"
@@ -29,7 +30,9 @@ Maker, the default redis.UniversalClient and exported configs.
 		Maker
 		Factory
 		redis.UniversalClient
+		redis.UniversalClient `name:"<name>"` (WithNamedInstances)
 		*collector
+		health.Checker `group:"health"`
 */
 func Providers(opts ...ProvidersOptionFunc) di.Deps {
 	option := providersOption{
@@ -38,12 +41,17 @@ func Providers(opts ...ProvidersOptionFunc) di.Deps {
 	for _, f := range opts {
 		f(&option)
 	}
-	return di.Deps{
+	deps := di.Deps{
 		provideRedisFactory(&option),
+		provideHealthCheck,
 		provideDefaultClient,
 		provideConfig,
 		di.Bind(new(*Factory), new(Maker)),
 	}
+	if option.named {
+		deps = append(deps, di.Named[Maker, redis.UniversalClient]("redis"))
+	}
+	return deps
 }
 
 // factoryIn is the injection parameter for provideRedisFactory.
@@ -161,8 +169,12 @@ func provideRedisFactory(option *providersOption) func(p factoryIn) (factoryOut,
 			}, nil
 		})
 		if option.reloadable && p.Dispatcher != nil {
-			p.Dispatcher.On(func(ctx context.Context, Config contract.ConfigUnmarshaler) error {
-				factory.Close()
+			p.Dispatcher.On(func(_ context.Context, payload lifecycle.ConfigReloadPayload) error {
+				for name := range factory.List() {
+					if payload.Changes.Changed("redis." + name) {
+						factory.CloseConn(name)
+					}
+				}
 				return nil
 			})
 		}
@@ -215,3 +227,16 @@ func provideConfig() configOut {
 	}
 	return configOut{Config: configs}
 }
+
+type healthOut struct {
+	di.Out
+
+	Checker health.Checker `group:"health"`
+}
+
+// provideHealthCheck exports a health check that pings every opened redis client.
+func provideHealthCheck(factory *Factory) healthOut {
+	return healthOut{Checker: health.FactoryChecker("redis", factory, func(ctx context.Context, client redis.UniversalClient) error {
+		return client.Ping(ctx).Err()
+	})}
+}
@@ -15,6 +15,7 @@ type ProvidersOptionFunc func(options *providersOption)
 type providersOption struct {
 	interceptor RedisConfigurationInterceptor
 	reloadable  bool
+	named       bool
 }
 
 // WithConfigInterceptor instructs the Providers to accept the
@@ -33,3 +34,13 @@ func WithReload(shouldReload bool) ProvidersOptionFunc {
 		options.reloadable = shouldReload
 	}
 }
+
+// WithNamedInstances toggles whether each configured client under "redis" is
+// provided as a named redis.UniversalClient, which can be injected by the tag
+// `name:"<name>"`. A misspelled name fails when the graph is built. By default,
+// only the default client is provided.
+func WithNamedInstances(shouldProvide bool) ProvidersOptionFunc {
+	return func(options *providersOption) {
+		options.named = shouldProvide
+	}
+}
@@ -1,10 +1,11 @@
 package otredis
 
 import (
+	"context"
 	"testing"
 
 	"github.com/DoNewsCode/core/config"
-	"github.com/DoNewsCode/core/contract"
+	"github.com/DoNewsCode/core/contract/lifecycle"
 	"github.com/DoNewsCode/core/events"
 
 	"github.com/go-kit/log"
@@ -25,7 +26,7 @@ func TestNewRedisFactory(t *testing.T) {
 		{"not reload", false},
 	} {
 		t.Run(c.name, func(t *testing.T) {
-			dispatcher := &events.Event[contract.ConfigUnmarshaler]{}
+			dispatcher := &events.Event[lifecycle.ConfigReloadPayload]{}
 			redisOut, cleanup := provideRedisFactory(&providersOption{reloadable: c.reload})(factoryIn{
 				Conf: config.MapAdapter{"redis": map[string]RedisUniversalOptions{
 					"default":     {},
@@ -48,6 +49,29 @@ func TestNewRedisFactory(t *testing.T) {
 	}
 }
 
+func TestProvideRedisFactory_reload(t *testing.T) {
+	dispatcher := &events.Event[lifecycle.ConfigReloadPayload]{}
+	redisOut, cleanup := provideRedisFactory(&providersOption{reloadable: true})(factoryIn{
+		Conf: config.MapAdapter{"redis": map[string]RedisUniversalOptions{
+			"default":     {},
+			"alternative": {},
+		}},
+		Logger:     log.NewNopLogger(),
+		Dispatcher: dispatcher,
+	})
+	defer cleanup()
+
+	def, _ := redisOut.Factory.Make("default")
+	alt, _ := redisOut.Factory.Make("alternative")
+	dispatcher.Fire(context.Background(), lifecycle.ConfigReloadPayload{
+		Changes: lifecycle.ConfigChanges{"log.level": {Old: "info", New: "debug"}, "redis.alternative.db": {Old: 0, New: 1}},
+	})
+	def2, _ := redisOut.Factory.Make("default")
+	alt2, _ := redisOut.Factory.Make("alternative")
+	assert.Same(t, def, def2)
+	assert.NotSame(t, alt, alt2)
+}
+
 func TestProvideConfigs(t *testing.T) {
 	var r redis.UniversalOptions
 	c := provideConfig()
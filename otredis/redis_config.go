@@ -8,11 +8,11 @@ import (
 type RedisUniversalOptions struct {
 	// Either a single address or a seed list of host:port addresses
 	// of cluster/sentinel nodes.
-	Addrs []string `json:"addrs" yaml:"addrs"`
+	Addrs []string `json:"addrs" yaml:"addrs" validate:"dive,hostname_port" description:"A single address or a seed list of host:port addresses of cluster/sentinel nodes. Defaults to 127.0.0.1:6379"`
 
 	// Database to be selected after connecting to the server.
 	// Only single-node and failover clients.
-	DB int `json:"db" yaml:"db"`
+	DB int `json:"db" yaml:"db" validate:"min=0" description:"Database to be selected after connecting to the server. Only single-node and failover clients"`
 
 	// Common options.
 
@@ -44,5 +44,5 @@ type RedisUniversalOptions struct {
 
 	// The sentinel master name.
 	// Only failover clients.
-	MasterName string `json:"masterName" yaml:"masterName"`
+	MasterName string `json:"masterName" yaml:"masterName" description:"The sentinel master name. Only failover clients"`
 }
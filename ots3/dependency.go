@@ -96,8 +96,12 @@ func provideFactory(option *providersOption) func(p factoryIn) *Factory {
 		})
 
 		if option.reloadable && p.Dispatcher != nil {
-			p.Dispatcher.On(func(ctx context.Context, Config contract.ConfigUnmarshaler) error {
-				factory.Close()
+			p.Dispatcher.On(func(_ context.Context, payload lifecycle.ConfigReloadPayload) error {
+				for name := range factory.List() {
+					if payload.Changes.Changed("s3." + name) {
+						factory.CloseConn(name)
+					}
+				}
 				return nil
 			})
 		}
@@ -5,7 +5,7 @@ import (
 
 	"github.com/DoNewsCode/core"
 	"github.com/DoNewsCode/core/config"
-	"github.com/DoNewsCode/core/contract"
+	"github.com/DoNewsCode/core/contract/lifecycle"
 	"github.com/DoNewsCode/core/di"
 	"github.com/DoNewsCode/core/events"
 
@@ -33,7 +33,7 @@ func TestNewUploadManagerFactory(t *testing.T) {
 }
 
 func TestNewUploadManagerFactory_customOption(t *testing.T) {
-	dispatcher := &events.Event[contract.ConfigUnmarshaler]{}
+	dispatcher := &events.Event[lifecycle.ConfigReloadPayload]{}
 	var called bool
 	s3out := provideFactory(&providersOption{ctor: func(args ManagerArgs) (*Manager, error) {
 		called = true
@@ -2,26 +2,41 @@ package core
 
 import (
 	"context"
+	"crypto/tls"
 	"fmt"
 	"net"
 	"net/http"
 	"os"
 	"os/signal"
+	"sort"
+	"strings"
 	"syscall"
+	"time"
 
 	"github.com/DoNewsCode/core/config"
 	"github.com/DoNewsCode/core/contract"
 	"github.com/DoNewsCode/core/contract/lifecycle"
+	"github.com/DoNewsCode/core/control/supervisor"
 	"github.com/DoNewsCode/core/cron"
 	"github.com/DoNewsCode/core/di"
 	"github.com/DoNewsCode/core/logging"
+	"github.com/DoNewsCode/core/srvgrpc"
+	"github.com/DoNewsCode/core/srvhttp"
 	"github.com/go-kit/log"
 	"github.com/go-kit/log/level"
 	"github.com/gorilla/mux"
+	"github.com/grpc-ecosystem/grpc-gateway/v2/runtime"
 	"github.com/oklog/run"
+	otgrpc "github.com/opentracing-contrib/go-grpc"
+	"github.com/opentracing-contrib/go-stdlib/nethttp"
+	"github.com/opentracing/opentracing-go"
 	"github.com/pkg/errors"
 	"github.com/spf13/cobra"
+	"golang.org/x/net/http2"
+	"golang.org/x/net/http2/h2c"
 	"google.golang.org/grpc"
+	"google.golang.org/grpc/credentials"
+	"google.golang.org/grpc/credentials/insecure"
 )
 
 type serveIn struct {
@@ -30,14 +45,20 @@ type serveIn struct {
 	Config             contract.ConfigAccessor
 	Logger             log.Logger
 	Container          contract.Container
-	HTTPServer         *http.Server                 `optional:"true"`
-	HTTPRouter         *mux.Router                  `optional:"true"`
-	GRPCServer         *grpc.Server                 `optional:"true"`
-	HTTPServerStart    lifecycle.HTTPServerStart    `optional:"true"`
-	HTTPServerShutdown lifecycle.HTTPServerShutdown `optional:"true"`
-	GRPCServerStart    lifecycle.GRPCServerStart    `optional:"true"`
-	GRPCServerShutdown lifecycle.GRPCServerShutdown `optional:"true"`
-	Cron               *cron.Cron                   `optional:"true"`
+	HTTPServer         *http.Server                    `optional:"true"`
+	HTTPRouter         *mux.Router                     `optional:"true"`
+	GRPCServer         *grpc.Server                    `optional:"true"`
+	HTTPServerStart    lifecycle.HTTPServerStart       `optional:"true"`
+	HTTPServerShutdown lifecycle.HTTPServerShutdown    `optional:"true"`
+	GRPCServerStart    lifecycle.GRPCServerStart       `optional:"true"`
+	GRPCServerShutdown lifecycle.GRPCServerShutdown    `optional:"true"`
+	ShutdownStart      lifecycle.ShutdownStart         `optional:"true"`
+	Cron               *cron.Cron                      `optional:"true"`
+	Closer             closeFunc                       `optional:"true"`
+	Tracer             opentracing.Tracer              `optional:"true"`
+	HTTPMetrics        *srvhttp.RequestDurationSeconds `optional:"true"`
+	GRPCMetrics        *srvgrpc.RequestDurationSeconds `optional:"true"`
+	RestartMetrics     *supervisor.RestartMetrics      `optional:"true"`
 }
 
 func NewServeModule(in serveIn) serveModule {
@@ -56,9 +77,24 @@ func (s serveModule) ProvideCommand(command *cobra.Command) {
 	command.AddCommand(newServeCmd(s.in))
 }
 
-type runGroupFunc func(ctx context.Context, logger logging.LevelLogger) (func() error, func(err error), error)
+// actorFunc returns a function that blocks until the actor completes, and a
+// function to stop the actor before the given context expires.
+type actorFunc func(ctx context.Context, logger logging.LevelLogger) (func() error, func(ctx context.Context), error)
 
-func (s serveIn) httpServe(ctx context.Context, logger logging.LevelLogger) (func() error, func(err error), error) {
+// listenFunc announces on the local network address.
+type listenFunc func(addr string) (net.Listener, error)
+
+func listenTCP(addr string) (net.Listener, error) {
+	return net.Listen("tcp", addr)
+}
+
+func (s serveIn) httpServe(listen listenFunc, h2cEnabled bool, inProcess *muxListener) actorFunc {
+	return func(ctx context.Context, logger logging.LevelLogger) (func() error, func(ctx context.Context), error) {
+		return s.serveHTTP(ctx, logger, listen, h2cEnabled, inProcess)
+	}
+}
+
+func (s serveIn) serveHTTP(ctx context.Context, logger logging.LevelLogger, listen listenFunc, h2cEnabled bool, inProcess *muxListener) (func() error, func(ctx context.Context), error) {
 	type httpConfig struct {
 		Disable           bool            `json:"disable" yaml:"disable"`
 		Addr              string          `json:"addr" yaml:"addr"`
@@ -67,6 +103,10 @@ func (s serveIn) httpServe(ctx context.Context, logger logging.LevelLogger) (fun
 		WriteTimeout      config.Duration `json:"writeTimeout" yaml:"writeTimeout"`
 		IdleTimeout       config.Duration `json:"idleTimeout" yaml:"idleTimeout"`
 		MaxHeaderBytes    int             `json:"maxHeaderBytes" yaml:"maxHeaderBytes"`
+		TLS               tlsConfig       `json:"tls" yaml:"tls"`
+		Gateway           struct {
+			Prefix string `json:"prefix" yaml:"prefix"`
+		} `json:"gateway" yaml:"gateway"`
 	}
 
 	var conf httpConfig
@@ -88,21 +128,52 @@ func (s serveIn) httpServe(ctx context.Context, logger logging.LevelLogger) (fun
 	if s.HTTPRouter == nil {
 		s.HTTPRouter = mux.NewRouter()
 	}
+	for _, m := range s.httpMiddlewares() {
+		s.HTTPRouter.Use(m.Middleware)
+	}
 	applyRouter(s.Container, s.HTTPRouter)
 
+	watchCtx, cancelWatch := context.WithCancel(ctx)
+	if inProcess != nil {
+		if err := applyGRPCGateway(watchCtx, s.Container, s.HTTPRouter, conf.Gateway.Prefix, inProcess); err != nil {
+			cancelWatch()
+			return nil, nil, errors.Wrap(err, "failed start http server")
+		}
+	}
+
 	s.HTTPRouter.Walk(func(route *mux.Route, router *mux.Router, ancestors []*mux.Route) error {
 		tpl, _ := route.GetPathTemplate()
 		level.Debug(logger).Log("tag", "http", "path", tpl)
 		return nil
 	})
 
+	var reloader *certReloader
+	if conf.TLS.enabled() {
+		var err error
+		if reloader, err = newCertReloader(conf.TLS, logger); err != nil {
+			cancelWatch()
+			return nil, nil, errors.Wrap(err, "failed start http server")
+		}
+	}
+
 	s.HTTPServer.Handler = s.HTTPRouter
+	if h2cEnabled {
+		s.HTTPServer.Handler = h2c.NewHandler(s.HTTPRouter, &http2.Server{})
+	}
 	httpAddr := conf.Addr
-	ln, err := net.Listen("tcp", httpAddr)
+	ln, err := listen(httpAddr)
 	if err != nil {
+		cancelWatch()
 		return nil, nil, errors.Wrap(err, "failed start http server")
 	}
+	if reloader != nil {
+		ln = tls.NewListener(ln, reloader.tlsConfig("h2", "http/1.1"))
+	}
+	stopped := make(chan struct{})
 	return func() error {
+			if reloader != nil {
+				reloader.watch(watchCtx)
+			}
 			logger.Infof("http service is listening at %s", ln.Addr())
 			s.HTTPServerStart.Fire(
 				ctx,
@@ -112,21 +183,92 @@ func (s serveIn) httpServe(ctx context.Context, logger logging.LevelLogger) (fun
 				ctx,
 				lifecycle.HTTPServerShutdownPayload{HTTPServer: s.HTTPServer, Listener: ln},
 			)
-			return s.HTTPServer.Serve(ln)
-		}, func(err error) {
-			_ = s.HTTPServer.Shutdown(context.Background())
+			err := s.HTTPServer.Serve(ln)
+			if errors.Is(err, http.ErrServerClosed) {
+				// Serve returns as soon as the shutdown begins. Wait for the
+				// in-flight requests to drain.
+				<-stopped
+			}
+			return err
+		}, func(ctx context.Context) {
+			defer close(stopped)
+			defer cancelWatch()
+			if err := s.HTTPServer.Shutdown(ctx); err != nil {
+				logger.Warnf("http server failed to shutdown gracefully: %s", err)
+				_ = s.HTTPServer.Close()
+			}
 			_ = ln.Close()
 		}, nil
 }
 
-func (s serveIn) grpcServe(ctx context.Context, logger logging.LevelLogger) (func() error, func(err error), error) {
+func (s serveIn) grpcServe(listen listenFunc, inProcess *muxListener) actorFunc {
+	return func(ctx context.Context, logger logging.LevelLogger) (func() error, func(ctx context.Context), error) {
+		return s.serveGRPC(ctx, logger, listen, inProcess)
+	}
+}
+
+func (s serveIn) serveGRPC(ctx context.Context, logger logging.LevelLogger, listen listenFunc, inProcess *muxListener) (func() error, func(ctx context.Context), error) {
 	if s.Config.Bool("grpc.disable") {
 		return nil, nil, nil
 	}
+
+	var (
+		tlsConf   tlsConfig
+		reloader  *certReloader
+		tlsListen bool
+		// gateway serves the in-process listener of the grpc gateway. It is
+		// the gRPC server itself, unless the server terminates TLS.
+		gateway *grpc.Server
+	)
+	if err := s.Config.Unmarshal("grpc.tls", &tlsConf); err != nil {
+		return nil, nil, errors.Wrap(err, "failed start grpc server")
+	}
+	if tlsConf.enabled() {
+		var err error
+		if reloader, err = newCertReloader(tlsConf, logger); err != nil {
+			return nil, nil, errors.Wrap(err, "failed start grpc server")
+		}
+	}
+
 	if s.GRPCServer == nil {
-		s.GRPCServer = grpc.NewServer()
+		var (
+			unary  []grpc.UnaryServerInterceptor
+			stream []grpc.StreamServerInterceptor
+		)
+		for _, interceptor := range s.grpcInterceptors() {
+			if interceptor.Unary != nil {
+				unary = append(unary, interceptor.Unary)
+			}
+			if interceptor.Stream != nil {
+				stream = append(stream, interceptor.Stream)
+			}
+		}
+		opts := []grpc.ServerOption{grpc.ChainUnaryInterceptor(unary...), grpc.ChainStreamInterceptor(stream...)}
+		if reloader != nil {
+			s.GRPCServer = grpc.NewServer(append(opts, grpc.Creds(credentials.NewTLS(reloader.tlsConfig("h2"))))...)
+			if inProcess != nil {
+				// The gateway dials in plaintext, so it is served by a twin
+				// server without the credentials.
+				gateway = grpc.NewServer(opts...)
+				applyGRPCServer(s.Container, gateway)
+			}
+		} else {
+			s.GRPCServer = grpc.NewServer(opts...)
+		}
+	} else {
+		if hasProvider[GRPCInterceptorProvider](s.Container) {
+			logger.Warn("the grpc interceptors provided by modules are ignored, since the *grpc.Server is provided to the container")
+		}
+		if reloader != nil {
+			// The credentials of an injected server cannot be changed. Terminate
+			// TLS at the listener instead.
+			tlsListen = true
+		}
 	}
 	applyGRPCServer(s.Container, s.GRPCServer)
+	if gateway == nil {
+		gateway = s.GRPCServer
+	}
 
 	for module, info := range s.GRPCServer.GetServiceInfo() {
 		for _, method := range info.Methods {
@@ -135,11 +277,26 @@ func (s serveIn) grpcServe(ctx context.Context, logger logging.LevelLogger) (fun
 	}
 
 	grpcAddr := s.Config.String("grpc.addr")
-	ln, err := net.Listen("tcp", grpcAddr)
+	ln, err := listen(grpcAddr)
 	if err != nil {
 		return nil, nil, errors.Wrap(err, "failed start grpc server")
 	}
+	if tlsListen {
+		ln = tls.NewListener(ln, reloader.tlsConfig("h2"))
+	}
+	var (
+		stopping = make(chan struct{})
+		stopped  = make(chan struct{})
+	)
+	watchCtx, cancelWatch := context.WithCancel(ctx)
 	return func() error {
+			if reloader != nil {
+				reloader.watch(watchCtx)
+			}
+			if inProcess != nil {
+				// The listener is closed by GracefulStop or Stop.
+				go gateway.Serve(inProcess)
+			}
 			logger.Infof("gRPC service is listening at %s", ln.Addr())
 			s.GRPCServerStart.Fire(
 				ctx,
@@ -149,14 +306,39 @@ func (s serveIn) grpcServe(ctx context.Context, logger logging.LevelLogger) (fun
 				ctx,
 				lifecycle.GRPCServerShutdownPayload{GRPCServer: s.GRPCServer, Listener: ln},
 			)
-			return s.GRPCServer.Serve(ln)
-		}, func(err error) {
-			s.GRPCServer.GracefulStop()
+			err := s.GRPCServer.Serve(ln)
+			select {
+			case <-stopping:
+				// Serve returns as soon as the shutdown begins. Wait for the
+				// in-flight RPCs to drain.
+				<-stopped
+			default:
+			}
+			return err
+		}, func(ctx context.Context) {
+			defer close(stopped)
+			defer cancelWatch()
+			close(stopping)
+			graceful := make(chan struct{})
+			go func() {
+				if gateway != s.GRPCServer {
+					gateway.GracefulStop()
+				}
+				s.GRPCServer.GracefulStop()
+				close(graceful)
+			}()
+			select {
+			case <-graceful:
+			case <-ctx.Done():
+				logger.Warnf("grpc server failed to shutdown gracefully: %s", ctx.Err())
+				gateway.Stop()
+				s.GRPCServer.Stop()
+			}
 			_ = ln.Close()
 		}, nil
 }
 
-func (s serveIn) cronServe(ctx context.Context, logger logging.LevelLogger) (func() error, func(err error), error) {
+func (s serveIn) cronServe(ctx context.Context, logger logging.LevelLogger) (func() error, func(ctx context.Context), error) {
 	if s.Config.Bool("cron.disable") {
 		return nil, nil, nil
 	}
@@ -169,7 +351,7 @@ func (s serveIn) cronServe(ctx context.Context, logger logging.LevelLogger) (fun
 		return func() error {
 				logger.Infof("cron runner started")
 				return s.Cron.Run(ctx)
-			}, func(err error) {
+			}, func(context.Context) {
 				cancel()
 			}, nil
 	}
@@ -201,10 +383,7 @@ func newServeCmd(s serveIn) *cobra.Command {
 		Short: "Start the server",
 		Long:  `Start the gRPC server, HTTP server, and cron job runner.`,
 		RunE: func(cmd *cobra.Command, args []string) error {
-			var (
-				g run.Group
-				l = logging.WithLevel(s.Logger)
-			)
+			var l = logging.WithLevel(s.Logger)
 
 			for _, m := range s.Container.Modules() {
 				l.Debugf("load module: %T", m)
@@ -213,29 +392,111 @@ func newServeCmd(s serveIn) *cobra.Command {
 			// Polyfill missing dependencies
 			setDefaultLifecycles(&s)
 
-			// Add serve and signalWatch
-			serves := []runGroupFunc{
-				s.httpServe,
-				s.grpcServe,
-				s.cronServe,
-				s.signalWatch,
+			timeout := s.Config.Duration("serve.shutdownTimeout")
+			if timeout <= 0 {
+				timeout = defaultShutdownTimeout
+			}
+
+			// The shutdown happens in the order of the phases: first mark the
+			// application as not ready, then drain the servers, then stop the
+			// background workers, and finally close the connections.
+			g := phasedGroup{
+				timeout: timeout,
+				logger:  l,
+				phases: []shutdownPhase{
+					{
+						name: "readiness",
+						run: func(ctx context.Context, deadline time.Time) {
+							s.ShutdownStart.Fire(ctx, lifecycle.ShutdownStartPayload{Deadline: deadline})
+						},
+					},
+					{name: "servers"},
+					{name: "workers"},
+					{
+						name: "closers",
+						run: func(ctx context.Context, deadline time.Time) {
+							if s.Closer != nil {
+								// Failures are logged in the summary of the closers.
+								_ = s.Closer(ctx)
+							}
+						},
+					},
+				},
+			}
+
+			execute, interrupt, err := s.signalWatch(cmd.Context(), l)
+			if err != nil {
+				return err
 			}
+			g.add(0, &actor{name: "signal watcher", execute: execute, stop: func(context.Context) { interrupt(nil) }})
 
-			for _, serve := range serves {
-				execute, interrupt, err := serve(cmd.Context(), l)
+			// In the single port mode, the http server and the gRPC server
+			// accept connections from the same listener at http.addr.
+			var (
+				listenHTTP listenFunc = listenTCP
+				listenGRPC listenFunc = listenTCP
+				singlePort            = s.Config.Bool("serve.singlePort") && !s.Config.Bool("http.disable") && !s.Config.Bool("grpc.disable")
+			)
+			if singlePort {
+				m, err := s.portMux(l)
+				if err != nil {
+					return err
+				}
+				defer m.close()
+				listenHTTP = func(string) (net.Listener, error) { return m.http, nil }
+				listenGRPC = func(string) (net.Listener, error) { return m.grpc, nil }
+				g.add(1, &actor{name: "port multiplexer", execute: m.serve, stop: func(context.Context) { m.close() }})
+			}
+
+			// The grpc gateway connects to the gRPC server in memory.
+			var inProcess *muxListener
+			if hasProvider[GRPCGatewayProvider](s.Container) {
+				if s.Config.Bool("grpc.disable") {
+					l.Warn("the grpc gateway is disabled along with the grpc server")
+				} else {
+					inProcess = newMuxListener(inProcessAddr{})
+				}
+			}
+
+			actors := []struct {
+				name  string
+				phase int
+				serve actorFunc
+			}{
+				{"http server", 1, s.httpServe(listenHTTP, singlePort, inProcess)},
+				{"grpc server", 1, s.grpcServe(listenGRPC, inProcess)},
+				{"cron runner", 2, s.cronServe},
+			}
+			for _, a := range actors {
+				execute, stop, err := a.serve(cmd.Context(), l)
 				if err != nil {
 					return err
 				}
 				if execute == nil {
 					continue
 				}
-				g.Add(execute, interrupt)
+				g.add(a.phase, &actor{name: a.name, execute: execute, stop: stop})
 			}
 
 			// Additional run groups
-			applyRunGroup(s.Container, &g)
+			var (
+				group  run.Group
+				policy supervisor.Policy
+			)
+			if err := s.Config.Unmarshal("serve.restart", &policy); err != nil {
+				return errors.Wrap(err, "failed to read serve.restart")
+			}
+			applyRunGroup(s.Container, &group, supervisor.Supervisor{Policy: policy, Logger: s.Logger, Metrics: s.RestartMetrics})
+			quit, cancel := context.WithCancel(context.Background())
+			group.Add(func() error {
+				<-quit.Done()
+				return nil
+			}, func(err error) {
+				cancel()
+			})
+			g.add(2, &actor{name: "modules", execute: group.Run, stop: func(context.Context) { cancel() }})
 
-			if err := g.Run(); err != nil {
+			if err := g.run(); err != nil {
 				return err
 			}
 
@@ -246,6 +507,149 @@ func newServeCmd(s serveIn) *cobra.Command {
 	return serveCmd
 }
 
+// portMux listens on http.addr for both the http server and the gRPC server.
+func (s serveIn) portMux(logger logging.LevelLogger) (*portMux, error) {
+	if s.Config.String("http.tls.certFile") != "" || s.Config.String("grpc.tls.certFile") != "" {
+		return nil, errSinglePortTLS
+	}
+	ln, err := net.Listen("tcp", s.Config.String("http.addr"))
+	if err != nil {
+		return nil, errors.Wrap(err, "failed start single port server")
+	}
+	logger.Infof("http and gRPC services share the port at %s", ln.Addr())
+	return newPortMux(ln, logger), nil
+}
+
+// httpMiddlewares returns the middlewares provided by modules, and the built-in
+// ones if http.builtinMiddlewares is enabled, sorted by priority.
+func (s serveIn) httpMiddlewares() []HTTPMiddleware {
+	var middlewares []HTTPMiddleware
+	if s.Config.Bool("http.builtinMiddlewares") {
+		middlewares = s.builtinHTTPMiddlewares()
+	}
+	modules := s.Container.Modules()
+	for i := range modules {
+		if p, ok := modules[i].(HTTPMiddlewareProvider); ok {
+			middlewares = append(middlewares, p.ProvideHTTPMiddleware()...)
+		}
+	}
+	sort.SliceStable(middlewares, func(i, j int) bool {
+		return middlewares[i].Priority < middlewares[j].Priority
+	})
+	return middlewares
+}
+
+// builtinHTTPMiddlewares returns the tracing, metrics and recovery middlewares
+// whose dependencies are available.
+func (s serveIn) builtinHTTPMiddlewares() []HTTPMiddleware {
+	var middlewares []HTTPMiddleware
+	if s.Tracer != nil {
+		tracer := s.Tracer
+		middlewares = append(middlewares, HTTPMiddleware{
+			Priority: PriorityTracing,
+			Middleware: func(handler http.Handler) http.Handler {
+				return nethttp.Middleware(tracer, handler, nethttp.OperationNameFunc(httpOperationName))
+			},
+		})
+	}
+	if s.HTTPMetrics != nil {
+		middlewares = append(middlewares, HTTPMiddleware{Priority: PriorityMetrics, Middleware: srvhttp.Metrics(s.HTTPMetrics)})
+	}
+	return append(middlewares, HTTPMiddleware{Priority: PriorityRecovery, Middleware: srvhttp.Recover(s.Logger)})
+}
+
+// grpcInterceptors returns the interceptors provided by modules, and the
+// built-in ones if grpc.builtinInterceptors is enabled, sorted by priority.
+func (s serveIn) grpcInterceptors() []GRPCInterceptor {
+	var interceptors []GRPCInterceptor
+	if s.Config.Bool("grpc.builtinInterceptors") {
+		interceptors = s.builtinGRPCInterceptors()
+	}
+	modules := s.Container.Modules()
+	for i := range modules {
+		if p, ok := modules[i].(GRPCInterceptorProvider); ok {
+			interceptors = append(interceptors, p.ProvideGRPCInterceptor()...)
+		}
+	}
+	sort.SliceStable(interceptors, func(i, j int) bool {
+		return interceptors[i].Priority < interceptors[j].Priority
+	})
+	return interceptors
+}
+
+// builtinGRPCInterceptors returns the tracing, metrics and recovery
+// interceptors whose dependencies are available.
+func (s serveIn) builtinGRPCInterceptors() []GRPCInterceptor {
+	var interceptors []GRPCInterceptor
+	if s.Tracer != nil {
+		interceptors = append(interceptors, GRPCInterceptor{
+			Priority: PriorityTracing,
+			Unary:    otgrpc.OpenTracingServerInterceptor(s.Tracer),
+			Stream:   otgrpc.OpenTracingStreamServerInterceptor(s.Tracer),
+		})
+	}
+	if s.GRPCMetrics != nil {
+		interceptors = append(interceptors, GRPCInterceptor{Priority: PriorityMetrics, Unary: srvgrpc.Metrics(s.GRPCMetrics)})
+	}
+	return append(interceptors, GRPCInterceptor{
+		Priority: PriorityRecovery,
+		Unary:    srvgrpc.Recover(s.Logger),
+		Stream:   srvgrpc.RecoverStream(s.Logger),
+	})
+}
+
+// httpOperationName names the spans after the route template rather than the
+// path, so that the cardinality stays low.
+func httpOperationName(request *http.Request) string {
+	if route := mux.CurrentRoute(request); route != nil {
+		if tpl, err := route.GetPathTemplate(); err == nil {
+			return "HTTP " + request.Method + " " + tpl
+		}
+	}
+	return "HTTP " + request.Method
+}
+
+func hasProvider[T any](ctn contract.Container) bool {
+	for _, m := range ctn.Modules() {
+		if _, ok := m.(T); ok {
+			return true
+		}
+	}
+	return false
+}
+
+// applyGRPCGateway mounts a grpc-gateway mux at the prefix of the router. The
+// handlers registered by modules dial the gRPC server through the in-process
+// listener.
+func applyGRPCGateway(ctx context.Context, ctn contract.Container, router *mux.Router, prefix string, inProcess *muxListener) error {
+	gateway := runtime.NewServeMux(
+		runtime.WithErrorHandler(srvhttp.GatewayErrorHandler),
+		runtime.WithRoutingErrorHandler(srvhttp.GatewayRoutingErrorHandler),
+	)
+	opts := []grpc.DialOption{
+		grpc.WithContextDialer(inProcess.dial),
+		grpc.WithTransportCredentials(insecure.NewCredentials()),
+	}
+	modules := ctn.Modules()
+	for i := range modules {
+		if p, ok := modules[i].(GRPCGatewayProvider); ok {
+			if err := p.ProvideGRPCGateway(ctx, gateway, inProcess.Addr().String(), opts); err != nil {
+				return fmt.Errorf("failed to register grpc gateway for %T: %w", modules[i], err)
+			}
+		}
+	}
+
+	if prefix == "" {
+		prefix = "/"
+	}
+	var handler http.Handler = gateway
+	if trimmed := strings.TrimSuffix(prefix, "/"); trimmed != "" {
+		handler = http.StripPrefix(trimmed, gateway)
+	}
+	router.PathPrefix(prefix).Handler(handler)
+	return nil
+}
+
 func applyRouter(ctn contract.Container, router *mux.Router) {
 	modules := ctn.Modules()
 	for i := range modules {
@@ -264,16 +668,23 @@ func applyGRPCServer(ctn contract.Container, server *grpc.Server) {
 	}
 }
 
-func applyRunGroup(ctn contract.Container, group *run.Group) {
+// applyRunGroup adds the modules to the group. Every Runnable runs under the
+// given supervisor, unless it has a restart policy of its own.
+func applyRunGroup(ctn contract.Container, group *run.Group, sup supervisor.Supervisor) {
 	modules := ctn.Modules()
 	for i := range modules {
 		if p, ok := modules[i].(RunProvider); ok {
 			p.ProvideRunGroup(group)
 		}
 		if p, ok := modules[i].(Runnable); ok {
+			sup := sup
+			sup.Name = fmt.Sprintf("%T", p)
+			if p, ok := p.(SupervisedRunnable); ok {
+				sup.Policy = p.RestartPolicy()
+			}
 			ctx, cancel := context.WithCancel(context.Background())
 			group.Add(func() error {
-				return p.Run(ctx)
+				return sup.Run(ctx, p.Run)
 			}, func(err error) {
 				cancel()
 			})
@@ -304,4 +715,7 @@ func setDefaultLifecycles(s *serveIn) {
 	if s.GRPCServerShutdown == nil {
 		s.GRPCServerShutdown = defaultLifecycles.GRPCServerShutdown
 	}
+	if s.ShutdownStart == nil {
+		s.ShutdownStart = defaultLifecycles.ShutdownStart
+	}
 }
@@ -8,19 +8,33 @@ import (
 	"net/http"
 	"os"
 	"runtime"
+	"strings"
+	"sync"
 	"sync/atomic"
 	"testing"
 	"time"
 
+	"github.com/DoNewsCode/core/config"
+	"github.com/DoNewsCode/core/container"
+	"github.com/DoNewsCode/core/contract/lifecycle"
+	"github.com/DoNewsCode/core/control/supervisor"
 	"github.com/DoNewsCode/core/cron"
 	"github.com/DoNewsCode/core/di"
 	"github.com/DoNewsCode/core/logging"
 	"github.com/DoNewsCode/core/observability"
+	"github.com/DoNewsCode/core/srvgrpc"
 	"github.com/gorilla/mux"
+	gwruntime "github.com/grpc-ecosystem/grpc-gateway/v2/runtime"
 
 	"github.com/go-kit/log"
 	"github.com/oklog/run"
+	"github.com/opentracing/opentracing-go"
 	"github.com/stretchr/testify/assert"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials/insecure"
+	healthpb "google.golang.org/grpc/health/grpc_health_v1"
+	"google.golang.org/grpc/status"
 )
 
 func TestServeIn_signalWatch(t *testing.T) {
@@ -154,3 +168,299 @@ func TestSimpleRun(t *testing.T) {
 	c.Serve(context.Background())
 	assert.Equal(t, uint32(1), m.RunCount)
 }
+
+type stuckRunModule struct{}
+
+func (s stuckRunModule) Run(ctx context.Context) error {
+	select {}
+}
+
+func TestServe_shutdownTimeout(t *testing.T) {
+	var (
+		shutdownStarted uint32
+		closed          uint32
+	)
+	c := Default(
+		WithInline("grpc.disable", true),
+		WithInline("cron.disable", true),
+		WithInline("http.addr", ":19997"),
+		WithInline("serve.shutdownTimeout", "300ms"),
+		WithInline("log.level", "none"),
+	)
+	c.Invoke(func(dispatcher lifecycle.ShutdownStart) {
+		dispatcher.On(func(ctx context.Context, payload lifecycle.ShutdownStartPayload) error {
+			atomic.StoreUint32(&shutdownStarted, 1)
+			assert.False(t, payload.Deadline.IsZero())
+			return nil
+		})
+	})
+	c.AddModule(HttpFunc(func(router *mux.Router) {
+		router.HandleFunc("/", func(writer http.ResponseWriter, request *http.Request) {
+			time.Sleep(time.Hour)
+		})
+	}))
+	c.AddModule(stuckRunModule{})
+	c.AddModule(closer(func() { atomic.StoreUint32(&closed, 1) }))
+
+	ctx, cancel := context.WithTimeout(context.Background(), 500*time.Millisecond)
+	defer cancel()
+	go http.Get("http://localhost:19997/")
+
+	start := time.Now()
+	err := c.Serve(ctx)
+	assert.NoError(t, err)
+	assert.Less(t, time.Since(start), 2*time.Second)
+	assert.Equal(t, uint32(1), atomic.LoadUint32(&shutdownStarted))
+	assert.Equal(t, uint32(1), atomic.LoadUint32(&closed))
+
+	// closers have already been called by the serve command.
+	c.AddModule(closer(func() { t.Error("closers should only be called once") }))
+	c.Shutdown()
+}
+
+type interceptorModule struct {
+	mu    sync.Mutex
+	calls []string
+}
+
+func (m *interceptorModule) record(name string) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.calls = append(m.calls, name)
+}
+
+func (m *interceptorModule) ProvideHTTPMiddleware() []HTTPMiddleware {
+	middleware := func(name string) func(handler http.Handler) http.Handler {
+		return func(handler http.Handler) http.Handler {
+			return http.HandlerFunc(func(writer http.ResponseWriter, request *http.Request) {
+				m.record(name)
+				handler.ServeHTTP(writer, request)
+			})
+		}
+	}
+	return []HTTPMiddleware{
+		{Priority: PriorityRecovery + 1, Middleware: middleware("inner")},
+		{Priority: PriorityTracing - 1, Middleware: middleware("outer")},
+	}
+}
+
+func (m *interceptorModule) ProvideGRPCInterceptor() []GRPCInterceptor {
+	interceptor := func(name string) grpc.UnaryServerInterceptor {
+		return func(ctx context.Context, req any, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (any, error) {
+			m.record(name)
+			return handler(ctx, req)
+		}
+	}
+	return []GRPCInterceptor{
+		{Priority: PriorityRecovery + 1, Unary: func(ctx context.Context, req any, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (any, error) {
+			m.record("inner")
+			panic("boom")
+		}},
+		{Priority: PriorityTracing - 1, Unary: interceptor("outer")},
+	}
+}
+
+func TestServe_middlewares(t *testing.T) {
+	c := Default(
+		WithInline("http.addr", "127.0.0.1:0"),
+		WithInline("http.builtinMiddlewares", true),
+		WithInline("grpc.addr", "127.0.0.1:0"),
+		WithInline("grpc.builtinInterceptors", true),
+		WithInline("cron.disable", true),
+		WithInline("log.level", "none"),
+	)
+	m := &interceptorModule{}
+	c.AddModule(m)
+	c.AddModule(srvgrpc.HealthCheckModule{})
+	c.AddModule(HttpFunc(func(router *mux.Router) {
+		router.HandleFunc("/panic", func(writer http.ResponseWriter, request *http.Request) {
+			panic("boom")
+		})
+	}))
+
+	var (
+		httpAddr = make(chan string, 1)
+		grpcAddr = make(chan string, 1)
+	)
+	c.Invoke(func(httpStart lifecycle.HTTPServerStart, grpcStart lifecycle.GRPCServerStart) {
+		httpStart.On(func(ctx context.Context, payload lifecycle.HTTPServerStartPayload) error {
+			httpAddr <- payload.Listener.Addr().String()
+			return nil
+		})
+		grpcStart.On(func(ctx context.Context, payload lifecycle.GRPCServerStartPayload) error {
+			grpcAddr <- payload.Listener.Addr().String()
+			return nil
+		})
+	})
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go c.Serve(ctx)
+
+	t.Run("http", func(t *testing.T) {
+		m.calls = nil
+		resp, err := http.Get("http://" + <-httpAddr + "/panic")
+		if err != nil {
+			t.Fatal(err)
+		}
+		resp.Body.Close()
+		assert.Equal(t, http.StatusInternalServerError, resp.StatusCode)
+		assert.Equal(t, []string{"outer", "inner"}, m.calls)
+	})
+
+	t.Run("grpc", func(t *testing.T) {
+		m.calls = nil
+		conn, err := grpc.Dial(<-grpcAddr, grpc.WithTransportCredentials(insecure.NewCredentials()))
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer conn.Close()
+		_, err = healthpb.NewHealthClient(conn).Check(ctx, &healthpb.HealthCheckRequest{})
+		assert.Equal(t, codes.Internal, status.Code(err))
+		assert.Equal(t, []string{"outer", "inner"}, m.calls)
+	})
+}
+
+type gatewayModule struct{}
+
+func (g gatewayModule) ProvideGRPCGateway(ctx context.Context, mux *gwruntime.ServeMux, endpoint string, opts []grpc.DialOption) error {
+	conn, err := grpc.DialContext(ctx, endpoint, opts...)
+	if err != nil {
+		return err
+	}
+	go func() {
+		<-ctx.Done()
+		conn.Close()
+	}()
+	client := healthpb.NewHealthClient(conn)
+	return mux.HandlePath(http.MethodGet, "/health/{service}", func(w http.ResponseWriter, r *http.Request, pathParams map[string]string) {
+		service := pathParams["service"]
+		if service == "default" {
+			service = ""
+		}
+		resp, err := client.Check(r.Context(), &healthpb.HealthCheckRequest{Service: service})
+		if err != nil {
+			gwruntime.HTTPError(r.Context(), mux, &gwruntime.JSONPb{}, w, r, err)
+			return
+		}
+		w.Write([]byte(resp.Status.String()))
+	})
+}
+
+func TestServe_grpcGateway(t *testing.T) {
+	c := Default(
+		WithInline("http.addr", "127.0.0.1:0"),
+		WithInline("http.gateway.prefix", "/api/"),
+		WithInline("grpc.addr", "127.0.0.1:0"),
+		WithInline("cron.disable", true),
+		WithInline("log.level", "none"),
+	)
+	c.AddModule(srvgrpc.HealthCheckModule{})
+	c.AddModule(gatewayModule{})
+
+	httpAddr := make(chan string, 1)
+	c.Invoke(func(httpStart lifecycle.HTTPServerStart) {
+		httpStart.On(func(ctx context.Context, payload lifecycle.HTTPServerStartPayload) error {
+			httpAddr <- payload.Listener.Addr().String()
+			return nil
+		})
+	})
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go c.Serve(ctx)
+	addr := <-httpAddr
+
+	cases := []struct {
+		name string
+		path string
+		code int
+		body string
+	}{
+		{"ok", "/api/health/default", http.StatusOK, "SERVING"},
+		{"grpc error", "/api/health/foo", http.StatusNotFound, `{"code":5,"message":"unknown service"}`},
+		{"routing error", "/api/foo", http.StatusNotFound, `{"code":5,"message":"Not Found"}`},
+	}
+	for _, cc := range cases {
+		t.Run(cc.name, func(t *testing.T) {
+			resp, err := http.Get("http://" + addr + cc.path)
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer resp.Body.Close()
+			body, _ := ioutil.ReadAll(resp.Body)
+			assert.Equal(t, cc.code, resp.StatusCode)
+			assert.Equal(t, cc.body, strings.TrimSpace(string(body)))
+		})
+	}
+}
+
+type flakyRunModule struct {
+	failures int
+	calls    int
+}
+
+func (f *flakyRunModule) Run(ctx context.Context) error {
+	f.calls++
+	if f.calls <= f.failures {
+		return errors.New("flaky")
+	}
+	return nil
+}
+
+type supervisedRunModule struct {
+	flakyRunModule
+}
+
+func (s *supervisedRunModule) RestartPolicy() supervisor.Policy {
+	return supervisor.Policy{MaxAttempts: 5, Backoff: config.Duration{Duration: time.Millisecond}}
+}
+
+func TestServe_restart(t *testing.T) {
+	newC := func() *C {
+		return Default(
+			WithInline("grpc.disable", true),
+			WithInline("http.disable", true),
+			WithInline("cron.disable", true),
+			WithInline("serve.restart.maxAttempts", 1),
+			WithInline("serve.restart.backoff", "1ms"),
+			WithInline("log.level", "none"),
+		)
+	}
+
+	t.Run("policy from config", func(t *testing.T) {
+		c := newC()
+		m := &flakyRunModule{failures: 2}
+		c.AddModule(m)
+		err := c.Serve(context.Background())
+		assert.ErrorContains(t, err, "gave up after 1 restarts")
+		assert.Equal(t, 2, m.calls)
+	})
+
+	t.Run("policy from module", func(t *testing.T) {
+		c := newC()
+		m := &supervisedRunModule{flakyRunModule{failures: 2}}
+		c.AddModule(m)
+		assert.NoError(t, c.Serve(context.Background()))
+		assert.Equal(t, 3, m.calls)
+	})
+}
+
+func TestServeIn_builtinMiddlewares(t *testing.T) {
+	in := serveIn{
+		Config:    config.WithAccessor(config.MapAdapter{}),
+		Logger:    log.NewNopLogger(),
+		Container: &container.Container{},
+		Tracer:    opentracing.NoopTracer{},
+	}
+	assert.Empty(t, in.httpMiddlewares())
+	assert.Empty(t, in.grpcInterceptors())
+
+	in.Config = config.WithAccessor(config.MapAdapter{
+		"http": map[string]any{"builtinMiddlewares": true},
+		"grpc": map[string]any{"builtinInterceptors": true},
+	})
+	// tracing and recovery, but no metrics without the histograms
+	assert.Len(t, in.httpMiddlewares(), 2)
+	assert.Len(t, in.grpcInterceptors(), 2)
+}
@@ -0,0 +1,122 @@
+package core
+
+import (
+	"context"
+	"strings"
+	"time"
+
+	"github.com/DoNewsCode/core/logging"
+)
+
+const defaultShutdownTimeout = 30 * time.Second
+
+// actor is a long-running member of the serve command, such as the http server
+// or the cron runner.
+type actor struct {
+	name    string
+	execute func() error
+	// stop asks the actor to return from execute. The context expires at the
+	// deadline of the shutdown phase the actor belongs to. Once it expires the
+	// actor should stop by force.
+	stop func(ctx context.Context)
+	done chan struct{}
+}
+
+// shutdownPhase is one step of the graceful shutdown. Phases are executed in
+// order. All actors within a phase are stopped concurrently.
+type shutdownPhase struct {
+	name   string
+	actors []*actor
+	// run is called at the beginning of the phase, before the actors are
+	// stopped. The deadline is the one of the whole shutdown. Optional.
+	run func(ctx context.Context, deadline time.Time)
+}
+
+// phasedGroup runs actors until the first one returns, and then shuts down the
+// rest of them phase by phase. The shutdown timeout is the budget of the whole
+// shutdown. Each phase gets an equal share of the budget left when it starts,
+// so that time saved by a fast phase is given to the slower ones.
+type phasedGroup struct {
+	timeout time.Duration
+	logger  logging.LevelLogger
+	phases  []shutdownPhase
+}
+
+func (g *phasedGroup) add(phase int, a *actor) {
+	a.done = make(chan struct{})
+	g.phases[phase].actors = append(g.phases[phase].actors, a)
+}
+
+// run starts every actor and blocks until the shutdown completes. It returns
+// the error of the first actor that returned.
+func (g *phasedGroup) run() error {
+	var count int
+	for i := range g.phases {
+		count += len(g.phases[i].actors)
+	}
+	if count == 0 {
+		return nil
+	}
+
+	errs := make(chan error, count)
+	for i := range g.phases {
+		for _, a := range g.phases[i].actors {
+			go func(a *actor) {
+				defer close(a.done)
+				errs <- a.execute()
+			}(a)
+		}
+	}
+
+	err := <-errs
+	g.shutdown()
+	return err
+}
+
+func (g *phasedGroup) shutdown() {
+	deadline := time.Now().Add(g.timeout)
+	g.logger.Infof("shutting down, timeout %s", g.timeout)
+
+	for i, phase := range g.phases {
+		share := time.Until(deadline) / time.Duration(len(g.phases)-i)
+		ctx, cancel := context.WithTimeout(context.Background(), share)
+		if phase.run != nil {
+			phase.run(ctx, deadline)
+		}
+		g.stop(ctx, phase)
+		cancel()
+	}
+}
+
+func (g *phasedGroup) stop(ctx context.Context, phase shutdownPhase) {
+	for _, a := range phase.actors {
+		go a.stop(ctx)
+	}
+	if pending := phase.pending(); len(pending) > 0 {
+		g.logger.Infof("shutdown phase %s: waiting on %s", phase.name, strings.Join(pending, ", "))
+	}
+	for _, a := range phase.actors {
+		select {
+		case <-a.done:
+		case <-ctx.Done():
+			g.logger.Warnf(
+				"shutdown phase %s: deadline exceeded, force stopped %s",
+				phase.name,
+				strings.Join(phase.pending(), ", "),
+			)
+			return
+		}
+	}
+}
+
+func (p shutdownPhase) pending() []string {
+	var names []string
+	for _, a := range p.actors {
+		select {
+		case <-a.done:
+		default:
+			names = append(names, a.name)
+		}
+	}
+	return names
+}
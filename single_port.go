@@ -0,0 +1,255 @@
+package core
+
+import (
+	"bytes"
+	"context"
+	"encoding/binary"
+	"errors"
+	"io"
+	"net"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/DoNewsCode/core/logging"
+
+	"golang.org/x/net/http2"
+	"golang.org/x/net/http2/hpack"
+)
+
+// sniffTimeout bounds the time a new connection may take to reveal its protocol.
+const sniffTimeout = 10 * time.Second
+
+var errSinglePortTLS = errors.New("tls is not supported in single port mode, terminate tls in front of the application instead")
+
+// portMux splits the connections of one listener between the http server and
+// the gRPC server, in the spirit of github.com/soheilhy/cmux. A connection
+// starting with the HTTP/2 client preface is routed by the content-type of its
+// first request: application/grpc goes to the gRPC server, anything else goes
+// to the http server as h2c. All other connections go to the http server.
+type portMux struct {
+	root   net.Listener
+	logger logging.LevelLogger
+	http   *muxListener
+	grpc   *muxListener
+	once   sync.Once
+	closed chan struct{}
+}
+
+func newPortMux(root net.Listener, logger logging.LevelLogger) *portMux {
+	return &portMux{
+		root:   root,
+		logger: logger,
+		http:   newMuxListener(root.Addr()),
+		grpc:   newMuxListener(root.Addr()),
+		closed: make(chan struct{}),
+	}
+}
+
+// serve accepts connections until close is called.
+func (m *portMux) serve() error {
+	for {
+		conn, err := m.root.Accept()
+		if err != nil {
+			select {
+			case <-m.closed:
+				return nil
+			default:
+				return err
+			}
+		}
+		go m.dispatch(conn)
+	}
+}
+
+// close stops accepting new connections. The listeners of the servers are
+// closed by the servers themselves.
+func (m *portMux) close() {
+	m.once.Do(func() {
+		close(m.closed)
+		_ = m.root.Close()
+	})
+}
+
+func (m *portMux) dispatch(conn net.Conn) {
+	_ = conn.SetReadDeadline(time.Now().Add(sniffTimeout))
+	isGRPC, sniffed, err := sniff(conn)
+	if err != nil {
+		m.logger.Debugf("failed to sniff the protocol of %s: %s", conn.RemoteAddr(), err)
+		_ = conn.Close()
+		return
+	}
+	_ = conn.SetReadDeadline(time.Time{})
+
+	target := m.http
+	if isGRPC {
+		target = m.grpc
+	}
+	reader := io.MultiReader(bytes.NewReader(sniffed), conn)
+	if bytes.HasPrefix(sniffed, []byte(http2.ClientPreface)) {
+		reader = io.MultiReader(
+			bytes.NewReader(sniffed[:len(http2.ClientPreface)]),
+			&settingsAckFilter{r: io.MultiReader(bytes.NewReader(sniffed[len(http2.ClientPreface):]), conn)},
+		)
+	}
+	target.deliver(&sniffedConn{Conn: conn, r: reader})
+}
+
+// sniff reads from the connection until the protocol is known. It returns the
+// bytes consumed along the way.
+func sniff(conn net.Conn) (isGRPC bool, sniffed []byte, err error) {
+	var buf bytes.Buffer
+	r := io.TeeReader(conn, &buf)
+
+	preface := []byte(http2.ClientPreface)
+	b := make([]byte, len(preface))
+	for n := 0; n < len(preface); {
+		read, err := r.Read(b[n:])
+		n += read
+		if !bytes.Equal(b[:n], preface[:n]) {
+			return false, buf.Bytes(), nil
+		}
+		if err != nil {
+			return false, nil, err
+		}
+	}
+
+	// Some gRPC clients wait for the server preface before sending any
+	// request. Send an empty SETTINGS frame on behalf of the server as soon as
+	// the client SETTINGS arrives, which always precedes the first request.
+	// The acknowledgement is dropped by settingsAckFilter later.
+	framer := http2.NewFramer(conn, r)
+	framer.ReadMetaHeaders = hpack.NewDecoder(4096, nil)
+	var settingsSent bool
+	for {
+		frame, err := framer.ReadFrame()
+		if err != nil {
+			return false, nil, err
+		}
+		switch f := frame.(type) {
+		case *http2.SettingsFrame:
+			if !f.IsAck() && !settingsSent {
+				if err := framer.WriteSettings(); err != nil {
+					return false, nil, err
+				}
+				settingsSent = true
+			}
+		case *http2.MetaHeadersFrame:
+			var contentType string
+			for _, field := range f.RegularFields() {
+				if field.Name == "content-type" {
+					contentType = field.Value
+				}
+			}
+			return strings.HasPrefix(contentType, "application/grpc"), buf.Bytes(), nil
+		}
+	}
+}
+
+// settingsAckFilter removes the first SETTINGS acknowledgement from an HTTP/2
+// client stream. The acknowledgement answers the SETTINGS frame sent by sniff,
+// which the real server knows nothing about.
+type settingsAckFilter struct {
+	r       io.Reader
+	done    bool
+	pending []byte
+}
+
+func (f *settingsAckFilter) Read(p []byte) (int, error) {
+	for !f.done && len(f.pending) == 0 {
+		header := make([]byte, 9)
+		if _, err := io.ReadFull(f.r, header); err != nil {
+			return 0, err
+		}
+		length := int(binary.BigEndian.Uint32(append([]byte{0}, header[:3]...)))
+		payload := make([]byte, length)
+		if _, err := io.ReadFull(f.r, payload); err != nil {
+			return 0, err
+		}
+		if http2.FrameType(header[3]) == http2.FrameSettings && http2.Flags(header[4]).Has(http2.FlagSettingsAck) {
+			f.done = true
+			continue
+		}
+		f.pending = append(header, payload...)
+	}
+	if len(f.pending) > 0 {
+		n := copy(p, f.pending)
+		f.pending = f.pending[n:]
+		return n, nil
+	}
+	return f.r.Read(p)
+}
+
+// sniffedConn replays the sniffed bytes before reading from the connection.
+type sniffedConn struct {
+	net.Conn
+	r io.Reader
+}
+
+func (c *sniffedConn) Read(p []byte) (int, error) {
+	return c.r.Read(p)
+}
+
+// muxListener is a net.Listener fed by portMux, or by dial for the
+// connections made from within the process.
+type muxListener struct {
+	addr   net.Addr
+	conns  chan net.Conn
+	once   sync.Once
+	closed chan struct{}
+}
+
+func newMuxListener(addr net.Addr) *muxListener {
+	return &muxListener{addr: addr, conns: make(chan net.Conn), closed: make(chan struct{})}
+}
+
+// dial connects to the listener through an in-memory pipe.
+func (l *muxListener) dial(ctx context.Context, _ string) (net.Conn, error) {
+	server, client := net.Pipe()
+	select {
+	case l.conns <- server:
+		return client, nil
+	case <-l.closed:
+		return nil, net.ErrClosed
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	}
+}
+
+func (l *muxListener) deliver(conn net.Conn) {
+	select {
+	case l.conns <- conn:
+	case <-l.closed:
+		_ = conn.Close()
+	}
+}
+
+// Accept implements net.Listener.
+func (l *muxListener) Accept() (net.Conn, error) {
+	select {
+	case conn := <-l.conns:
+		return conn, nil
+	case <-l.closed:
+		return nil, net.ErrClosed
+	}
+}
+
+// Close implements net.Listener.
+func (l *muxListener) Close() error {
+	l.once.Do(func() { close(l.closed) })
+	return nil
+}
+
+// Addr implements net.Listener.
+func (l *muxListener) Addr() net.Addr {
+	return l.addr
+}
+
+// inProcessAddr is the address of the in-process listener.
+type inProcessAddr struct{}
+
+// Network implements net.Addr.
+func (inProcessAddr) Network() string { return "pipe" }
+
+// String implements net.Addr.
+func (inProcessAddr) String() string { return "in-process" }
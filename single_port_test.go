@@ -0,0 +1,116 @@
+package core
+
+import (
+	"context"
+	"crypto/tls"
+	"io"
+	"net"
+	"net/http"
+	"testing"
+
+	"github.com/DoNewsCode/core/contract/lifecycle"
+	"github.com/DoNewsCode/core/srvgrpc"
+
+	"github.com/gorilla/mux"
+	"github.com/stretchr/testify/assert"
+	"golang.org/x/net/http2"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/credentials/insecure"
+	healthpb "google.golang.org/grpc/health/grpc_health_v1"
+)
+
+func TestServe_singlePort(t *testing.T) {
+	c := New(
+		WithInline("serve.singlePort", true),
+		WithInline("http.addr", "127.0.0.1:0"),
+		WithInline("cron.disable", true),
+		WithInline("log.level", "none"),
+	)
+	c.ProvideEssentials()
+	c.AddModule(srvgrpc.HealthCheckModule{})
+	c.AddModule(HttpFunc(func(router *mux.Router) {
+		router.HandleFunc("/", func(writer http.ResponseWriter, request *http.Request) {
+			writer.Write([]byte(request.Proto))
+		})
+	}))
+
+	var (
+		httpAddr = make(chan string, 1)
+		grpcAddr = make(chan string, 1)
+	)
+	c.Invoke(func(httpStart lifecycle.HTTPServerStart, grpcStart lifecycle.GRPCServerStart) {
+		httpStart.On(func(ctx context.Context, payload lifecycle.HTTPServerStartPayload) error {
+			httpAddr <- payload.Listener.Addr().String()
+			return nil
+		})
+		grpcStart.On(func(ctx context.Context, payload lifecycle.GRPCServerStartPayload) error {
+			grpcAddr <- payload.Listener.Addr().String()
+			return nil
+		})
+	})
+
+	ctx, cancel := context.WithCancel(context.Background())
+	served := make(chan error)
+	go func() { served <- c.Serve(ctx) }()
+
+	addr := <-httpAddr
+	assert.Equal(t, addr, <-grpcAddr)
+
+	get := func(t *testing.T, client *http.Client) string {
+		resp, err := client.Get("http://" + addr + "/")
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer resp.Body.Close()
+		body, err := io.ReadAll(resp.Body)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return string(body)
+	}
+
+	t.Run("http/1.1", func(t *testing.T) {
+		assert.Equal(t, "HTTP/1.1", get(t, http.DefaultClient))
+	})
+
+	t.Run("h2c", func(t *testing.T) {
+		client := &http.Client{Transport: &http2.Transport{
+			AllowHTTP: true,
+			DialTLS: func(network, addr string, cfg *tls.Config) (net.Conn, error) {
+				return net.Dial(network, addr)
+			},
+		}}
+		assert.Equal(t, "HTTP/2.0", get(t, client))
+		assert.Equal(t, "HTTP/2.0", get(t, client))
+	})
+
+	t.Run("grpc", func(t *testing.T) {
+		conn, err := grpc.Dial(addr, grpc.WithTransportCredentials(insecure.NewCredentials()))
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer conn.Close()
+		for i := 0; i < 2; i++ {
+			resp, err := healthpb.NewHealthClient(conn).Check(ctx, &healthpb.HealthCheckRequest{})
+			if err != nil {
+				t.Fatal(err)
+			}
+			assert.Equal(t, healthpb.HealthCheckResponse_SERVING, resp.Status)
+		}
+	})
+
+	cancel()
+	assert.NoError(t, <-served)
+}
+
+func TestServe_singlePortTLS(t *testing.T) {
+	c := New(
+		WithInline("serve.singlePort", true),
+		WithInline("http.addr", "127.0.0.1:0"),
+		WithInline("http.tls.certFile", "cert.pem"),
+		WithInline("cron.disable", true),
+		WithInline("log.level", "none"),
+	)
+	c.ProvideEssentials()
+	assert.ErrorIs(t, c.Serve(context.Background()), errSinglePortTLS)
+}
@@ -1,17 +1,33 @@
 package srvgrpc
 
 import (
+	"context"
+
+	"github.com/DoNewsCode/core/contract/lifecycle"
+	"github.com/DoNewsCode/core/di"
+
 	"google.golang.org/grpc"
 	"google.golang.org/grpc/health"
 	healthpb "google.golang.org/grpc/health/grpc_health_v1"
 )
 
-// HealthCheckModule defines a grpc provider for container.Container.
-type HealthCheckModule struct{}
+// HealthCheckModule defines a grpc provider for container.Container. The
+// serving status turns into NOT_SERVING as soon as the shutdown begins.
+type HealthCheckModule struct {
+	di.In
+
+	ShutdownStart lifecycle.ShutdownStart `optional:"true"`
+}
 
 // ProvideGRPC implements container.GRPCProvider
 func (h HealthCheckModule) ProvideGRPC(server *grpc.Server) {
 	srv := health.NewServer()
 	srv.SetServingStatus("", healthpb.HealthCheckResponse_SERVING)
 	healthpb.RegisterHealthServer(server, srv)
+	if h.ShutdownStart != nil {
+		h.ShutdownStart.On(func(ctx context.Context, payload lifecycle.ShutdownStartPayload) error {
+			srv.Shutdown()
+			return nil
+		})
+	}
 }
@@ -0,0 +1,47 @@
+package srvgrpc
+
+import (
+	"context"
+	"fmt"
+	"runtime/debug"
+
+	"github.com/go-kit/log"
+	"github.com/go-kit/log/level"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// Recover is a unary interceptor for grpc package. It recovers the panics in
+// the handler, logs them along with the stack, and returns codes.Internal.
+func Recover(logger log.Logger) grpc.UnaryServerInterceptor {
+	return func(ctx context.Context, req any, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (resp any, err error) {
+		defer func() {
+			if r := recover(); r != nil {
+				err = recovered(logger, info.FullMethod, r)
+			}
+		}()
+		return handler(ctx, req)
+	}
+}
+
+// RecoverStream is the stream interceptor counterpart of Recover.
+func RecoverStream(logger log.Logger) grpc.StreamServerInterceptor {
+	return func(srv any, ss grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) (err error) {
+		defer func() {
+			if r := recover(); r != nil {
+				err = recovered(logger, info.FullMethod, r)
+			}
+		}()
+		return handler(srv, ss)
+	}
+}
+
+func recovered(logger log.Logger, method string, r any) error {
+	level.Error(logger).Log(
+		"msg", fmt.Sprintf("panic recovered: %v", r),
+		"method", method,
+		"stack", string(debug.Stack()),
+	)
+	return status.Error(codes.Internal, "internal error")
+}
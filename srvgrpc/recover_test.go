@@ -0,0 +1,26 @@
+package srvgrpc
+
+import (
+	"context"
+	"testing"
+
+	"github.com/go-kit/log"
+	"github.com/stretchr/testify/assert"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+func TestRecover(t *testing.T) {
+	info := &grpc.UnaryServerInfo{FullMethod: "/foo/bar"}
+	_, err := Recover(log.NewNopLogger())(context.Background(), nil, info, func(ctx context.Context, req any) (any, error) {
+		panic("boom")
+	})
+	assert.Equal(t, codes.Internal, status.Code(err))
+
+	streamInfo := &grpc.StreamServerInfo{FullMethod: "/foo/baz"}
+	err = RecoverStream(log.NewNopLogger())(nil, nil, streamInfo, func(srv any, stream grpc.ServerStream) error {
+		panic("boom")
+	})
+	assert.Equal(t, codes.Internal, status.Code(err))
+}
@@ -0,0 +1,37 @@
+package srvhttp
+
+import (
+	"context"
+	"net/http"
+
+	"github.com/DoNewsCode/core/unierr"
+
+	"github.com/grpc-ecosystem/grpc-gateway/v2/runtime"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// GatewayErrorHandler is a runtime.ErrorHandlerFunc for grpc-gateway. It
+// translates the gRPC status to unierr.Error, so that the REST responses share
+// the JSON shape of ResponseEncoder.
+func GatewayErrorHandler(ctx context.Context, mux *runtime.ServeMux, marshaler runtime.Marshaler, writer http.ResponseWriter, request *http.Request, err error) {
+	NewResponseEncoder(writer).EncodeError(unierr.FromStatus(status.Convert(err)))
+}
+
+// GatewayRoutingErrorHandler is a runtime.RoutingErrorHandlerFunc for
+// grpc-gateway. It keeps the http status of the routing error, and responds in
+// the JSON shape of ResponseEncoder.
+func GatewayRoutingErrorHandler(ctx context.Context, mux *runtime.ServeMux, marshaler runtime.Marshaler, writer http.ResponseWriter, request *http.Request, httpStatus int) {
+	code := codes.Internal
+	switch httpStatus {
+	case http.StatusBadRequest:
+		code = codes.InvalidArgument
+	case http.StatusNotFound:
+		code = codes.NotFound
+	case http.StatusMethodNotAllowed:
+		code = codes.Unimplemented
+	}
+	err := unierr.New(code, http.StatusText(httpStatus))
+	err.HttpStatusCodeFunc = func(codes.Code) int { return httpStatus }
+	NewResponseEncoder(writer).EncodeError(err)
+}
@@ -0,0 +1,42 @@
+package srvhttp
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/DoNewsCode/core/unierr"
+
+	"github.com/grpc-ecosystem/grpc-gateway/v2/runtime"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestGatewayErrorHandler(t *testing.T) {
+	mux := runtime.NewServeMux(
+		runtime.WithErrorHandler(GatewayErrorHandler),
+		runtime.WithRoutingErrorHandler(GatewayRoutingErrorHandler),
+	)
+	mux.HandlePath(http.MethodGet, "/foo", func(w http.ResponseWriter, r *http.Request, pathParams map[string]string) {
+		runtime.HTTPError(r.Context(), mux, &runtime.JSONPb{}, w, r, unierr.NotFoundErr(nil, "no foo"))
+	})
+
+	cases := []struct {
+		name   string
+		method string
+		path   string
+		code   int
+		body   string
+	}{
+		{"error", http.MethodGet, "/foo", http.StatusNotFound, `{"code":5,"message":"no foo"}`},
+		{"not found", http.MethodGet, "/bar", http.StatusNotFound, `{"code":5,"message":"Not Found"}`},
+		{"method not allowed", http.MethodPost, "/foo", http.StatusMethodNotAllowed, `{"code":12,"message":"Method Not Allowed"}`},
+	}
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			recorder := httptest.NewRecorder()
+			mux.ServeHTTP(recorder, httptest.NewRequest(c.method, c.path, nil))
+			assert.Equal(t, c.code, recorder.Code)
+			assert.JSONEq(t, c.body, recorder.Body.String())
+		})
+	}
+}
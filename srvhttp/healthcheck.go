@@ -1,18 +1,102 @@
 package srvhttp
 
 import (
+	"context"
+	"errors"
+	"sync"
+	"time"
+
+	"github.com/DoNewsCode/core/contract"
+	"github.com/DoNewsCode/core/contract/lifecycle"
+	"github.com/DoNewsCode/core/di"
+	"github.com/DoNewsCode/core/health"
+
 	"github.com/gorilla/mux"
 	"github.com/heptiolabs/healthcheck"
 )
 
+const healthCheckTimeout = time.Second
+
 // HealthCheckModule defines a http provider for container.Container.
-// It uses github.com/heptiolabs/healthcheck underneath. It doesn't do much out of box other than providing liveness
-// check at ``/live`` and readiness check at ``/ready``. End user should add health checking functionality by themself,
-// e.g. probe if database connection pool has exhausted at readiness check.
-type HealthCheckModule struct{}
+// It uses github.com/heptiolabs/healthcheck underneath. It provides liveness
+// check at ``/live`` and readiness check at ``/ready``.
+//
+// The readiness check fails until the servers have started, and fails again as
+// soon as the shutdown begins. It also runs every health.Checker in the "health"
+// group, such as the ones contributed by otgorm and otredis. End user can add
+// their own checks by providing a health.Checker to that group.
+//
+// The fields are injected when the module is added by core.AddModule. The zero
+// value only serves the empty checks.
+type HealthCheckModule struct {
+	di.In
+
+	Conf            contract.ConfigAccessor   `optional:"true"`
+	Checkers        []health.Checker          `group:"health"`
+	HTTPServerStart lifecycle.HTTPServerStart `optional:"true"`
+	GRPCServerStart lifecycle.GRPCServerStart `optional:"true"`
+	ShutdownStart   lifecycle.ShutdownStart   `optional:"true"`
+}
 
 // ProvideHTTP implements container.HTTPProvider
 func (h HealthCheckModule) ProvideHTTP(router *mux.Router) {
-	router.PathPrefix("/live").Handler(healthcheck.NewHandler())
-	router.PathPrefix("/ready").Handler(healthcheck.NewHandler())
+	handler := healthcheck.NewHandler()
+	handler.AddReadinessCheck("serve", h.serveCheck())
+	for _, checker := range h.Checkers {
+		check := checker.Check
+		handler.AddReadinessCheck(checker.Name, func() error {
+			ctx, cancel := context.WithTimeout(context.Background(), healthCheckTimeout)
+			defer cancel()
+			return check(ctx)
+		})
+	}
+	router.PathPrefix("/live").HandlerFunc(handler.LiveEndpoint)
+	router.PathPrefix("/ready").HandlerFunc(handler.ReadyEndpoint)
+}
+
+// serveCheck tracks the lifecycle of the serve command.
+func (h HealthCheckModule) serveCheck() healthcheck.Check {
+	var (
+		mu           sync.Mutex
+		httpStarted  = h.HTTPServerStart == nil
+		grpcStarted  = h.GRPCServerStart == nil || h.Conf == nil || h.Conf.Bool("grpc.disable")
+		shuttingDown bool
+	)
+	if h.HTTPServerStart != nil {
+		h.HTTPServerStart.On(func(ctx context.Context, payload lifecycle.HTTPServerStartPayload) error {
+			mu.Lock()
+			defer mu.Unlock()
+			httpStarted = true
+			return nil
+		})
+	}
+	if h.GRPCServerStart != nil {
+		h.GRPCServerStart.On(func(ctx context.Context, payload lifecycle.GRPCServerStartPayload) error {
+			mu.Lock()
+			defer mu.Unlock()
+			grpcStarted = true
+			return nil
+		})
+	}
+	if h.ShutdownStart != nil {
+		h.ShutdownStart.On(func(ctx context.Context, payload lifecycle.ShutdownStartPayload) error {
+			mu.Lock()
+			defer mu.Unlock()
+			shuttingDown = true
+			return nil
+		})
+	}
+	return func() error {
+		mu.Lock()
+		defer mu.Unlock()
+		switch {
+		case shuttingDown:
+			return errors.New("shutting down")
+		case !httpStarted:
+			return errors.New("http server not started")
+		case !grpcStarted:
+			return errors.New("grpc server not started")
+		}
+		return nil
+	}
 }
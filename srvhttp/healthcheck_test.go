@@ -0,0 +1,76 @@
+package srvhttp
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/DoNewsCode/core/config"
+	"github.com/DoNewsCode/core/contract/lifecycle"
+	"github.com/DoNewsCode/core/events"
+	"github.com/DoNewsCode/core/health"
+
+	"github.com/gorilla/mux"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestHealthCheckModule(t *testing.T) {
+	var (
+		healthy       = true
+		httpStart     = &events.Event[lifecycle.HTTPServerStartPayload]{}
+		grpcStart     = &events.Event[lifecycle.GRPCServerStartPayload]{}
+		shutdownStart = &events.Event[lifecycle.ShutdownStartPayload]{}
+		conf, _       = config.NewConfig()
+	)
+	router := mux.NewRouter()
+	HealthCheckModule{
+		Conf: conf,
+		Checkers: []health.Checker{{Name: "foo", Check: func(ctx context.Context) error {
+			if !healthy {
+				return errors.New("unhealthy")
+			}
+			return nil
+		}}},
+		HTTPServerStart: httpStart,
+		GRPCServerStart: grpcStart,
+		ShutdownStart:   shutdownStart,
+	}.ProvideHTTP(router)
+
+	code := func(path string) int {
+		req := httptest.NewRequest("GET", path, nil)
+		rr := httptest.NewRecorder()
+		router.ServeHTTP(rr, req)
+		return rr.Code
+	}
+
+	assert.Equal(t, http.StatusOK, code("/live"))
+	assert.Equal(t, http.StatusServiceUnavailable, code("/ready"))
+
+	httpStart.Fire(context.Background(), lifecycle.HTTPServerStartPayload{})
+	assert.Equal(t, http.StatusServiceUnavailable, code("/ready"))
+
+	grpcStart.Fire(context.Background(), lifecycle.GRPCServerStartPayload{})
+	assert.Equal(t, http.StatusOK, code("/ready"))
+
+	healthy = false
+	assert.Equal(t, http.StatusServiceUnavailable, code("/ready"))
+	assert.Equal(t, http.StatusOK, code("/live"))
+
+	healthy = true
+	shutdownStart.Fire(context.Background(), lifecycle.ShutdownStartPayload{})
+	assert.Equal(t, http.StatusServiceUnavailable, code("/ready"))
+}
+
+func TestHealthCheckModule_zero(t *testing.T) {
+	router := mux.NewRouter()
+	HealthCheckModule{}.ProvideHTTP(router)
+
+	for _, path := range []string{"/live", "/ready"} {
+		req := httptest.NewRequest("GET", path, nil)
+		rr := httptest.NewRecorder()
+		router.ServeHTTP(rr, req)
+		assert.Equal(t, http.StatusOK, rr.Code)
+	}
+}
@@ -0,0 +1,37 @@
+package srvhttp
+
+import (
+	"fmt"
+	"net/http"
+	"runtime/debug"
+
+	"github.com/DoNewsCode/core/unierr"
+
+	"github.com/go-kit/log"
+	"github.com/go-kit/log/level"
+	"google.golang.org/grpc/codes"
+)
+
+// Recover is a middleware for standard library http package. It recovers the
+// panics in the handler, logs them along with the stack, and responds with an
+// internal error in the format of ResponseEncoder.
+func Recover(logger log.Logger) func(handler http.Handler) http.Handler {
+	return func(handler http.Handler) http.Handler {
+		return http.HandlerFunc(func(writer http.ResponseWriter, request *http.Request) {
+			defer func() {
+				if r := recover(); r != nil {
+					if r == http.ErrAbortHandler {
+						panic(r)
+					}
+					level.Error(logger).Log(
+						"msg", fmt.Sprintf("panic recovered: %v", r),
+						"path", request.URL.Path,
+						"stack", string(debug.Stack()),
+					)
+					NewResponseEncoder(writer).EncodeError(unierr.New(codes.Internal, "internal error"))
+				}
+			}()
+			handler.ServeHTTP(writer, request)
+		})
+	}
+}
@@ -0,0 +1,20 @@
+package srvhttp
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/go-kit/log"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRecover(t *testing.T) {
+	h := Recover(log.NewNopLogger())(http.HandlerFunc(func(writer http.ResponseWriter, request *http.Request) {
+		panic("boom")
+	}))
+	recorder := httptest.NewRecorder()
+	h.ServeHTTP(recorder, httptest.NewRequest(http.MethodGet, "/", nil))
+	assert.Equal(t, http.StatusInternalServerError, recorder.Code)
+	assert.JSONEq(t, `{"code":13,"message":"internal error"}`, recorder.Body.String())
+}
@@ -0,0 +1,156 @@
+package core
+
+import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"fmt"
+	"os"
+	"sync/atomic"
+	"time"
+
+	"github.com/DoNewsCode/core/config/watcher"
+	"github.com/DoNewsCode/core/logging"
+)
+
+const certReloadDelay = 100 * time.Millisecond
+
+// tlsConfig is the tls section of the http and grpc configuration. TLS is
+// enabled when the certificate file is set. Mutual TLS is enabled when the
+// client CA file is set.
+type tlsConfig struct {
+	CertFile          string `json:"certFile" yaml:"certFile"`
+	KeyFile           string `json:"keyFile" yaml:"keyFile"`
+	ClientCAFile      string `json:"clientCAFile" yaml:"clientCAFile"`
+	RequireClientCert bool   `json:"requireClientCert" yaml:"requireClientCert"`
+}
+
+func (t tlsConfig) enabled() bool {
+	return t.CertFile != ""
+}
+
+// certReloader serves the certificates in the files of tlsConfig, and reloads
+// them whenever the files change, so that rotated certificates are picked up
+// without a restart.
+type certReloader struct {
+	conf   tlsConfig
+	logger logging.LevelLogger
+	cert   atomic.Value // *tls.Certificate
+	pool   atomic.Value // *x509.CertPool
+}
+
+func newCertReloader(conf tlsConfig, logger logging.LevelLogger) (*certReloader, error) {
+	if conf.KeyFile == "" {
+		return nil, fmt.Errorf("the key file must be set along with the cert file %s", conf.CertFile)
+	}
+	if conf.RequireClientCert && conf.ClientCAFile == "" {
+		return nil, fmt.Errorf("the client CA file must be set to require client certificates")
+	}
+	r := &certReloader{conf: conf, logger: logger}
+	if err := r.load(); err != nil {
+		return nil, err
+	}
+	return r, nil
+}
+
+func (r *certReloader) load() error {
+	cert, err := tls.LoadX509KeyPair(r.conf.CertFile, r.conf.KeyFile)
+	if err != nil {
+		return fmt.Errorf("failed to load tls certificate: %w", err)
+	}
+	if r.conf.ClientCAFile != "" {
+		pem, err := os.ReadFile(r.conf.ClientCAFile)
+		if err != nil {
+			return fmt.Errorf("failed to read client CA file: %w", err)
+		}
+		pool := x509.NewCertPool()
+		if !pool.AppendCertsFromPEM(pem) {
+			return fmt.Errorf("no valid certificate found in client CA file %s", r.conf.ClientCAFile)
+		}
+		r.pool.Store(pool)
+	}
+	r.cert.Store(&cert)
+	return nil
+}
+
+// tlsConfig returns a *tls.Config that always uses the latest certificates.
+func (r *certReloader) tlsConfig(nextProtos ...string) *tls.Config {
+	return &tls.Config{
+		MinVersion: tls.VersionTLS12,
+		NextProtos: nextProtos,
+		GetConfigForClient: func(*tls.ClientHelloInfo) (*tls.Config, error) {
+			conf := &tls.Config{
+				MinVersion:   tls.VersionTLS12,
+				NextProtos:   nextProtos,
+				Certificates: []tls.Certificate{*r.cert.Load().(*tls.Certificate)},
+			}
+			if pool, ok := r.pool.Load().(*x509.CertPool); ok {
+				conf.ClientCAs = pool
+				conf.ClientAuth = tls.VerifyClientCertIfGiven
+				if r.conf.RequireClientCert {
+					conf.ClientAuth = tls.RequireAndVerifyClientCert
+				}
+			}
+			return conf, nil
+		},
+	}
+}
+
+// watch reloads the certificates whenever any of the files changes, until the
+// context is canceled. A failed reload is logged and the previous certificates
+// are kept. The reload is delayed until the files have been quiet for a short
+// while, since the cert and the key are rarely replaced at once. watcher.File
+// stops when its file is removed, so the watch is restarted after a short
+// delay, and a reload is triggered once the file is recreated, since the
+// changes made meanwhile are missed.
+func (r *certReloader) watch(ctx context.Context) {
+	changed := make(chan struct{}, 1)
+	notify := func() error {
+		select {
+		case changed <- struct{}{}:
+		default:
+		}
+		return nil
+	}
+	for _, path := range []string{r.conf.CertFile, r.conf.KeyFile, r.conf.ClientCAFile} {
+		if path == "" {
+			continue
+		}
+		go func(path string) {
+			for {
+				err := (watcher.File{Path: path}).Watch(ctx, notify)
+				if ctx.Err() != nil {
+					return
+				}
+				r.logger.Debugf("restarting the watch of %s: %s", path, err)
+				select {
+				case <-time.After(certReloadDelay):
+					if _, err := os.Stat(path); err == nil {
+						notify()
+					}
+				case <-ctx.Done():
+					return
+				}
+			}
+		}(path)
+	}
+	go func() {
+		timer := time.NewTimer(0)
+		<-timer.C
+		for {
+			select {
+			case <-changed:
+				timer.Reset(certReloadDelay)
+			case <-timer.C:
+				if err := r.load(); err != nil {
+					r.logger.Warnf("keep using the previous certificates: %s", err)
+					continue
+				}
+				r.logger.Infof("tls certificates reloaded")
+			case <-ctx.Done():
+				timer.Stop()
+				return
+			}
+		}
+	}()
+}
@@ -0,0 +1,318 @@
+package core
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"io"
+	"math/big"
+	"net"
+	"net/http"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/DoNewsCode/core/contract/lifecycle"
+	"github.com/DoNewsCode/core/logging"
+	"github.com/DoNewsCode/core/srvgrpc"
+
+	"github.com/go-kit/log"
+	"github.com/gorilla/mux"
+	"github.com/stretchr/testify/assert"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/credentials"
+	healthpb "google.golang.org/grpc/health/grpc_health_v1"
+	"google.golang.org/grpc/peer"
+)
+
+type testCA struct {
+	cert *x509.Certificate
+	key  *ecdsa.PrivateKey
+	pem  []byte
+}
+
+func newTestCA(t *testing.T) testCA {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	tpl := &x509.Certificate{
+		SerialNumber:          big.NewInt(1),
+		Subject:               pkix.Name{CommonName: "test ca"},
+		NotBefore:             time.Now().Add(-time.Hour),
+		NotAfter:              time.Now().Add(time.Hour),
+		IsCA:                  true,
+		KeyUsage:              x509.KeyUsageCertSign,
+		BasicConstraintsValid: true,
+	}
+	der, err := x509.CreateCertificate(rand.Reader, tpl, tpl, &key.PublicKey, key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	cert, err := x509.ParseCertificate(der)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return testCA{cert: cert, key: key, pem: pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der})}
+}
+
+// issue returns a certificate and its key signed by the CA, both PEM encoded.
+func (ca testCA) issue(t *testing.T, cn string, usage x509.ExtKeyUsage) ([]byte, []byte) {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	tpl := &x509.Certificate{
+		SerialNumber: big.NewInt(time.Now().UnixNano()),
+		Subject:      pkix.Name{CommonName: cn},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+		KeyUsage:     x509.KeyUsageDigitalSignature,
+		ExtKeyUsage:  []x509.ExtKeyUsage{usage},
+		DNSNames:     []string{"localhost"},
+		IPAddresses:  []net.IP{net.ParseIP("127.0.0.1")},
+	}
+	der, err := x509.CreateCertificate(rand.Reader, tpl, ca.cert, &key.PublicKey, ca.key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	keyDer, err := x509.MarshalECPrivateKey(key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der}),
+		pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: keyDer})
+}
+
+func writeFile(t *testing.T, path string, data []byte) {
+	if err := os.WriteFile(path, data, 0o600); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestServe_tls(t *testing.T) {
+	var (
+		dir        = t.TempDir()
+		ca         = newTestCA(t)
+		caFile     = filepath.Join(dir, "ca.pem")
+		certFile   = filepath.Join(dir, "cert.pem")
+		keyFile    = filepath.Join(dir, "key.pem")
+		cert, key  = ca.issue(t, "server", x509.ExtKeyUsageServerAuth)
+		clientCert = func() tls.Certificate {
+			cert, key := ca.issue(t, "client", x509.ExtKeyUsageClientAuth)
+			pair, err := tls.X509KeyPair(cert, key)
+			if err != nil {
+				t.Fatal(err)
+			}
+			return pair
+		}()
+		tlsConf = map[string]any{
+			"certFile":          certFile,
+			"keyFile":           keyFile,
+			"clientCAFile":      caFile,
+			"requireClientCert": true,
+		}
+	)
+	writeFile(t, caFile, ca.pem)
+	writeFile(t, certFile, cert)
+	writeFile(t, keyFile, key)
+
+	c := New(
+		WithInline("http.addr", "127.0.0.1:0"),
+		WithInline("http.tls", tlsConf),
+		WithInline("grpc.addr", "127.0.0.1:0"),
+		WithInline("grpc.tls", tlsConf),
+		WithInline("cron.disable", true),
+		WithInline("log.level", "none"),
+	)
+	c.ProvideEssentials()
+	c.AddModule(srvgrpc.HealthCheckModule{})
+	c.AddModule(HttpFunc(func(router *mux.Router) {
+		router.HandleFunc("/", func(writer http.ResponseWriter, request *http.Request) {
+			writer.Write([]byte(request.TLS.PeerCertificates[0].Subject.CommonName))
+		})
+	}))
+	grpcPeer := make(chan string, 1)
+	c.AddModule(peerModule{peer: grpcPeer})
+
+	var (
+		httpAddr = make(chan string, 1)
+		grpcAddr = make(chan string, 1)
+	)
+	c.Invoke(func(httpStart lifecycle.HTTPServerStart, grpcStart lifecycle.GRPCServerStart) {
+		httpStart.On(func(ctx context.Context, payload lifecycle.HTTPServerStartPayload) error {
+			httpAddr <- payload.Listener.Addr().String()
+			return nil
+		})
+		grpcStart.On(func(ctx context.Context, payload lifecycle.GRPCServerStartPayload) error {
+			grpcAddr <- payload.Listener.Addr().String()
+			return nil
+		})
+	})
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go c.Serve(ctx)
+
+	roots := x509.NewCertPool()
+	roots.AddCert(ca.cert)
+	client := func(certs ...tls.Certificate) *http.Client {
+		return &http.Client{Transport: &http.Transport{
+			TLSClientConfig: &tls.Config{RootCAs: roots, Certificates: certs},
+		}}
+	}
+
+	t.Run("http", func(t *testing.T) {
+		addr := "https://" + <-httpAddr + "/"
+
+		_, err := client().Get(addr)
+		assert.Error(t, err, "client certificate is required")
+
+		resp, err := client(clientCert).Get(addr)
+		if err != nil {
+			t.Fatal(err)
+		}
+		resp.Body.Close()
+		assert.Equal(t, "server", resp.TLS.PeerCertificates[0].Subject.CommonName)
+
+		// rotate the certificate
+		cert, key := ca.issue(t, "rotated", x509.ExtKeyUsageServerAuth)
+		writeFile(t, keyFile, key)
+		writeFile(t, certFile, cert)
+		assert.Eventually(t, func() bool {
+			resp, err := client(clientCert).Get(addr)
+			if err != nil {
+				return false
+			}
+			resp.Body.Close()
+			return resp.TLS.PeerCertificates[0].Subject.CommonName == "rotated"
+		}, 5*time.Second, 50*time.Millisecond)
+	})
+
+	t.Run("grpc", func(t *testing.T) {
+		creds := credentials.NewTLS(&tls.Config{RootCAs: roots, Certificates: []tls.Certificate{clientCert}})
+		conn, err := grpc.Dial(<-grpcAddr, grpc.WithTransportCredentials(creds))
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer conn.Close()
+		resp, err := healthpb.NewHealthClient(conn).Check(ctx, &healthpb.HealthCheckRequest{})
+		if err != nil {
+			t.Fatal(err)
+		}
+		assert.Equal(t, healthpb.HealthCheckResponse_SERVING, resp.Status)
+		assert.Equal(t, "client", <-grpcPeer)
+	})
+}
+
+// peerModule reports the common name of the client certificate of each RPC.
+type peerModule struct {
+	peer chan string
+}
+
+func (m peerModule) ProvideGRPCInterceptor() []GRPCInterceptor {
+	return []GRPCInterceptor{{Unary: func(ctx context.Context, req any, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (any, error) {
+		var cn string
+		if p, ok := peer.FromContext(ctx); ok {
+			if info, ok := p.AuthInfo.(credentials.TLSInfo); ok && len(info.State.PeerCertificates) > 0 {
+				cn = info.State.PeerCertificates[0].Subject.CommonName
+			}
+		}
+		select {
+		case m.peer <- cn:
+		default:
+		}
+		return handler(ctx, req)
+	}}}
+}
+
+func TestServe_grpcGatewayTLS(t *testing.T) {
+	var (
+		dir       = t.TempDir()
+		ca        = newTestCA(t)
+		certFile  = filepath.Join(dir, "cert.pem")
+		keyFile   = filepath.Join(dir, "key.pem")
+		cert, key = ca.issue(t, "server", x509.ExtKeyUsageServerAuth)
+	)
+	writeFile(t, certFile, cert)
+	writeFile(t, keyFile, key)
+
+	c := Default(
+		WithInline("http.addr", "127.0.0.1:0"),
+		WithInline("grpc.addr", "127.0.0.1:0"),
+		WithInline("grpc.tls", map[string]any{"certFile": certFile, "keyFile": keyFile}),
+		WithInline("cron.disable", true),
+		WithInline("log.level", "none"),
+	)
+	c.AddModule(srvgrpc.HealthCheckModule{})
+	c.AddModule(gatewayModule{})
+
+	httpAddr := make(chan string, 1)
+	c.Invoke(func(httpStart lifecycle.HTTPServerStart) {
+		httpStart.On(func(ctx context.Context, payload lifecycle.HTTPServerStartPayload) error {
+			httpAddr <- payload.Listener.Addr().String()
+			return nil
+		})
+	})
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go c.Serve(ctx)
+
+	// The gateway reaches the gRPC server in plaintext, while the gRPC
+	// listener requires TLS.
+	resp, err := http.Get("http://" + <-httpAddr + "/health/default")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+	body, _ := io.ReadAll(resp.Body)
+	assert.Equal(t, http.StatusOK, resp.StatusCode)
+	assert.Equal(t, "SERVING", string(body))
+}
+
+func TestCertReloader_recreate(t *testing.T) {
+	var (
+		dir       = t.TempDir()
+		ca        = newTestCA(t)
+		certFile  = filepath.Join(dir, "cert.pem")
+		keyFile   = filepath.Join(dir, "key.pem")
+		cert, key = ca.issue(t, "server", x509.ExtKeyUsageServerAuth)
+	)
+	writeFile(t, certFile, cert)
+	writeFile(t, keyFile, key)
+
+	r, err := newCertReloader(tlsConfig{CertFile: certFile, KeyFile: keyFile}, logging.WithLevel(log.NewNopLogger()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	r.watch(ctx)
+
+	commonName := func() string {
+		leaf, err := x509.ParseCertificate(r.cert.Load().(*tls.Certificate).Certificate[0])
+		if err != nil {
+			t.Fatal(err)
+		}
+		return leaf.Subject.CommonName
+	}
+	assert.Equal(t, "server", commonName())
+
+	// Each rotation deletes the files and creates them again.
+	for _, cn := range []string{"first", "second"} {
+		time.Sleep(50 * time.Millisecond)
+		cert, key := ca.issue(t, cn, x509.ExtKeyUsageServerAuth)
+		os.Remove(certFile)
+		os.Remove(keyFile)
+		writeFile(t, keyFile, key)
+		writeFile(t, certFile, cert)
+		assert.Eventually(t, func() bool { return commonName() == cn }, 5*time.Second, 50*time.Millisecond)
+	}
+}